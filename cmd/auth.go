@@ -0,0 +1,145 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/latocchi/gomailit/internal/providers"
+	"github.com/latocchi/gomailit/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+// authCmd represents the auth command
+var authCmd = &cobra.Command{
+	Use:   "auth",
+	Short: "Inspect, refresh and revoke the stored Google credential",
+	Long: `Manage the Google credential of the selected profile.
+
+Examples:
+
+Show the account, scopes, expiry and where the token is kept
+gomailit auth status
+
+Get a new access token now
+gomailit auth refresh
+
+Revoke access at Google and delete the token
+gomailit auth revoke
+
+Delete the token without revoking it
+gomailit auth logout
+`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		rootCmd.PersistentPreRun(cmd, args)
+		requireGoogle()
+	},
+}
+
+// authStatusCmd represents the auth status command
+var authStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the account, scopes, expiry and token store of the credential",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		status, err := providers.GetGoogleAuthStatus(cmd.Context())
+		if status == nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Profile:      %s\n", utils.Profile())
+		if status.Account != "" {
+			fmt.Printf("Account:      %s\n", status.Account)
+		}
+		if status.ServiceAccount != "" {
+			fmt.Printf("Credential:   service account %s\n", status.ServiceAccount)
+		} else if status.HasRefreshToken {
+			fmt.Println("Credential:   OAuth token with refresh token")
+		} else {
+			fmt.Println("Credential:   OAuth token without refresh token")
+		}
+		if len(status.Scopes) > 0 {
+			fmt.Printf("Scopes:       %s\n", strings.Join(status.Scopes, "\n              "))
+		}
+		if !status.Expiry.IsZero() {
+			fmt.Printf("Expires:      %s (in %s)\n", status.Expiry.Local().Format(time.DateTime), time.Until(status.Expiry).Round(time.Second))
+		}
+		fmt.Printf("Token store:  %s\n", status.Store)
+		if status.Transport != "" {
+			fmt.Printf("Transport:    %s\n", status.Transport)
+		}
+
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	},
+}
+
+// authRefreshCmd represents the auth refresh command
+var authRefreshCmd = &cobra.Command{
+	Use:   "refresh",
+	Short: "Get a new access token and save it",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		tok, err := providers.RefreshGoogleToken(cmd.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Token refreshed, it expires at %s.\n", tok.Expiry.Local().Format(time.DateTime))
+	},
+}
+
+// authRevokeCmd represents the auth revoke command
+var authRevokeCmd = &cobra.Command{
+	Use:   "revoke",
+	Short: "Revoke access at Google and delete the token",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := providers.RevokeGoogleToken(cmd.Context()); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Println("Access revoked and token deleted.")
+	},
+}
+
+// authLogoutCmd represents the auth logout command
+var authLogoutCmd = &cobra.Command{
+	Use:   "logout",
+	Short: "Delete the credential without revoking it",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := providers.LogoutGoogle(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Println("Logged out.")
+	},
+}
+
+// requireGoogle exits unless the selected profile sends through Google,
+// the only provider with a credential to manage.
+func requireGoogle() {
+	name := providers.ActiveProvider()
+	r, err := providers.Lookup(name)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if r.Name != "google" {
+		fmt.Fprintf(os.Stderr, "Profile %s uses the %s provider, 'gomailit auth' manages Google credentials only\n", utils.Profile(), r.Name)
+		os.Exit(1)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(authCmd)
+	authCmd.AddCommand(authStatusCmd, authRefreshCmd, authRevokeCmd, authLogoutCmd)
+}
@@ -0,0 +1,303 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/latocchi/gomailit/internal/config"
+	"github.com/latocchi/gomailit/internal/providers"
+	"github.com/spf13/cobra"
+)
+
+// configTemplate is what 'gomailit config edit' starts from when there is
+// no config file yet.
+const configTemplate = `# gomailit configuration, see 'gomailit config --help'.
+#
+# provider = "google"
+# from = "Alice <alice@example.com>"
+# signature = """
+# --
+# Alice"""
+# concurrency = 5
+# history_keep = 12
+#
+# [rate_limits.google]
+# per_second = 2.0
+# per_day = 500
+#
+# [profiles.billing]
+# provider = "google"
+# from = "billing@example.com"
+`
+
+// configCmd represents the config command
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Show and change settings in the config file",
+	Long: `Settings live in config.toml in the gomailit config directory, or the file
+given with --config or $GOMAILIT_CONFIG. Keys:
+
+  default_profile              Profile used when --profile is not given
+  provider                     Provider of the default profile
+  from                         Default From address of the default profile
+  signature                    Signature of the default profile
+  concurrency                  Messages sent at the same time (default 5)
+  history_keep                 Rotated history files kept (default 0, all)
+  rate_limits.<provider>.per_second
+  rate_limits.<provider>.per_day
+  profiles.<name>.provider     Settings of a named profile
+  profiles.<name>.from
+  profiles.<name>.signature
+
+Environment variables override the file:
+
+  GOMAILIT_CONFIG              Config file to use
+  GOMAILIT_PROFILE             Profile used when --profile is not given
+  GOMAILIT_PROVIDER            Provider of the selected profile
+  GOMAILIT_FROM                Default From address of the selected profile
+  GOMAILIT_SIGNATURE           Signature of the selected profile
+  GOMAILIT_CONCURRENCY         Messages sent at the same time
+
+Examples:
+
+gomailit config get
+gomailit config get rate_limits.google.per_day
+gomailit config set concurrency 10
+gomailit config set rate_limits.google.per_day 500
+gomailit config set from ""        # reset a key
+gomailit config edit
+gomailit config validate
+
+'config set' rewrites the file, which drops comments; use 'config edit' to
+keep them.
+`,
+}
+
+// configGetCmd represents the config get command
+var configGetCmd = &cobra.Command{
+	Use:   "get [key]",
+	Short: "Print a setting, or the whole config with environment overrides applied",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		key := ""
+		if len(args) > 0 {
+			key = args[0]
+		}
+		value, err := loadConfig().Get(key)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Println(value)
+	},
+}
+
+// configSetCmd represents the config set command
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Change a setting in the config file",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadConfigFile()
+		if err := cfg.Set(args[0], args[1]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if err := validateConfig(cfg); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if err := cfg.Save(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	},
+}
+
+// configEditCmd represents the config edit command
+var configEditCmd = &cobra.Command{
+	Use:   "edit",
+	Short: "Open the config file in $VISUAL or $EDITOR and validate it",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := editConfig(config.Path(), os.Stdin, os.Stdout); err != nil {
+			if !errors.Is(err, errEditDiscarded) {
+				fmt.Fprintln(os.Stderr, err)
+			}
+			os.Exit(1)
+		}
+	},
+}
+
+// errEditDiscarded is returned by editConfig when the user gives up on an
+// invalid config.
+var errEditDiscarded = errors.New("changes discarded")
+
+// editConfig opens a copy of the config file at path in the user's editor
+// and saves it once it is valid. While it is not, the user is asked whether
+// to edit it again, reading the answer from in; no answer at all, such as at
+// the end of the input, discards the changes.
+func editConfig(path string, in io.Reader, out io.Writer) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		data = []byte(configTemplate)
+	} else if err != nil {
+		return fmt.Errorf("unable to read config: %v", err)
+	}
+
+	// Edit a copy so that a broken config never replaces a working one.
+	tmp, err := os.CreateTemp(filepath.Dir(path), "config.*.toml")
+	if err != nil {
+		return fmt.Errorf("unable to create temporary file: %v", err)
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+	if err := os.WriteFile(tmp.Name(), data, 0600); err != nil {
+		return fmt.Errorf("unable to create temporary file: %v", err)
+	}
+
+	input := bufio.NewReader(in)
+	for {
+		if err := runEditor(tmp.Name()); err != nil {
+			return err
+		}
+		edited, err := os.ReadFile(tmp.Name())
+		if err != nil {
+			return fmt.Errorf("unable to read edited config: %v", err)
+		}
+		if string(edited) == string(data) {
+			fmt.Fprintln(out, "No changes.")
+			return nil
+		}
+
+		cfg, err := config.Parse(edited)
+		if err == nil {
+			err = validateConfig(cfg)
+		}
+		if err == nil {
+			if err := config.WriteFile(path, edited); err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "Saved %s\n", path)
+			return nil
+		}
+
+		fmt.Fprintf(os.Stderr, "Invalid config: %v\n", err)
+		fmt.Fprint(out, "Edit again? [Y/n] ")
+		answer, err := input.ReadString('\n')
+		if err != nil && answer == "" || strings.HasPrefix(strings.ToLower(strings.TrimSpace(answer)), "n") {
+			fmt.Fprintln(out, "Changes discarded.")
+			return errEditDiscarded
+		}
+	}
+}
+
+// configValidateCmd represents the config validate command
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the config file and environment variables for errors",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := config.Load()
+		if err == nil {
+			err = validateConfig(cfg)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid config: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("%s is valid.\n", config.Path())
+	},
+}
+
+// validateConfig checks the config values, including that every provider it
+// names exists.
+func validateConfig(cfg *config.Config) error {
+	var problems []string
+	if err := cfg.Validate(); err != nil {
+		problems = append(problems, err.Error())
+	}
+	for _, name := range cfg.ProfileNames() {
+		profile, _ := cfg.GetProfile(name)
+		if profile.Provider == "" {
+			continue
+		}
+		if _, err := providers.Lookup(profile.Provider); err != nil {
+			problems = append(problems, fmt.Sprintf("profile %s: %v", name, err))
+		}
+	}
+	names := make([]string, 0, len(cfg.RateLimits))
+	for name := range cfg.RateLimits {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, err := providers.Lookup(name); err != nil {
+			problems = append(problems, fmt.Sprintf("rate_limits.%s: %v", name, err))
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+// runEditor opens path in the user's editor.
+func runEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+		if runtime.GOOS == "windows" {
+			editor = "notepad"
+		}
+	}
+
+	// $EDITOR may include arguments, such as "code --wait".
+	fields := strings.Fields(editor)
+	c := exec.Command(fields[0], append(fields[1:], path)...)
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("unable to run editor %s: %v", editor, err)
+	}
+	return nil
+}
+
+// loadConfig returns the config with the GOMAILIT_* environment variables
+// applied.
+func loadConfig() *config.Config {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	return cfg
+}
+
+// loadConfigFile returns the config file as it is, for changing it.
+func loadConfigFile() *config.Config {
+	cfg, err := config.LoadFile()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	return cfg
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configGetCmd, configSetCmd, configEditCmd, configValidateCmd)
+}
@@ -0,0 +1,214 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/latocchi/gomailit/internal/config"
+)
+
+func TestValidateConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []string
+	}{
+		{
+			name: "valid",
+			src:  "provider = \"gmail\"\n[rate_limits.microsoft]\nper_day = 10\n[profiles.work]\nprovider = \"smtp\"\nfrom = \"Bob <bob@example.com>\"",
+		},
+		{
+			name: "unknown provider",
+			src:  "provider = \"pigeon\"",
+			want: []string{"profile default: unsupported provider: pigeon"},
+		},
+		{
+			name: "unknown profile provider",
+			src:  "[profiles.work]\nprovider = \"pigeon\"",
+			want: []string{"profile work: unsupported provider: pigeon"},
+		},
+		{
+			name: "unknown rate limit provider",
+			src:  "[rate_limits.pigeon]\nper_day = 10",
+			want: []string{"rate_limits.pigeon: unsupported provider: pigeon"},
+		},
+		{
+			name: "negative values",
+			src:  "concurrency = -1\nhistory_keep = -1\n[rate_limits.google]\nper_day = -5",
+			want: []string{"concurrency must not be negative", "history_keep must not be negative", "rate_limits.google must not be negative"},
+		},
+		{
+			name: "missing default profile",
+			src:  "default_profile = \"work\"",
+			want: []string{`default_profile "work" does not exist`},
+		},
+		{
+			name: "invalid from",
+			src:  "[profiles.work]\nfrom = \"not an address\"",
+			want: []string{"profile work: invalid from"},
+		},
+		{
+			name: "every problem",
+			src:  "default_profile = \"home\"\n[rate_limits.pigeon]\nper_day = 1\n[profiles.work]\nprovider = \"owl\"",
+			want: []string{`default_profile "home" does not exist`, "profile work: unsupported provider: owl", "rate_limits.pigeon: unsupported provider: pigeon"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := config.Parse([]byte(tt.src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = validateConfig(cfg)
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Errorf("error = %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("no error, want %q", tt.want)
+			}
+			problems := strings.Split(err.Error(), "\n")
+			if len(problems) != len(tt.want) {
+				t.Fatalf("problems = %q, want %q", problems, tt.want)
+			}
+			for i, want := range tt.want {
+				if !strings.HasPrefix(problems[i], want) {
+					t.Errorf("problem %d = %q, want %q", i+1, problems[i], want)
+				}
+			}
+		})
+	}
+}
+
+// useEditor makes runEditor replace the edited file with each of edits in
+// turn, and returns how many times it ran.
+func useEditor(t *testing.T, edits ...string) func() int {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake editor is a shell script")
+	}
+	dir := t.TempDir()
+	for i, edit := range edits {
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("edit%d", i+1)), []byte(edit), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	script := filepath.Join(dir, "editor")
+	src := fmt.Sprintf(`#!/bin/sh
+n=$(($(cat %[1]s/count 2>/dev/null || echo 0) + 1))
+echo $n > %[1]s/count
+cp %[1]s/edit$n "$1"
+`, dir)
+	if err := os.WriteFile(script, []byte(src), 0700); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("VISUAL", script)
+
+	return func() int {
+		data, err := os.ReadFile(filepath.Join(dir, "count"))
+		if err != nil {
+			return 0
+		}
+		var n int
+		fmt.Sscan(string(data), &n)
+		return n
+	}
+}
+
+func TestEditConfig(t *testing.T) {
+	const (
+		original = "provider = \"smtp\"\n"
+		invalid  = "provider = \"pigeon\"\n"
+		broken   = "provider = smtp\n"
+		valid    = "# Work first\nprovider = \"gmail\"\n"
+	)
+	tests := []struct {
+		name   string
+		edits  []string
+		answer string
+		runs   int
+		err    error
+		want   string
+		output string
+	}{
+		{"valid", []string{valid}, "", 1, nil, valid, "Saved"},
+		{"no changes", []string{original}, "", 1, nil, original, "No changes."},
+		{"again after an invalid config", []string{invalid, broken, valid}, "\ny\n", 3, nil, valid, "Edit again? [Y/n] Edit again? [Y/n] Saved"},
+		{"discarded", []string{invalid}, "n\n", 1, errEditDiscarded, original, "Changes discarded."},
+		{"discarded without an answer", []string{invalid}, "", 1, errEditDiscarded, original, "Changes discarded."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.toml")
+			if err := os.WriteFile(path, []byte(original), 0600); err != nil {
+				t.Fatal(err)
+			}
+			runs := useEditor(t, tt.edits...)
+
+			var out bytes.Buffer
+			err := editConfig(path, strings.NewReader(tt.answer), &out)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("error = %v, want %v", err, tt.err)
+			}
+			if got := runs(); got != tt.runs {
+				t.Errorf("editor ran %d times, want %d", got, tt.runs)
+			}
+			if !strings.Contains(out.String(), tt.output) {
+				t.Errorf("output = %q, want %q", out.String(), tt.output)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("config = %q, want %q", data, tt.want)
+			}
+
+			// The copy being edited is always removed.
+			matches, _ := filepath.Glob(filepath.Join(filepath.Dir(path), "config.*.toml"))
+			if len(matches) != 0 {
+				t.Errorf("temporary files left: %v", matches)
+			}
+		})
+	}
+}
+
+func TestEditConfigNew(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("true is not a command on Windows")
+	}
+	path := filepath.Join(t.TempDir(), "config.toml")
+
+	// Closing the template unchanged creates no file.
+	t.Setenv("VISUAL", "true")
+	var out bytes.Buffer
+	if err := editConfig(path, strings.NewReader(""), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "No changes.\n" {
+		t.Errorf("output = %q", out.String())
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("config created: %v", err)
+	}
+
+	// The template itself is a valid config.
+	cfg, err := config.Parse([]byte(configTemplate))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validateConfig(cfg); err != nil {
+		t.Error(err)
+	}
+}
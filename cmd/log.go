@@ -0,0 +1,190 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/latocchi/gomailit/internal/config"
+	"github.com/latocchi/gomailit/internal/history"
+	"github.com/latocchi/gomailit/internal/providers"
+	"github.com/latocchi/gomailit/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var (
+	logSince  string
+	logTo     string
+	logFailed bool
+	logJSON   bool
+)
+
+// logCmd represents the log command
+var logCmd = &cobra.Command{
+	Use:   "log",
+	Short: "Show the history of sent messages",
+	Long: `Every message gomailit sends, or fails to send, is recorded in history.jsonl
+in the gomailit config directory: when it was sent, from which profile, the
+recipients, subject, attachment names and SHA-256 hashes, the ID the provider
+gave it (and its Gmail thread) and the outcome. The history of every profile
+is shown unless --profile is given.
+
+Once history.jsonl reaches 10 MB it is renamed to history.jsonl.1, the
+previous history.jsonl.1 to history.jsonl.2 and so on. Every rotated file is
+kept, and read by this command, unless history_keep is set in the config:
+
+gomailit config set history_keep 12
+
+keeps the 12 newest rotated files and deletes older history when the file
+next rotates.
+
+Examples:
+
+Show what was sent in the last day
+gomailit log --since 24h
+
+Show every message sent to a customer since the start of the month
+gomailit log --to customer@example.com --since 2025-06-01
+
+Show failed sends as JSON
+gomailit log --failed --json
+`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		records, err := logRecords(time.Now())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		printLog(os.Stdout, records, logJSON)
+	},
+}
+
+// logRecords returns the records the log flags select.
+func logRecords(now time.Time) ([]*history.Record, error) {
+	filter := history.Filter{To: logTo, Failed: logFailed}
+	if logSince != "" {
+		since, err := parseSince(logSince, now)
+		if err != nil {
+			return nil, err
+		}
+		filter.Since = since
+	}
+	if profileName != "" {
+		filter.Profile = utils.Profile()
+	}
+	return openHistory().List(filter)
+}
+
+// printLog prints records as a table, or as JSON.
+func printLog(out io.Writer, records []*history.Record, asJSON bool) {
+	if asJSON {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false)
+		if records == nil {
+			records = []*history.Record{}
+		}
+		encoder.Encode(records)
+		return
+	}
+	if len(records) == 0 {
+		fmt.Fprintln(out, "No messages found.")
+		return
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tPROFILE\tPROVIDER\tOUTCOME\tTO\tSUBJECT\tATTACHMENTS\tID")
+	for _, record := range records {
+		id := record.ProviderID
+		if record.Outcome == history.Failed {
+			id = truncate(record.Error, 60)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%d\t%s\n",
+			record.Time.Local().Format(time.DateTime), record.Profile, record.Provider, record.Outcome,
+			truncate(strings.Join(record.Recipients(), ", "), 40), truncate(record.Subject, 40),
+			len(record.Attachments), id)
+	}
+	w.Flush()
+}
+
+// parseSince parses --since, either a duration back from now such as 36h
+// or 7d, or a date or time such as 2025-06-01 or "2025-06-01 15:04".
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	for _, layout := range []string{time.RFC3339, time.DateTime, "2006-01-02 15:04", time.DateOnly} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %q, expected a duration such as 24h or 7d, or a date such as 2025-06-01", value)
+}
+
+// openHistory returns the history with the retention set by history_keep.
+// An unreadable config keeps every rotated file.
+func openHistory() *history.Log {
+	log := history.Open(utils.HistoryPath())
+	if cfg, err := config.Load(); err == nil {
+		log.Keep = cfg.HistoryKeep
+	}
+	return log
+}
+
+// recordSend adds the outcome of a send through the provider called name to
+// the history. sender is what sent the message, asked for the thread ID
+// when the provider has threads. Failing to record never fails the send.
+func recordSend(record history.Record, name string, sender any, id string, err error) {
+	record.Time = time.Now()
+	record.Profile = utils.Profile()
+	record.Provider = name
+	if err != nil {
+		record.Outcome = history.Failed
+		record.Error = err.Error()
+	} else {
+		record.Outcome = history.Sent
+		record.ProviderID = id
+		record.ThreadID = threadID(sender, id)
+	}
+	if err := openHistory().Append(record); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+}
+
+// threadID returns the thread of a sent message when the provider behind
+// sender reports threads.
+func threadID(sender any, id string) string {
+	switch s := sender.(type) {
+	case *limitedSender:
+		sender = s.Sender
+	case *limitedRawSender:
+		sender = s.RawSender
+	}
+	if reporter, ok := sender.(providers.ThreadReporter); ok && id != "" {
+		return reporter.ThreadID(id)
+	}
+	return ""
+}
+
+func init() {
+	rootCmd.AddCommand(logCmd)
+
+	logCmd.Flags().StringVar(&logSince, "since", "", "Only show messages sent since a duration ago (24h, 7d) or a date (2025-06-01)")
+	logCmd.Flags().StringVar(&logTo, "to", "", "Only show messages with a recipient containing this address")
+	logCmd.Flags().BoolVar(&logFailed, "failed", false, "Only show messages that failed to send")
+	logCmd.Flags().BoolVar(&logJSON, "json", false, "Print the records as JSON")
+}
@@ -0,0 +1,159 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/latocchi/gomailit/internal/history"
+	"github.com/latocchi/gomailit/internal/utils"
+)
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2025, 6, 15, 12, 0, 0, 0, time.Local)
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"7d", now.AddDate(0, 0, -7)},
+		{"36h", now.Add(-36 * time.Hour)},
+		{"90m", now.Add(-90 * time.Minute)},
+		{"2025-06-01T08:00:00Z", time.Date(2025, 6, 1, 8, 0, 0, 0, time.UTC)},
+		{"2025-06-01 15:04:05", time.Date(2025, 6, 1, 15, 4, 5, 0, time.Local)},
+		{"2025-06-01 15:04", time.Date(2025, 6, 1, 15, 4, 0, 0, time.Local)},
+		{"2025-06-01", time.Date(2025, 6, 1, 0, 0, 0, 0, time.Local)},
+	}
+	for _, tt := range tests {
+		got, err := parseSince(tt.value, now)
+		if err != nil {
+			t.Errorf("parseSince(%q): %v", tt.value, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("parseSince(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+
+	for _, value := range []string{"yesterday", "7w", "d", "2025-13-01"} {
+		if _, err := parseSince(value, now); err == nil {
+			t.Errorf("parseSince(%q) succeeded", value)
+		}
+	}
+}
+
+// useLogFlags sets the log flags for the test.
+func useLogFlags(t *testing.T, since, to string, failed bool, profile string) {
+	logSince, logTo, logFailed, profileName = since, to, failed, profile
+	utils.SetProfile(profile)
+	t.Cleanup(func() {
+		logSince, logTo, logFailed, profileName = "", "", false, ""
+		utils.SetProfile("")
+	})
+}
+
+func TestLogFilters(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	now := time.Now()
+	log := openHistory()
+	for _, record := range []history.Record{
+		{Time: now.Add(-72 * time.Hour), Profile: "default", To: []string{"old@example.com"}, Outcome: history.Sent},
+		{Time: now.Add(-2 * time.Hour), Profile: "default", To: []string{"Customer@Example.com"}, Outcome: history.Sent},
+		{Time: now.Add(-time.Hour), Profile: "work", To: []string{"team@example.com"}, Cc: []string{"customer@example.com"}, Outcome: history.Failed, Error: "550 rejected"},
+		{Time: now, Profile: "work", To: []string{"boss@example.com"}, Outcome: history.Sent},
+	} {
+		if err := log.Append(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		since   string
+		to      string
+		failed  bool
+		profile string
+		want    string
+	}{
+		{name: "everything", want: "old,Customer,team,boss"},
+		{name: "since", since: "24h", want: "Customer,team,boss"},
+		{name: "since date", since: now.AddDate(0, 0, 1).Format(time.DateOnly), want: ""},
+		{name: "to", to: "customer@example.com", want: "Customer,team"},
+		{name: "failed", failed: true, want: "team"},
+		{name: "profile", profile: "work", want: "team,boss"},
+		{name: "default profile", profile: "default", want: "old,Customer"},
+		{name: "combined", since: "90m", to: "example.com", profile: "work", want: "team,boss"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useLogFlags(t, tt.since, tt.to, tt.failed, tt.profile)
+			records, err := logRecords(now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, record := range records {
+				name, _, _ := strings.Cut(record.To[0], "@")
+				got = append(got, name)
+			}
+			if strings.Join(got, ",") != tt.want {
+				t.Errorf("records = %v, want %s", got, tt.want)
+			}
+		})
+	}
+
+	useLogFlags(t, "last week", "", false, "")
+	if _, err := logRecords(now); err == nil {
+		t.Error("invalid --since accepted")
+	}
+}
+
+func TestPrintLog(t *testing.T) {
+	records := []*history.Record{
+		{Time: time.Now(), Profile: "default", Provider: "gmail", To: []string{"bob@example.com"}, Subject: "Report",
+			Attachments: []history.Attachment{{Name: "report.pdf"}}, ProviderID: "gmail-id", Outcome: history.Sent},
+		{Time: time.Now(), Profile: "work", Provider: "smtp", To: []string{"carol@example.com"}, Subject: "Hi",
+			Outcome: history.Failed, Error: "550 mailbox unavailable"},
+	}
+
+	var out bytes.Buffer
+	printLog(&out, records, false)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "TIME") {
+		t.Fatalf("table:\n%s", out.String())
+	}
+	if !strings.Contains(lines[1], "bob@example.com") || !strings.Contains(lines[1], "gmail-id") {
+		t.Errorf("sent row = %q", lines[1])
+	}
+	if !strings.Contains(lines[2], "failed") || !strings.Contains(lines[2], "550 mailbox unavailable") {
+		t.Errorf("failed row = %q", lines[2])
+	}
+
+	out.Reset()
+	printLog(&out, nil, false)
+	if out.String() != "No messages found.\n" {
+		t.Errorf("empty table = %q", out.String())
+	}
+
+	out.Reset()
+	printLog(&out, records, true)
+	var decoded []history.Record
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 2 || decoded[1].Error != "550 mailbox unavailable" {
+		t.Errorf("JSON = %s", out.String())
+	}
+
+	out.Reset()
+	printLog(&out, nil, true)
+	if strings.TrimSpace(out.String()) != "[]" {
+		t.Errorf("empty JSON = %q", out.String())
+	}
+}
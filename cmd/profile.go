@@ -0,0 +1,256 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/latocchi/gomailit/internal/config"
+	"github.com/latocchi/gomailit/internal/mail"
+	"github.com/latocchi/gomailit/internal/providers"
+	"github.com/latocchi/gomailit/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var (
+	profileName          string
+	profileFrom          string
+	profileSignature     string
+	profileSignatureFile string
+)
+
+// profileCmd represents the profile command
+var profileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Manage named profiles for sending from several accounts",
+	Long: `A profile is an account with its own provider, token, default From address
+and signature. Commands use the default profile unless --profile is given.
+
+Examples:
+
+Set up a profile for the billing account
+gomailit setup google --profile billing
+
+Give it a default From address and a signature
+gomailit profile set billing --from billing@example.com --signature-file billing-sig.txt
+
+Send from it
+gomailit send --profile billing --to customer@example.com --subject "Invoice"
+
+Use it when --profile is not given
+gomailit profile default billing
+`,
+}
+
+// profileListCmd represents the profile list command
+var profileListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List profiles",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadConfigFile()
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "\tNAME\tPROVIDER\tFROM")
+		for _, name := range cfg.ProfileNames() {
+			profile, _ := cfg.GetProfile(name)
+			marker := ""
+			if name == cfg.DefaultProfileName() {
+				marker = "*"
+			}
+			provider := profile.Provider
+			if provider == "" {
+				provider = "-"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", marker, name, provider, profile.From)
+		}
+		w.Flush()
+	},
+}
+
+// profileShowCmd represents the profile show command
+var profileShowCmd = &cobra.Command{
+	Use:   "show [name]",
+	Short: "Show the settings of a profile, the selected one by default",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadConfigFile()
+
+		name := utils.Profile()
+		if len(args) > 0 {
+			name = args[0]
+		}
+		profile := mustGetProfile(cfg, name)
+
+		fmt.Printf("Name:      %s\n", name)
+		fmt.Printf("Default:   %t\n", name == cfg.DefaultProfileName())
+		fmt.Printf("Provider:  %s\n", profile.Provider)
+		fmt.Printf("From:      %s\n", profile.From)
+		fmt.Printf("Directory: %s\n", utils.ProfileDir(name))
+		if profile.Signature != "" {
+			fmt.Printf("Signature:\n%s\n", profile.Signature)
+		}
+	},
+}
+
+// profileSetCmd represents the profile set command
+var profileSetCmd = &cobra.Command{
+	Use:   "set <name>",
+	Short: "Set the default From address and signature of a profile",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadConfigFile()
+		name := args[0]
+		profile := mustGetProfile(cfg, name)
+
+		if cmd.Flags().Changed("from") {
+			if profileFrom != "" {
+				if _, err := mail.ParseAddressList([]string{profileFrom}); err != nil {
+					fmt.Fprintf(os.Stderr, "Invalid --from: %v\n", err)
+					os.Exit(1)
+				}
+			}
+			profile.From = profileFrom
+		}
+		if cmd.Flags().Changed("signature") {
+			profile.Signature = profileSignature
+		}
+		if profileSignatureFile != "" {
+			data, err := os.ReadFile(profileSignatureFile)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Unable to read signature: %v\n", err)
+				os.Exit(1)
+			}
+			profile.Signature = strings.TrimRight(string(data), "\r\n")
+		}
+
+		cfg.SetProfile(name, profile)
+		if err := cfg.Save(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Profile %s updated.\n", name)
+	},
+}
+
+// profileDeleteCmd represents the profile delete command
+var profileDeleteCmd = &cobra.Command{
+	Use:   "delete <name>",
+	Short: "Delete a profile together with its token and settings",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadConfigFile()
+		name := args[0]
+		if name == config.Default {
+			fmt.Fprintln(os.Stderr, "The default profile cannot be deleted.")
+			os.Exit(1)
+		}
+		mustGetProfile(cfg, name)
+
+		// Select the profile so that its tokens are found wherever they are
+		// kept. The profile may have used other providers before its
+		// current one, so every provider's token is deleted.
+		utils.SetProfile(name)
+		if err := providers.DeleteTokens(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+		if err := os.RemoveAll(utils.ProfileDir(name)); err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to delete profile directory: %v\n", err)
+			os.Exit(1)
+		}
+
+		delete(cfg.Profiles, name)
+		if cfg.DefaultProfile == name {
+			cfg.DefaultProfile = ""
+		}
+		if err := cfg.Save(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Profile %s deleted.\n", name)
+	},
+}
+
+// profileDefaultCmd represents the profile default command
+var profileDefaultCmd = &cobra.Command{
+	Use:   "default <name>",
+	Short: "Use a profile when --profile is not given",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadConfigFile()
+		name := args[0]
+		mustGetProfile(cfg, name)
+
+		cfg.DefaultProfile = name
+		if name == config.Default {
+			cfg.DefaultProfile = ""
+		}
+		if err := cfg.Save(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Default profile set to %s.\n", name)
+	},
+}
+
+func mustGetProfile(cfg *config.Config, name string) *config.Profile {
+	profile, ok := cfg.GetProfile(name)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown profile %q, create it with 'gomailit setup <provider> --profile %s'\n", name, name)
+		os.Exit(1)
+	}
+	return profile
+}
+
+// selectProfile applies --profile, or the default profile, before any
+// command runs. Setup may name a profile that does not exist yet, which
+// creates it.
+func selectProfile(cmd *cobra.Command) {
+	if isSubcommandOf(cmd, configCmd) {
+		// The config commands must work even when the config is broken.
+		utils.SetProfile(profileName)
+		return
+	}
+	cfg := loadConfig()
+
+	name := profileName
+	if name == "" {
+		name = cfg.DefaultProfileName()
+	}
+	if err := config.ValidateProfileName(name); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if !isSubcommandOf(cmd, setupCmd) {
+		mustGetProfile(cfg, name)
+	}
+	utils.SetProfile(name)
+}
+
+func isSubcommandOf(cmd, parent *cobra.Command) bool {
+	for c := cmd; c != nil; c = c.Parent() {
+		if c == parent {
+			return true
+		}
+	}
+	return false
+}
+
+// activeProfile returns the settings of the selected profile.
+func activeProfile() *config.Profile {
+	return mustGetProfile(loadConfig(), utils.Profile())
+}
+
+func init() {
+	rootCmd.AddCommand(profileCmd)
+	profileCmd.AddCommand(profileListCmd, profileShowCmd, profileSetCmd, profileDeleteCmd, profileDefaultCmd)
+
+	profileSetCmd.Flags().StringVar(&profileFrom, "from", "", "Default sender address of the profile")
+	profileSetCmd.Flags().StringVar(&profileSignature, "signature", "", "Signature appended to every message")
+	profileSetCmd.Flags().StringVar(&profileSignatureFile, "signature-file", "", "Read the signature from a file")
+	profileSetCmd.MarkFlagsMutuallyExclusive("signature", "signature-file")
+}
@@ -0,0 +1,360 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/latocchi/gomailit/internal/config"
+	"github.com/latocchi/gomailit/internal/history"
+	"github.com/latocchi/gomailit/internal/mail"
+	"github.com/latocchi/gomailit/internal/mime"
+	"github.com/latocchi/gomailit/internal/outbox"
+	"github.com/latocchi/gomailit/internal/providers"
+	"github.com/latocchi/gomailit/internal/ratelimit"
+	"github.com/latocchi/gomailit/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var queueState string
+
+// queueCmd represents the queue command
+var queueCmd = &cobra.Command{
+	Use:   "queue",
+	Short: "Inspect and manage the outbox of queued and sent messages",
+	Long: `Every message 'gomailit send' sends goes through a durable outbox under
+the gomailit config directory, which records whether it is pending, sent or
+failed. Running an interrupted send again resumes it without sending any
+message twice. Once a send has sent all of its messages it is over, and
+running the same send again sends them again.
+
+Examples:
+
+List failed messages
+gomailit queue list --state failed
+
+Retry every failed message
+gomailit queue retry
+
+Send messages left pending by an interrupted send
+gomailit queue flush
+
+Remove sent messages from the outbox
+gomailit queue purge
+`,
+}
+
+// queueListCmd represents the queue list command
+var queueListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List messages in the outbox",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		box := openOutbox()
+
+		states, err := parseStates(queueState, nil)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		entries, err := box.List(states...)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to read outbox: %v\n", err)
+			os.Exit(1)
+		}
+		if len(entries) == 0 {
+			fmt.Println("Outbox is empty.")
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tSTATE\tATTEMPTS\tUPDATED\tTO\tSUBJECT\tERROR")
+		for _, entry := range entries {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\t%s\n",
+				entry.ID, entry.State, entry.Attempts, entry.UpdatedAt.Format(time.DateTime),
+				strings.Join(entry.Message.To, ", "), truncate(entry.Message.Subject, 40), truncate(entry.LastError, 60))
+		}
+		w.Flush()
+	},
+}
+
+// queueRetryCmd represents the queue retry command
+var queueRetryCmd = &cobra.Command{
+	Use:   "retry [id...]",
+	Short: "Send failed messages again, or only the given ones",
+	Run: func(cmd *cobra.Command, args []string) {
+		redeliver(cmd.Context(), args, outbox.Failed)
+	},
+}
+
+// queueFlushCmd represents the queue flush command
+var queueFlushCmd = &cobra.Command{
+	Use:   "flush",
+	Short: "Send messages left pending by an interrupted send",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		redeliver(cmd.Context(), nil, outbox.Pending)
+	},
+}
+
+// queuePurgeCmd represents the queue purge command
+var queuePurgeCmd = &cobra.Command{
+	Use:   "purge [id...]",
+	Short: "Remove messages from the outbox (sent ones by default)",
+	Run: func(cmd *cobra.Command, args []string) {
+		box := openOutbox()
+
+		if len(args) > 0 {
+			for _, id := range args {
+				if err := box.Delete(id); err != nil {
+					fmt.Fprintf(os.Stderr, "Unable to remove %s: %v\n", id, err)
+					os.Exit(1)
+				}
+			}
+			fmt.Printf("Removed %d messages.\n", len(args))
+			return
+		}
+
+		states, err := parseStates(queueState, []outbox.State{outbox.Sent})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		n, err := box.Purge(states...)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to purge outbox: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Removed %d messages.\n", n)
+	},
+}
+
+func openOutbox() *outbox.Outbox {
+	box, err := outbox.Open(utils.OutboxDir())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	return box
+}
+
+// redeliver sends the entries with the given IDs, or every entry in state
+// when no IDs are given.
+func redeliver(ctx context.Context, ids []string, state outbox.State) {
+	box := openOutbox()
+
+	var entries []*outbox.Entry
+	if len(ids) > 0 {
+		for _, id := range ids {
+			entry, err := box.Get(id)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Unable to find %s in the outbox: %v\n", id, err)
+				os.Exit(1)
+			}
+			if entry.State == outbox.Sent {
+				fmt.Printf("%s was already sent, skipping.\n", id)
+				continue
+			}
+			entries = append(entries, entry)
+		}
+	} else {
+		var err error
+		entries, err = box.List(state)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to read outbox: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if len(entries) == 0 {
+		fmt.Printf("No %s messages.\n", state)
+		return
+	}
+	if deliver(ctx, box, entries) > 0 {
+		os.Exit(1)
+	}
+}
+
+// deliver sends outbox entries through their providers, as many at a time
+// as the concurrency setting allows, and returns how many were not sent.
+func deliver(ctx context.Context, box *outbox.Outbox, entries []*outbox.Entry) int {
+	senders := map[string]outbox.Sender{}
+	for _, entry := range entries {
+		if _, ok := senders[entry.Provider]; ok {
+			continue
+		}
+		provider, err := providers.Get(ctx, entry.Provider)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to load provider: %v\n", err)
+			os.Exit(1)
+		}
+		senders[entry.Provider] = &limitedSender{provider, rateLimiter(entry.Provider)}
+	}
+
+	policy := outbox.DefaultRetryPolicy(providers.IsTransient)
+	policy.RetryAfter = providers.RetryAfter
+	policy.Postpone = providers.IsQuotaExceeded
+
+	// Once the daily limit is reached the remaining messages stay pending
+	// instead of failing one by one.
+	var quotaErr error
+
+	sem := make(chan struct{}, loadConfig().ConcurrencyOrDefault())
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	unsent := 0
+
+	for _, entry := range entries {
+		mu.Lock()
+		stopped := quotaErr != nil
+		mu.Unlock()
+		if ctx.Err() != nil || stopped {
+			unsent++
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(entry *outbox.Entry) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			recipient := strings.Join(entry.Message.To, ", ")
+			sender := senders[entry.Provider]
+			if err := box.Deliver(ctx, sender, entry, policy); err != nil {
+				mu.Lock()
+				unsent++
+				if providers.IsQuotaExceeded(err) && quotaErr == nil {
+					quotaErr = err
+				}
+				mu.Unlock()
+				if entry.State == outbox.Pending {
+					return
+				}
+				recordSend(history.FromMessage(entry.Message), entry.Provider, sender, "", err)
+				fmt.Printf("Failed to send email to %s: %v\n", recipient, err)
+			} else {
+				recordSend(history.FromMessage(entry.Message), entry.Provider, sender, entry.ProviderID, nil)
+				fmt.Printf("Email sent to %s successfully.\n", recipient)
+			}
+		}(entry)
+	}
+	wg.Wait()
+
+	if err := box.EndRuns(entries); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: unable to update outbox: %v\n", err)
+	}
+	if quotaErr != nil {
+		fmt.Printf("Stopped: %v. %d messages left pending, run 'gomailit queue flush' once the limit resets to send them.\n", quotaErr, unsent)
+	} else if ctx.Err() != nil {
+		fmt.Printf("Interrupted, %d messages left unsent. Run the same command again or 'gomailit queue flush' to resume.\n", unsent)
+	}
+	return unsent
+}
+
+var (
+	limitersMu sync.Mutex
+	limiters   = map[string]*ratelimit.Limiter{}
+)
+
+// rateLimiter returns the limiter of a provider from the rate_limits
+// setting, shared by every send of this run.
+func rateLimiter(name string) *ratelimit.Limiter {
+	r, err := providers.Lookup(name)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+	if limiter, ok := limiters[r.Name]; ok {
+		return limiter
+	}
+
+	// Limits may be keyed by an alias of the provider.
+	limit := &config.RateLimit{}
+	for key, value := range loadConfig().RateLimits {
+		if other, err := providers.Lookup(key); err == nil && other.Name == r.Name && value != nil {
+			limit = value
+		}
+	}
+	limiter := ratelimit.New(limit.PerSecond, limit.PerDay, ratelimit.NewCounter(utils.SendCountPath()), r.Name)
+	limiters[r.Name] = limiter
+	return limiter
+}
+
+// limitedSender keeps a provider within its rate limits, and holds back
+// every send through it when the provider asks to slow down.
+type limitedSender struct {
+	outbox.Sender
+	limiter *ratelimit.Limiter
+}
+
+func (s *limitedSender) Send(ctx context.Context, msg *mail.Message) (string, error) {
+	if err := s.limiter.Wait(ctx); err != nil {
+		return "", err
+	}
+	id, err := s.Sender.Send(ctx, msg)
+	if d := providers.RetryAfter(err); d > 0 {
+		s.limiter.Pause(d)
+	}
+	s.limiter.Done(err == nil)
+	return id, err
+}
+
+// limitedRawSender is limitedSender for pre-built messages.
+type limitedRawSender struct {
+	providers.RawSender
+	limiter *ratelimit.Limiter
+}
+
+func (s *limitedRawSender) SendRaw(ctx context.Context, env mime.Envelope, raw []byte) (string, error) {
+	if err := s.limiter.Wait(ctx); err != nil {
+		return "", err
+	}
+	id, err := s.RawSender.SendRaw(ctx, env, raw)
+	if d := providers.RetryAfter(err); d > 0 {
+		s.limiter.Pause(d)
+	}
+	s.limiter.Done(err == nil)
+	return id, err
+}
+
+// parseStates parses a --state value, returning fallback when it is empty.
+func parseStates(value string, fallback []outbox.State) ([]outbox.State, error) {
+	switch value {
+	case "":
+		return fallback, nil
+	case "all":
+		return nil, nil
+	case string(outbox.Pending), string(outbox.Sent), string(outbox.Failed):
+		return []outbox.State{outbox.State(value)}, nil
+	default:
+		return nil, fmt.Errorf("unknown state %q, expected pending, sent, failed or all", value)
+	}
+}
+
+func truncate(s string, n int) string {
+	s = strings.ReplaceAll(s, "\n", " ")
+	if len([]rune(s)) <= n {
+		return s
+	}
+	return string([]rune(s)[:n-1]) + "…"
+}
+
+func init() {
+	rootCmd.AddCommand(queueCmd)
+	queueCmd.AddCommand(queueListCmd, queueRetryCmd, queueFlushCmd, queuePurgeCmd)
+
+	queueListCmd.Flags().StringVar(&queueState, "state", "", "Only list messages in this state: pending, sent or failed")
+	queuePurgeCmd.Flags().StringVar(&queueState, "state", "", "Remove messages in this state: pending, sent, failed or all (default sent)")
+}
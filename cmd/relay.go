@@ -0,0 +1,137 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"time"
+
+	"github.com/latocchi/gomailit/internal/mime"
+	"github.com/latocchi/gomailit/internal/providers"
+	"github.com/latocchi/gomailit/internal/smtpd"
+	"github.com/spf13/cobra"
+)
+
+var (
+	relayListen   string
+	relayUsers    string
+	relayHostname string
+	relayMaxSize  int64
+	relayTLSCert  string
+	relayTLSKey   string
+)
+
+// relayCmd represents the relay command
+var relayCmd = &cobra.Command{
+	Use:   "relay",
+	Short: "Runs a local SMTP server that forwards mail through the configured provider",
+	Long: `Accepts SMTP submissions from local applications and forwards each message
+through the configured provider, so that apps which only speak SMTP can use
+the Gmail OAuth setup.
+
+Examples:
+
+Listen on the default address, 127.0.0.1:2525
+gomailit relay
+
+Require AUTH PLAIN or LOGIN with a username:password per line
+gomailit relay --users ~/.config/gomailit/relay-users
+
+Listen on the network, which requires --users and STARTTLS; AUTH is only
+offered after STARTTLS unless the client is on this machine
+gomailit relay --listen 0.0.0.0:587 --users relay-users --tls-cert cert.pem --tls-key key.pem
+`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+
+		server := &smtpd.Server{
+			Hostname:  relayHostname,
+			MaxSize:   relayMaxSize,
+			Temporary: providers.IsTemporary,
+			Timeout:   5 * time.Minute,
+			Logf:      log.Printf,
+		}
+
+		if relayUsers != "" {
+			credentials, err := smtpd.LoadCredentials(relayUsers)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			server.Authenticate = credentials.Check
+		} else if !isLoopback(relayListen) {
+			fmt.Fprintf(os.Stderr, "Refusing to run an open relay on %s, use --users to require authentication.\n", relayListen)
+			os.Exit(1)
+		}
+
+		if relayTLSCert != "" || relayTLSKey != "" {
+			cert, err := tls.LoadX509KeyPair(relayTLSCert, relayTLSKey)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Unable to load TLS certificate: %v\n", err)
+				os.Exit(1)
+			}
+			server.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+		}
+		if relayUsers != "" && server.TLSConfig == nil && !isLoopback(relayListen) {
+			fmt.Fprintf(os.Stderr, "Refusing to accept passwords in cleartext on %s, use --tls-cert and --tls-key.\n", relayListen)
+			os.Exit(1)
+		}
+
+		// The provider is loaded once so that every message reuses it.
+		rawSender, err := activeRawSender(ctx)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		server.Handler = func(ctx context.Context, env mime.Envelope, raw []byte) error {
+			_, err := sendRaw(ctx, rawSender, env, raw)
+			return err
+		}
+
+		ln, err := net.Listen("tcp", relayListen)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to listen on %s: %v\n", relayListen, err)
+			os.Exit(1)
+		}
+		log.Printf("Relaying mail from %s through %s", ln.Addr(), providers.ActiveProvider())
+
+		if err := server.Serve(ctx, ln); err != nil {
+			fmt.Fprintf(os.Stderr, "Relay stopped: %v\n", err)
+			os.Exit(1)
+		}
+		log.Printf("Relay stopped")
+	},
+}
+
+// isLoopback reports whether a listen address only accepts local
+// connections.
+func isLoopback(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func init() {
+	rootCmd.AddCommand(relayCmd)
+
+	relayCmd.Flags().StringVar(&relayListen, "listen", "127.0.0.1:2525", "Address to accept SMTP connections on")
+	relayCmd.Flags().StringVar(&relayUsers, "users", "", "File of username:password lines; clients must authenticate when given")
+	relayCmd.Flags().StringVar(&relayHostname, "hostname", hostname(), "Host name announced to clients")
+	relayCmd.Flags().Int64Var(&relayMaxSize, "max-size", 25<<20, "Largest accepted message in bytes")
+	relayCmd.Flags().StringVar(&relayTLSCert, "tls-cert", "", "Certificate file enabling STARTTLS")
+	relayCmd.Flags().StringVar(&relayTLSKey, "tls-key", "", "Private key file for --tls-cert")
+	relayCmd.MarkFlagsRequiredTogether("tls-cert", "tls-key")
+}
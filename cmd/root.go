@@ -4,8 +4,14 @@ Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
 package cmd
 
 import (
+	"context"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
 
+	"github.com/latocchi/gomailit/internal/config"
+	"github.com/latocchi/gomailit/internal/providers"
 	"github.com/spf13/cobra"
 )
 
@@ -13,6 +19,15 @@ import (
 var rootCmd = &cobra.Command{
 	Use:   "gomailit",
 	Short: "A command-line tool that allows user to send email via terminal",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if cfgFile != "" {
+			config.SetPath(cfgFile)
+		}
+		if credentialsFile != "" {
+			providers.SetGoogleCredentialsPath(credentialsFile)
+		}
+		selectProfile(cmd)
+	},
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	// Run: func(cmd *cobra.Command, args []string) { },
@@ -21,20 +36,29 @@ var rootCmd = &cobra.Command{
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	err := rootCmd.Execute()
+	// Interrupting a send cancels the context so that unsent messages stay
+	// pending in the outbox and can be resumed.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Installed as (or linked to) sendmail, behave as 'gomailit sendmail'.
+	if filepath.Base(os.Args[0]) == "sendmail" {
+		rootCmd.SetArgs(append([]string{sendmailCmd.Name()}, os.Args[1:]...))
+	}
+
+	err := rootCmd.ExecuteContext(ctx)
 	if err != nil {
 		os.Exit(1)
 	}
 }
 
-func init() {
-	// Here you will define your flags and configuration settings.
-	// Cobra supports persistent flags, which, if defined here,
-	// will be global for your application.
-
-	// rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.Go-Mail-It.yaml)")
+var (
+	cfgFile         string
+	credentialsFile string
+)
 
-	// Cobra also supports local flags, which will only run
-	// when this action is called directly.
-	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+func init() {
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "Config file (default is config.toml in the gomailit config directory)")
+	rootCmd.PersistentFlags().StringVar(&credentialsFile, "credentials", "", "Google OAuth client secret file (default is $GOMAILIT_GOOGLE_CREDENTIALS, then credentials.json in the gomailit config directory)")
+	rootCmd.PersistentFlags().StringVar(&profileName, "profile", "", "Profile to use (default is the profile set with 'gomailit profile default')")
 }
@@ -6,22 +6,42 @@ package cmd
 import (
 	"bufio"
 	"fmt"
-	"log"
+	"html"
+	"io"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
-	"sync"
 
+	"github.com/latocchi/gomailit/internal/mail"
+	"github.com/latocchi/gomailit/internal/mime"
+	"github.com/latocchi/gomailit/internal/outbox"
 	"github.com/latocchi/gomailit/internal/providers"
+	"github.com/latocchi/gomailit/internal/render"
 	"github.com/latocchi/gomailit/internal/utils"
 	"github.com/spf13/cobra"
 )
 
 var (
 	to          string
+	from        string
+	cc          []string
+	bcc         []string
+	replyTo     []string
 	body        string
+	htmlFile    string
+	markdown    string
 	subject     string
 	attachments []string
 	recipients  []string
+
+	dataFile      string
+	attachColumns []string
+	preview       int
+	force         bool
+	dryRun        bool
+	emlDir        string
+	noSignature   bool
 )
 
 // sendCmd represents the send command
@@ -36,6 +56,11 @@ gomailit send --from alice@example.com --to bob@example.com \
 
 Examples:
 
+Send with Cc, Bcc and Reply-To
+gomailit send --to bob@example.com --cc carol@example.com --cc dave@example.com \
+	--bcc audit@example.com --reply-to support@example.com \
+	--subject "Hello" --body "This is a test"
+
 Send with attachment
 gomailit send --to bob@example.com --subject "Files" --body "See attached" \
 	--attach report.pdf
@@ -52,6 +77,12 @@ Use file for email body
 gomailit send --to bob@example.com --subject "Files" --body ~/Documents/body.txt \
 	--attach ~/Documents/report/*
 
+Send an HTML body, embedding local images referenced by <img src="...">
+gomailit send --to bob@example.com --subject "Newsletter" --html newsletter.html
+
+Send a Markdown body as HTML with a plain-text alternative
+gomailit send --to bob@example.com --subject "Notes" --markdown notes.md
+
 Send to multiple recipients via .txt file
 gomailit send --to ~/Documents/recipients.txt --subject "Files" \ 
 	--body ~/Documents/body.txt --attach ~/Documents/report/*
@@ -63,105 +94,444 @@ recipient2@example.com
 recipient3@example.com
 recipient4@example.com
 recipient5@example.com
+
+Personalised mail merge from a CSV file (recipients come from the Email
+column unless --to is given as a template)
+gomailit send --data customers.csv --subject "Invoice for {{.FirstName}}" \
+	--html invoice.html --attach-column Invoice
+
+Preview the first 3 messages of a mail merge without sending
+gomailit send --data customers.csv --subject "Hi {{.FirstName}}" \
+	--body "Your invoice: {{.InvoiceURL}}" --preview 3
+
+Print the exact message that would be sent, without sending it
+gomailit send --to bob@example.com --subject "Hello" --html newsletter.html --dry-run
+
+Write one .eml file per recipient for review instead of sending
+gomailit send --to ~/Documents/recipients.txt --subject "Files" \
+	--body ~/Documents/body.txt --output-eml ./out
+
+Resume an interrupted bulk send; messages it already sent are skipped (a
+send that finished is sent again in full)
+gomailit send --to ~/Documents/recipients.txt --subject "Files" \
+	--body ~/Documents/body.txt
+
+Example contents of customers.csv file:
+Email,FirstName,InvoiceURL,Invoice
+alice@example.com,Alice,https://example.com/i/1,invoices/1.pdf
+bob@example.com,Bob,https://example.com/i/2,invoices/2.pdf
 `,
 	Run: func(cmd *cobra.Command, args []string) {
-		if !utils.IsFile(utils.TokenPath()) {
-			fmt.Println("No token found, please run 'gomailit setup google' first to set up the Google provider.")
+		ctx := cmd.Context()
+
+		if to == "" && dataFile == "" {
+			fmt.Fprintln(os.Stderr, "Either --to or --data is required")
 			os.Exit(1)
 		}
 
-		srv, err := providers.GetGoogleService()
+		ccList, err := mail.ParseAddressList(cc)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Unable to get google mail service: %v", err)
+			fmt.Fprintf(os.Stderr, "Invalid --cc: %v\n", err)
+			os.Exit(1)
 		}
-
-		profile, err := srv.Users.GetProfile("me").Do()
+		bccList, err := mail.ParseAddressList(bcc)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Unable to get user profile: %v", err)
+			fmt.Fprintf(os.Stderr, "Invalid --bcc: %v\n", err)
+			os.Exit(1)
+		}
+		replyToList, err := mail.ParseAddressList(replyTo)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid --reply-to: %v\n", err)
+			os.Exit(1)
+		}
+		profile := activeProfile()
+		if from == "" {
+			from = profile.From
+		}
+		if from != "" {
+			if _, err := mail.ParseAddressList([]string{from}); err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid --from: %v\n", err)
+				os.Exit(1)
+			}
 		}
-
-		fmt.Printf("Sending email as %s\n", profile.EmailAddress)
 
 		if cmd.Flags().Changed("attach") {
 			attachments = args
 		}
 
-		// fmt.Println(attachments)
-		if len(attachments) > 0 {
-			// Go through attachments and remove any that do not exist
-			for index, path := range attachments {
-				// if file does not exist, remove from attachments slice
-				fmt.Println("Checking attachment:", path)
-				if !utils.FileExists(path) {
-					fmt.Printf("Attachment file not found: %s\n", path)
-					attachments = append(attachments[:index], attachments[index+1:]...)
-					break
-				}
-
+		// Leave out attachments that do not exist.
+		found := attachments[:0]
+		for _, path := range attachments {
+			fmt.Println("Checking attachment:", path)
+			if !utils.FileExists(path) {
+				fmt.Fprintf(os.Stderr, "Attachment file not found: %s\n", path)
+				continue
 			}
+			found = append(found, path)
+		}
+		attachments = found
+
+		files, err := mail.LoadAttachments(attachments)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
 
 		if utils.FileExists(body) {
 			data, err := os.ReadFile(body)
 			if err != nil {
-				panic(err)
+				fmt.Fprintf(os.Stderr, "Unable to read body file: %v\n", err)
+				os.Exit(1)
 			}
 			body = string(data)
 		}
 
-		// Check if 'to' is a file with multiple recipients
-		if utils.FileExists(to) {
-			file, err := os.Open(to)
-			if err != nil {
-				panic(err)
-			}
+		var messages []*mail.Message
+		if dataFile != "" {
+			messages, err = mergeMessages(cmd)
+		} else {
+			messages, err = plainMessages(cmd)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
 
-			defer file.Close()
+		for _, msg := range messages {
+			msg.From = from
+			msg.Cc = ccList
+			msg.Bcc = bccList
+			msg.ReplyTo = replyToList
+			msg.Attachments = append(msg.Attachments, files...)
+			if !noSignature {
+				addSignature(msg, profile.Signature)
+			}
+		}
 
-			scanner := bufio.NewScanner(file)
-			for scanner.Scan() {
-				line := strings.TrimSpace(scanner.Text())
-				if line != "" {
-					recipients = append(recipients, line)
+		if preview > 0 {
+			for i, msg := range messages {
+				if i == preview {
+					break
 				}
+				printPreview(i+1, msg)
 			}
+			return
+		}
 
-			if err := scanner.Err(); err != nil {
-				log.Fatalf("failed to read file: %v", err)
+		if dryRun || emlDir != "" {
+			if err := exportMessages(messages); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
 			}
+			return
+		}
 
-			sem := make(chan struct{}, 5) // limit to 5 concurrent goroutines
-			var wg sync.WaitGroup
+		name := providers.ActiveProvider()
+		provider, err := providers.Get(ctx, name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to load provider: %v\n", err)
+			os.Exit(1)
+		}
 
-			for _, recipient := range recipients {
-				// Should i use goroutines here?
-				wg.Add(1)
-				sem <- struct{}{}
+		if from != "" {
+			if validator, ok := provider.(providers.SenderValidator); ok {
+				if err := validator.ValidateFrom(ctx, from); err != nil {
+					fmt.Fprintf(os.Stderr, "Invalid --from: %v\n", err)
+					os.Exit(1)
+				}
+			}
+			fmt.Printf("Sending email as %s\n", from)
+		} else if reporter, ok := provider.(providers.AccountReporter); ok {
+			account, err := reporter.Account(ctx)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			fmt.Printf("Sending email as %s\n", account)
+		} else {
+			fmt.Printf("Sending email via %s\n", name)
+		}
 
-				go func(recipient string) {
-					defer wg.Done()
-					defer func() { <-sem }()
+		box := openOutbox()
 
-					if err := providers.SendEmailGMail(recipient, subject, body, attachments); err != nil {
-						fmt.Printf("Failed to send email to %s: %v\n", recipient, err)
-					} else {
-						fmt.Printf("Email sent to %s successfully.\n", recipient)
-					}
-				}(recipient)
+		// Running the same send again resumes it when it was interrupted,
+		// skipping the messages it already sent.
+		run := outbox.RunID(name, messages)
+		var entries []*outbox.Entry
+		queued := map[string]bool{}
+		skipped := 0
+		for _, msg := range messages {
+			entry, existing, err := box.Enqueue(run, name, msg, mime.NewMessageID(msg.From))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Unable to queue message: %v\n", err)
+				os.Exit(1)
 			}
-			wg.Wait()
-			fmt.Println("All emails sent.")
-		} else { // Single recipient
-			if err := providers.SendEmailGMail(to, subject, body, attachments); err != nil {
-				fmt.Printf("Failed to send email to %s: %v\n", to, err)
-			} else {
-				fmt.Printf("Email sent to %s successfully.\n", to)
+			// A recipient listed twice gets the same entry, which must
+			// be delivered only once.
+			if queued[entry.ID] {
+				fmt.Fprintf(os.Stderr, "Skipping email to %s: it is listed more than once.\n", strings.Join(msg.To, ", "))
+				skipped++
+				continue
+			}
+			queued[entry.ID] = true
+			if existing && entry.State == outbox.Sent && !force {
+				fmt.Fprintf(os.Stderr, "Skipping email to %s: it was already sent before this send was interrupted (use --force to send it again).\n", strings.Join(msg.To, ", "))
+				skipped++
+				continue
 			}
+			if existing && entry.State == outbox.Sent {
+				entry.State = outbox.Pending
+			}
+			entries = append(entries, entry)
 		}
 
+		unsent := deliver(ctx, box, entries)
+		if skipped > 0 {
+			fmt.Fprintf(os.Stderr, "%d of %d emails were skipped, %d sent now.\n", skipped, len(messages), len(entries)-unsent)
+		} else if len(messages) > 1 && unsent == 0 {
+			fmt.Println("All emails sent.")
+		}
+		if unsent > 0 || len(entries) == 0 {
+			os.Exit(1)
+		}
 	},
 }
 
+// plainMessages builds one message per recipient of --to, which is either a
+// single address or a .txt file with one address per line.
+func plainMessages(cmd *cobra.Command) ([]*mail.Message, error) {
+	htmlBody, baseDir, err := loadHTMLBody()
+	if err != nil {
+		return nil, err
+	}
+
+	textBody := body
+	var images []mail.Attachment
+	if htmlBody != "" {
+		// Without an explicit --body the plain-text alternative is
+		// generated from the HTML.
+		if !cmd.Flags().Changed("body") {
+			textBody = render.HTMLToText(htmlBody)
+		}
+
+		htmlBody, images, err = render.EmbedImages(htmlBody, baseDir)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Check if 'to' is a file with multiple recipients
+	if utils.FileExists(to) {
+		file, err := os.Open(to)
+		if err != nil {
+			return nil, err
+		}
+
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line != "" {
+				recipients = append(recipients, line)
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read file: %v", err)
+		}
+	} else {
+		recipients = []string{to}
+	}
+
+	messages := make([]*mail.Message, 0, len(recipients))
+	for _, recipient := range recipients {
+		messages = append(messages, &mail.Message{
+			To:          []string{recipient},
+			Subject:     subject,
+			TextBody:    textBody,
+			HTMLBody:    htmlBody,
+			Attachments: slices.Clone(images),
+		})
+	}
+	return messages, nil
+}
+
+// mergeMessages renders one message per row of the --data file, using the
+// recipient, subject and body flags as templates.
+func mergeMessages(cmd *cobra.Command) ([]*mail.Message, error) {
+	rows, err := render.ReadCSV(dataFile)
+	if err != nil {
+		return nil, err
+	}
+
+	options := render.MergeOptions{
+		To:            to,
+		Subject:       subject,
+		AttachColumns: attachColumns,
+		DataDir:       filepath.Dir(dataFile),
+	}
+	if options.To == "" {
+		options.To = "{{.Email}}"
+	}
+
+	switch {
+	case htmlFile != "":
+		options.HTML, err = readBodySource(htmlFile)
+		options.BodyDir = filepath.Dir(htmlFile)
+	case markdown != "":
+		options.Markdown, err = readBodySource(markdown)
+		options.BodyDir = filepath.Dir(markdown)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Without an explicit --body the plain-text alternative of an HTML
+	// body is generated from the HTML.
+	if options.HTML == "" && options.Markdown == "" || cmd.Flags().Changed("body") {
+		options.Text = body
+	}
+
+	merge, err := render.NewMerge(options)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]*mail.Message, 0, len(rows))
+	for i, row := range rows {
+		msg, err := merge.Render(row)
+		if err != nil {
+			// Line 1 holds the column names.
+			return nil, fmt.Errorf("%s line %d: %v", dataFile, i+2, err)
+		}
+		messages = append(messages, msg)
+	}
+	return messages, nil
+}
+
+// printPreview writes a readable rendering of a message to stdout.
+func printPreview(n int, msg *mail.Message) {
+	fmt.Printf("----- Message %d -----\n", n)
+	fmt.Printf("To: %s\n", strings.Join(msg.To, ", "))
+	if len(msg.Cc) > 0 {
+		fmt.Printf("Cc: %s\n", strings.Join(msg.Cc, ", "))
+	}
+	if len(msg.Bcc) > 0 {
+		fmt.Printf("Bcc: %s\n", strings.Join(msg.Bcc, ", "))
+	}
+	fmt.Printf("Subject: %s\n", msg.Subject)
+	for _, attachment := range msg.Attachments {
+		if attachment.ContentID == "" {
+			fmt.Printf("Attachment: %s (%d bytes)\n", attachment.Filename, len(attachment.Data))
+		}
+	}
+	fmt.Println()
+	fmt.Println(strings.TrimRight(msg.TextBody, "\n"))
+	fmt.Println()
+}
+
+// exportMessages renders every message as RFC 5322 and prints it (--dry-run)
+// or writes it to a .eml file (--output-eml) instead of sending it. Bcc is
+// kept in the rendering so that every recipient can be reviewed.
+func exportMessages(messages []*mail.Message) error {
+	builder := &mime.Builder{IncludeBcc: true}
+
+	if emlDir != "" {
+		if err := os.MkdirAll(emlDir, 0755); err != nil {
+			return fmt.Errorf("unable to create output directory: %v", err)
+		}
+	}
+
+	for i, msg := range messages {
+		raw, err := builder.Build(msg)
+		if err != nil {
+			return fmt.Errorf("unable to build message to %s: %v", strings.Join(msg.To, ", "), err)
+		}
+
+		if dryRun {
+			if len(messages) > 1 {
+				fmt.Printf("----- Message %d -----\n", i+1)
+			}
+			os.Stdout.Write(raw)
+			fmt.Println()
+		}
+
+		if emlDir != "" {
+			path := filepath.Join(emlDir, emlFilename(i+1, msg))
+			if err := os.WriteFile(path, raw, 0644); err != nil {
+				return fmt.Errorf("unable to write %s: %v", path, err)
+			}
+			fmt.Printf("Wrote %s\n", path)
+		}
+	}
+	return nil
+}
+
+// emlFilename returns a file name such as 001-bob_example.com.eml.
+func emlFilename(n int, msg *mail.Message) string {
+	recipient := "message"
+	if len(msg.To) > 0 {
+		recipient = mail.BareAddress(msg.To[0])
+	}
+	safe := strings.Map(func(r rune) rune {
+		if r == '.' || r == '-' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' {
+			return r
+		}
+		return '_'
+	}, recipient)
+	return fmt.Sprintf("%03d-%s.eml", n, safe)
+}
+
+// addSignature appends the profile signature to the plain-text and HTML
+// bodies.
+func addSignature(msg *mail.Message, signature string) {
+	if signature == "" {
+		return
+	}
+	if msg.TextBody != "" {
+		msg.TextBody = strings.TrimRight(msg.TextBody, "\r\n") + "\n\n" + signature + "\n"
+	}
+	if msg.HTMLBody != "" {
+		escaped := strings.ReplaceAll(html.EscapeString(signature), "\n", "<br>\n")
+		block := "<br><br>\n<div class=\"signature\">" + escaped + "</div>\n"
+		if i := strings.LastIndex(strings.ToLower(msg.HTMLBody), "</body>"); i >= 0 {
+			msg.HTMLBody = msg.HTMLBody[:i] + block + msg.HTMLBody[i:]
+		} else {
+			msg.HTMLBody += block
+		}
+	}
+}
+
+// readBodySource reads a body file, or stdin when path is '-'.
+func readBodySource(path string) (string, error) {
+	if path == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", fmt.Errorf("unable to read body from stdin: %v", err)
+		}
+		return string(data), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("unable to read body: %v", err)
+	}
+	return string(data), nil
+}
+
+// loadHTMLBody reads the --html or --markdown body and returns it as HTML,
+// together with the directory that relative image paths are resolved from.
+func loadHTMLBody() (string, string, error) {
+	switch {
+	case htmlFile != "":
+		source, err := readBodySource(htmlFile)
+		return source, filepath.Dir(htmlFile), err
+	case markdown != "":
+		source, err := readBodySource(markdown)
+		return render.MarkdownToHTML(source), filepath.Dir(markdown), err
+	}
+	return "", "", nil
+}
+
 func init() {
 	rootCmd.AddCommand(sendCmd)
 
@@ -175,12 +545,22 @@ func init() {
 	// is called directly, e.g.:
 	// sendCmd.Flags().BoolP("toggle", "p", false, "Help message for toggle")
 
-	sendCmd.Flags().StringVarP(&to, "to", "t", "", "Recipient (single email or .txt file with one address per line)")
+	sendCmd.Flags().StringVarP(&to, "to", "t", "", "Recipient (single email or .txt file with one address per line), required unless --data is set")
+	sendCmd.Flags().StringVarP(&from, "from", "f", "", "Sender address, e.g. 'Alice <alice@example.com>' (Gmail: must be a send-as alias)")
+	sendCmd.Flags().StringArrayVar(&cc, "cc", nil, "Cc recipient, can be repeated or comma-separated")
+	sendCmd.Flags().StringArrayVar(&bcc, "bcc", nil, "Bcc recipient, can be repeated or comma-separated")
+	sendCmd.Flags().StringArrayVar(&replyTo, "reply-to", nil, "Reply-To address, can be repeated or comma-separated")
 	sendCmd.Flags().StringVarP(&body, "body", "b", "No body", "Body of the email, can be '-' for stdin or a .txt file path (default \"No body\")")
+	sendCmd.Flags().StringVar(&htmlFile, "html", "", "HTML body file, or '-' for stdin; a plain-text alternative is generated unless --body is set")
+	sendCmd.Flags().StringVar(&markdown, "markdown", "", "Markdown body file, sent as HTML with a plain-text alternative")
+	sendCmd.MarkFlagsMutuallyExclusive("html", "markdown")
 	sendCmd.Flags().StringVarP(&subject, "subject", "s", "No subject", "Subject of the email (default \"No subject\")")
 	sendCmd.Flags().BoolP("attach", "a", false, "One or more attachment files, or a directory path")
-	if err := sendCmd.MarkFlagRequired("to"); err != nil {
-		panic(err)
-	}
-
+	sendCmd.Flags().StringVar(&dataFile, "data", "", "CSV file for a mail merge; --to, --subject and the body become templates such as {{.FirstName}}")
+	sendCmd.Flags().StringArrayVar(&attachColumns, "attach-column", nil, "CSV column holding per-row attachment paths separated by ';', can be repeated")
+	sendCmd.Flags().IntVar(&preview, "preview", 0, "Print the first N messages instead of sending")
+	sendCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print the fully rendered RFC 5322 messages instead of sending")
+	sendCmd.Flags().StringVar(&emlDir, "output-eml", "", "Write one .eml file per recipient to this directory instead of sending")
+	sendCmd.Flags().BoolVar(&noSignature, "no-signature", false, "Do not append the profile signature")
+	sendCmd.Flags().BoolVar(&force, "force", false, "Send messages again that an interrupted run of the same send already sent")
 }
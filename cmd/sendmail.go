@@ -0,0 +1,342 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/latocchi/gomailit/internal/mail"
+	"github.com/latocchi/gomailit/internal/mime"
+	"github.com/latocchi/gomailit/internal/providers"
+	"github.com/latocchi/gomailit/internal/smtpd"
+	"github.com/spf13/cobra"
+)
+
+// Exit codes from sysexits.h, which callers of sendmail look at.
+const (
+	exUsage       = 64
+	exDataErr     = 65
+	exUnavailable = 69
+	exTempFail    = 75
+)
+
+// sendmailOptions are the sendmail flags gomailit understands.
+type sendmailOptions struct {
+	// readHeaders is -t: take the recipients from the To, Cc and Bcc headers.
+	readHeaders bool
+	// ignoreDots is -i / -oi: a line with a single dot does not end the
+	// message.
+	ignoreDots bool
+	// sender is -f / -r: the envelope sender.
+	sender string
+	// fullName is -F: the sender's name, used when the message has no From.
+	fullName string
+	// smtpSession is -bs: speak SMTP on stdin and stdout.
+	smtpSession bool
+	recipients  []string
+}
+
+// sendmailCmd represents the sendmail command
+var sendmailCmd = &cobra.Command{
+	Use:   "sendmail [flags] [recipient...]",
+	Short: "Sendmail-compatible interface for cron, git and other local tools",
+	Long: `Reads a message from stdin and delivers it through the configured provider,
+accepting the usual sendmail flags:
+
+  -t        Read the recipients from the To, Cc and Bcc headers
+  -i, -oi   Do not treat a line with a single dot as the end of the message
+  -f addr   Envelope sender (also -faddr and -r addr)
+  -F name   Sender's full name, used when the message has no From header
+  -bs       Speak SMTP on stdin and stdout
+  -bm       Read the message from stdin (the default)
+
+Single-letter flags may be grouped, as in -ti. Other -o options and delivery
+flags such as -odi, -oem and -v are accepted and ignored. gomailit's own
+--profile, --config and --credentials may be given among them.
+
+When gomailit is invoked through a link named 'sendmail' it behaves as
+'gomailit sendmail', so it can replace /usr/sbin/sendmail:
+
+ln -s "$(command -v gomailit)" /usr/sbin/sendmail
+
+Examples:
+
+printf 'Subject: backup done\n\nAll good.\n' | gomailit sendmail -i admin@example.com
+gomailit sendmail -ti < message.eml
+gomailit sendmail --profile alerts -t -oi < message.eml
+`,
+	DisableFlagParsing: true,
+	// The root's setup needs --config and --profile, which are only known
+	// once Run has parsed the sendmail flags.
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {},
+	Run: func(cmd *cobra.Command, args []string) {
+		for _, arg := range args {
+			if arg == "--help" {
+				cmd.Help()
+				return
+			}
+		}
+
+		opts, globals, err := parseSendmailArgs(args)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "sendmail: %v\n", err)
+			os.Exit(exUsage)
+		}
+		for name, value := range globals {
+			if err := rootCmd.PersistentFlags().Set(name, value); err != nil {
+				fmt.Fprintf(os.Stderr, "sendmail: invalid --%s: %v\n", name, err)
+				os.Exit(exUsage)
+			}
+		}
+		rootCmd.PersistentPreRun(cmd, args)
+
+		if opts.smtpSession {
+			server := &smtpd.Server{
+				Hostname: hostname(),
+				Handler: func(ctx context.Context, env mime.Envelope, raw []byte) error {
+					_, err := sendRawMessage(ctx, env, raw)
+					return err
+				},
+				Temporary: providers.IsTemporary,
+			}
+			if err := server.ServeSession(cmd.Context(), smtpd.Stdio(os.Stdin, os.Stdout)); err != nil {
+				fmt.Fprintf(os.Stderr, "sendmail: %v\n", err)
+				os.Exit(exTempFail)
+			}
+			return
+		}
+
+		raw, err := readSendmailMessage(os.Stdin, opts.ignoreDots)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "sendmail: unable to read message: %v\n", err)
+			os.Exit(exDataErr)
+		}
+		raw = completeHeaders(raw, opts)
+
+		env, err := sendmailEnvelope(raw, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "sendmail: %v\n", err)
+			os.Exit(exDataErr)
+		}
+		if len(env.Recipients) == 0 {
+			fmt.Fprintln(os.Stderr, "sendmail: no recipients, give them as arguments or use -t")
+			os.Exit(exUsage)
+		}
+
+		if _, err := sendRawMessage(cmd.Context(), env, raw); err != nil {
+			fmt.Fprintf(os.Stderr, "sendmail: unable to send message to %s: %v\n", strings.Join(env.Recipients, ", "), err)
+			if providers.IsTemporary(err) || errors.Is(err, context.Canceled) {
+				os.Exit(exTempFail)
+			}
+			os.Exit(exUnavailable)
+		}
+	},
+}
+
+// parseSendmailArgs parses sendmail's command line. Its flags do not follow
+// the conventions pflag expects (-oi is one option, -fuser takes an attached
+// value, -ti groups two flags), so they are parsed by hand the way getopt
+// would. gomailit's own --config, --credentials and --profile may be mixed
+// in and are returned in globals.
+func parseSendmailArgs(args []string) (opts sendmailOptions, globals map[string]string, err error) {
+	globals = map[string]string{}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			opts.recipients = append(opts.recipients, args[i+1:]...)
+			break
+		}
+		if name, ok := strings.CutPrefix(arg, "--"); ok {
+			name, value, attached := strings.Cut(name, "=")
+			if rootCmd.PersistentFlags().Lookup(name) == nil {
+				return opts, nil, fmt.Errorf("unknown option --%s", name)
+			}
+			if !attached {
+				if i+1 >= len(args) {
+					return opts, nil, fmt.Errorf("option --%s requires a value", name)
+				}
+				i++
+				value = args[i]
+			}
+			globals[name] = value
+			continue
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			opts.recipients = append(opts.recipients, arg)
+			continue
+		}
+
+		for j := 1; j < len(arg); j++ {
+			flag := arg[j]
+
+			// value returns the value of the flag, either the rest of the
+			// argument (-fuser) or the next argument (-f user).
+			value := func() (string, error) {
+				rest := arg[j+1:]
+				j = len(arg)
+				if rest != "" {
+					return rest, nil
+				}
+				if i+1 >= len(args) {
+					return "", fmt.Errorf("option -%c requires a value", flag)
+				}
+				i++
+				return args[i], nil
+			}
+
+			var v string
+			switch flag {
+			case 't':
+				opts.readHeaders = true
+			case 'i':
+				opts.ignoreDots = true
+			case 'v', 'm', 'n', 'U':
+				// Verbose, me too, no aliases and initial submission.
+			case 'o':
+				// -oi is -i; other options, such as -oem or -odi for error
+				// reporting and delivery mode, do not apply and are ignored.
+				if v, err = value(); v == "i" {
+					opts.ignoreDots = true
+				}
+			case 'b':
+				switch v, err = value(); v {
+				case "s":
+					opts.smtpSession = true
+				case "m":
+				default:
+					if err == nil {
+						err = fmt.Errorf("mode -b%s is not supported", v)
+					}
+				}
+			case 'f', 'r':
+				opts.sender, err = value()
+			case 'F':
+				opts.fullName, err = value()
+			case 'N', 'R', 'V', 'B', 'L', 'O':
+				// Delivery status notification and body type options, which
+				// the providers do not support.
+				_, err = value()
+			default:
+				err = fmt.Errorf("unknown option -%c", flag)
+			}
+			if err != nil {
+				return opts, nil, err
+			}
+		}
+	}
+	return opts, globals, nil
+}
+
+// readSendmailMessage reads a message from r, up to EOF or, unless
+// ignoreDots is set, a line with a single dot. Line endings are normalised to
+// CRLF.
+func readSendmailMessage(r io.Reader, ignoreDots bool) ([]byte, error) {
+	var buf bytes.Buffer
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadString('\n')
+		if len(line) > 0 {
+			line = strings.TrimRight(line, "\r\n")
+			if line == "." && !ignoreDots {
+				break
+			}
+			buf.WriteString(line)
+			buf.WriteString("\r\n")
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if buf.Len() == 0 {
+		return nil, errors.New("message is empty")
+	}
+	return buf.Bytes(), nil
+}
+
+// completeHeaders adds the From, Date and Message-ID headers sendmail would
+// add when the message lacks them.
+func completeHeaders(raw []byte, opts sendmailOptions) []byte {
+	from := mime.HeaderValue(raw, "From")
+	if from == "" && strings.Contains(opts.sender, "@") {
+		from = opts.sender
+		if opts.fullName != "" {
+			if formatted, err := mime.FormatAddressList([]string{fmt.Sprintf("%q <%s>", opts.fullName, opts.sender)}); err == nil {
+				from = formatted
+			}
+		}
+		raw = mime.PrependHeader(raw, "From", from)
+	}
+	if mime.HeaderValue(raw, "Message-ID") == "" {
+		raw = mime.PrependHeader(raw, "Message-ID", mime.NewMessageID(from))
+	}
+	if mime.HeaderValue(raw, "Date") == "" {
+		raw = mime.PrependHeader(raw, "Date", time.Now().Format(time.RFC1123Z))
+	}
+	return raw
+}
+
+// sendmailEnvelope works out who a message is from and delivered to. The
+// recipients on the command line are always used; with -t those in the
+// headers are added to them.
+func sendmailEnvelope(raw []byte, opts sendmailOptions) (mime.Envelope, error) {
+	env := mime.Envelope{}
+	// A bare local user such as "root", as cron passes it, is not a usable
+	// envelope sender for a remote provider, so From is used instead.
+	useSender := strings.Contains(opts.sender, "@")
+	if opts.readHeaders || !useSender {
+		headers, err := mime.ParseEnvelope(raw)
+		if err != nil {
+			return env, err
+		}
+		env.From = headers.From
+		if opts.readHeaders {
+			env.Recipients = headers.Recipients
+		}
+	}
+
+	if useSender {
+		env.From = mail.BareAddress(opts.sender)
+	}
+
+	recipients, err := mail.ParseAddressList(opts.recipients)
+	if err != nil {
+		return env, fmt.Errorf("invalid recipient: %v", err)
+	}
+	seen := map[string]bool{}
+	for _, address := range env.Recipients {
+		seen[strings.ToLower(address)] = true
+	}
+	for _, address := range recipients {
+		address = mail.BareAddress(address)
+		if !seen[strings.ToLower(address)] {
+			seen[strings.ToLower(address)] = true
+			env.Recipients = append(env.Recipients, address)
+		}
+	}
+	return env, nil
+}
+
+func hostname() string {
+	name, err := os.Hostname()
+	if err != nil || name == "" {
+		return "localhost"
+	}
+	return name
+}
+
+func init() {
+	rootCmd.AddCommand(sendmailCmd)
+}
@@ -0,0 +1,226 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSendmailArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    sendmailOptions
+		globals map[string]string
+	}{
+		{
+			name: "recipients only",
+			args: []string{"admin@example.com", "root"},
+			want: sendmailOptions{recipients: []string{"admin@example.com", "root"}},
+		},
+		{
+			name: "separate flags",
+			args: []string{"-t", "-i", "-f", "cron@example.com", "-F", "Cron Daemon"},
+			want: sendmailOptions{readHeaders: true, ignoreDots: true, sender: "cron@example.com", fullName: "Cron Daemon"},
+		},
+		{
+			name: "grouped flags",
+			args: []string{"-ti"},
+			want: sendmailOptions{readHeaders: true, ignoreDots: true},
+		},
+		{
+			name: "grouped flags ending with a value",
+			args: []string{"-itfroot@example.com", "admin@example.com"},
+			want: sendmailOptions{readHeaders: true, ignoreDots: true, sender: "root@example.com", recipients: []string{"admin@example.com"}},
+		},
+		{
+			name: "grouped flags with the value next",
+			args: []string{"-tr", "root@example.com"},
+			want: sendmailOptions{readHeaders: true, sender: "root@example.com"},
+		},
+		{
+			name: "attached values",
+			args: []string{"-fcron@example.com", "-FCron"},
+			want: sendmailOptions{sender: "cron@example.com", fullName: "Cron"},
+		},
+		{
+			name: "-oi",
+			args: []string{"-oi", "admin@example.com"},
+			want: sendmailOptions{ignoreDots: true, recipients: []string{"admin@example.com"}},
+		},
+		{
+			name: "-o with the option next",
+			args: []string{"-o", "i"},
+			want: sendmailOptions{ignoreDots: true},
+		},
+		{
+			name: "unknown -o options are ignored",
+			args: []string{"-oem", "-odi", "-oQ/var/spool", "-oXyz", "-o", "DeliveryMode=b", "admin@example.com"},
+			want: sendmailOptions{recipients: []string{"admin@example.com"}},
+		},
+		{
+			name: "ignored flags",
+			args: []string{"-v", "-Um", "-N", "never", "-Rhdrs", "-B8BITMIME", "-Lcron", "-Vid", "-Oopt=1", "admin@example.com"},
+			want: sendmailOptions{recipients: []string{"admin@example.com"}},
+		},
+		{
+			name: "modes",
+			args: []string{"-bm", "-bs"},
+			want: sendmailOptions{smtpSession: true},
+		},
+		{
+			name: "after --",
+			args: []string{"-i", "--", "-t", "admin@example.com"},
+			want: sendmailOptions{ignoreDots: true, recipients: []string{"-t", "admin@example.com"}},
+		},
+		{
+			name:    "gomailit flags",
+			args:    []string{"--profile", "alerts", "-ti", "--config=/etc/gomailit.toml", "admin@example.com"},
+			want:    sendmailOptions{readHeaders: true, ignoreDots: true, recipients: []string{"admin@example.com"}},
+			globals: map[string]string{"profile": "alerts", "config": "/etc/gomailit.toml"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, globals, err := parseSendmailArgs(tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("options = %+v, want %+v", got, tt.want)
+			}
+			if tt.globals == nil {
+				tt.globals = map[string]string{}
+			}
+			if !reflect.DeepEqual(globals, tt.globals) {
+				t.Errorf("globals = %v, want %v", globals, tt.globals)
+			}
+		})
+	}
+}
+
+func TestParseSendmailArgsErrors(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-f"}, "option -f requires a value"},
+		{[]string{"-tF"}, "option -F requires a value"},
+		{[]string{"-o"}, "option -o requires a value"},
+		{[]string{"-bp"}, "mode -bp is not supported"},
+		{[]string{"-tbd"}, "mode -bd is not supported"},
+		{[]string{"-x"}, "unknown option -x"},
+		{[]string{"-tix"}, "unknown option -x"},
+		{[]string{"--verbose"}, "unknown option --verbose"},
+		{[]string{"-t", "--profile"}, "option --profile requires a value"},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			_, _, err := parseSendmailArgs(tt.args)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadSendmailMessage(t *testing.T) {
+	const input = "To: a@example.com\n\nfirst\n.\nafter the dot\n"
+	tests := []struct {
+		name       string
+		input      string
+		ignoreDots bool
+		want       string
+	}{
+		{"dot ends the message", input, false, "To: a@example.com\r\n\r\nfirst\r\n"},
+		{"-i reads past the dot", input, true, "To: a@example.com\r\n\r\nfirst\r\n.\r\nafter the dot\r\n"},
+		{"dots within lines", "Subject: x\n\n..\n. \n.x\n", false, "Subject: x\r\n\r\n..\r\n. \r\n.x\r\n"},
+		{"CRLF dot", "Subject: x\r\n\r\nbody\r\n.\r\nignored\r\n", false, "Subject: x\r\n\r\nbody\r\n"},
+		{"no final newline", "Subject: x\n\nbody", false, "Subject: x\r\n\r\nbody\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readSendmailMessage(strings.NewReader(tt.input), tt.ignoreDots)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("message = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := readSendmailMessage(strings.NewReader(".\nrest\n"), false); err == nil {
+		t.Error("a message ended by a dot before any line was accepted")
+	}
+}
+
+func TestSendmailEnvelope(t *testing.T) {
+	const raw = "From: Alice <alice@example.com>\r\n" +
+		"To: Bob <bob@example.com>, carol@example.com\r\n" +
+		"Cc: dave@example.com\r\n" +
+		"Bcc: audit@example.com\r\n" +
+		"Subject: report\r\n\r\nbody\r\n"
+	tests := []struct {
+		name string
+		args []string
+		from string
+		to   []string
+	}{
+		{"arguments only", []string{"erin@example.com"}, "alice@example.com", []string{"erin@example.com"}},
+		{"-t", []string{"-t"}, "alice@example.com", []string{"bob@example.com", "carol@example.com", "dave@example.com", "audit@example.com"}},
+		{"-t adds the arguments", []string{"-ti", "erin@example.com", "Carol <CAROL@example.com>"}, "alice@example.com",
+			[]string{"bob@example.com", "carol@example.com", "dave@example.com", "audit@example.com", "erin@example.com"}},
+		{"-f overrides From", []string{"-t", "-f", "bounce@example.com"}, "bounce@example.com",
+			[]string{"bob@example.com", "carol@example.com", "dave@example.com", "audit@example.com"}},
+		{"local -f user", []string{"-froot", "erin@example.com"}, "alice@example.com", []string{"erin@example.com"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, _, err := parseSendmailArgs(tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			env, err := sendmailEnvelope([]byte(raw), opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if env.From != tt.from {
+				t.Errorf("from = %q, want %q", env.From, tt.from)
+			}
+			if !reflect.DeepEqual(env.Recipients, tt.to) {
+				t.Errorf("recipients = %q, want %q", env.Recipients, tt.to)
+			}
+		})
+	}
+}
+
+// TestSendmailDotsAndHeaders reads the same input with and without -i: a
+// lone dot ends the message unless -i is given, and -t takes the recipients
+// from whatever headers were read.
+func TestSendmailDotsAndHeaders(t *testing.T) {
+	const input = "To: bob@example.com\nSubject: dots\n\nline\n.\nCc: not-a-header@example.com\n"
+	for _, args := range [][]string{{"-t"}, {"-ti"}, {"-t", "-oi"}} {
+		opts, _, err := parseSendmailArgs(args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, err := readSendmailMessage(strings.NewReader(input), opts.ignoreDots)
+		if err != nil {
+			t.Fatal(err)
+		}
+		env, err := sendmailEnvelope(raw, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(env.Recipients, []string{"bob@example.com"}) {
+			t.Errorf("%v: recipients = %q", args, env.Recipients)
+		}
+		if got := strings.Contains(string(raw), "not-a-header"); got != opts.ignoreDots {
+			t.Errorf("%v: body after the dot kept = %v", args, got)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/latocchi/gomailit/internal/history"
+	"github.com/latocchi/gomailit/internal/mail"
+	"github.com/latocchi/gomailit/internal/mime"
+	"github.com/latocchi/gomailit/internal/providers"
+	"github.com/spf13/cobra"
+)
+
+var (
+	rawTo   []string
+	rawFrom string
+)
+
+// sendRawCmd represents the send-raw command
+var sendRawCmd = &cobra.Command{
+	Use:   "send-raw <file.eml|->",
+	Short: "Sends a pre-built .eml / RFC 822 message as is",
+	Long: `Usage:
+
+Send a message generated by another tool
+gomailit send-raw message.eml
+
+Read the message from stdin
+generate-report | gomailit send-raw -
+
+Deliver to other recipients than the To, Cc and Bcc headers (SMTP, SES,
+Mailgun and JMAP; the Gmail and Graph APIs always deliver to the header
+recipients too, and get the others as Bcc)
+gomailit send-raw message.eml --to ops@example.com --to oncall@example.com
+`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var raw []byte
+		var err error
+		if args[0] == "-" {
+			raw, err = io.ReadAll(os.Stdin)
+		} else {
+			raw, err = os.ReadFile(args[0])
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to read message: %v\n", err)
+			os.Exit(1)
+		}
+
+		env, err := mime.ParseEnvelope(raw)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if len(rawTo) > 0 {
+			env.Recipients, err = mail.ParseAddressList(rawTo)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid --to: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		if rawFrom != "" {
+			env.From = mail.BareAddress(rawFrom)
+		}
+
+		if _, err := sendRawMessage(cmd.Context(), env, raw); err != nil {
+			fmt.Printf("Failed to send email to %s: %v\n", strings.Join(env.Recipients, ", "), err)
+			os.Exit(1)
+		}
+		fmt.Printf("Email sent to %s successfully.\n", strings.Join(env.Recipients, ", "))
+	},
+}
+
+// sendRawMessage submits a pre-built message through the configured
+// provider.
+func sendRawMessage(ctx context.Context, env mime.Envelope, raw []byte) (string, error) {
+	if len(env.Recipients) == 0 {
+		return "", fmt.Errorf("message has no recipients")
+	}
+
+	rawSender, err := activeRawSender(ctx)
+	if err != nil {
+		return "", err
+	}
+	return sendRaw(ctx, rawSender, env, raw)
+}
+
+// sendRaw submits a pre-built message through rawSender and records the
+// outcome in the history.
+func sendRaw(ctx context.Context, rawSender providers.RawSender, env mime.Envelope, raw []byte) (string, error) {
+	id, err := rawSender.SendRaw(ctx, env, raw)
+	recordSend(history.FromRaw(env.From, env.Recipients, raw), providers.ActiveProvider(), rawSender, id, err)
+	return id, err
+}
+
+// activeRawSender returns the configured provider, which must be able to
+// send pre-built messages.
+func activeRawSender(ctx context.Context) (providers.RawSender, error) {
+	name := providers.ActiveProvider()
+	provider, err := providers.Get(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load provider: %v", err)
+	}
+	rawSender, ok := provider.(providers.RawSender)
+	if !ok {
+		return nil, fmt.Errorf("provider %s cannot send pre-built messages", name)
+	}
+	return &limitedRawSender{rawSender, rateLimiter(name)}, nil
+}
+
+func init() {
+	rootCmd.AddCommand(sendRawCmd)
+
+	sendRawCmd.Flags().StringArrayVarP(&rawTo, "to", "t", nil, "Envelope recipient overriding the To, Cc and Bcc headers, can be repeated")
+	sendRawCmd.Flags().StringVarP(&rawFrom, "from", "f", "", "Envelope sender overriding the From header")
+}
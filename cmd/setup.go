@@ -5,13 +5,13 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"strings"
 
 	"github.com/latocchi/gomailit/internal/providers"
 	"github.com/spf13/cobra"
 )
 
-var provider string
-
 // setupCmd represents the setup command
 var setupCmd = &cobra.Command{
 	Use:   "setup",
@@ -25,45 +25,74 @@ Examples:
 Setup Google provider
 gomailit setup google
 
-Supported Providers:
-- google / gmail
+Setup Microsoft 365 or Outlook.com through Microsoft Graph
+gomailit setup outlook --client-id 00000000-0000-0000-0000-000000000000
+
+Setup Amazon SES with credentials from the AWS environment variables
+gomailit setup ses --region eu-west-1 --from noreply@example.com
+
+Setup SMTP provider (STARTTLS on port 587 with PLAIN auth)
+gomailit setup smtp --host smtp.example.com --username alice@example.com
 
-As of now only Google provider is supported.
+Setup SMTP provider with implicit TLS and LOGIN auth
+gomailit setup smtp --host smtp.example.com --port 465 --security tls \
+	--auth login --username alice@example.com
+
+Setup SMTP provider for a local Mailhog instance
+gomailit setup smtp --host localhost --port 1025 --security none \
+	--from ci@example.com
+
+Run 'gomailit setup [provider] --help' for the flags of each provider.
 	`,
+	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		if len(args) < 1 {
-			fmt.Println("Unsupported provider:", provider)
-			fmt.Println("Switching to default provider 'google'")
-			_, err := providers.SetupGoogle()
-			if err != nil {
-				fmt.Println("Error setting up Google provider:", err)
+		// Providers have their own subcommands, so anything ending up here
+		// is either no provider at all or an unknown one.
+		if len(args) > 0 {
+			names := []string{}
+			for _, r := range providers.Registrations() {
+				names = append(names, r.Name)
 			}
-			return
+			fmt.Fprintf(os.Stderr, "Unsupported provider %q, available providers: %s\n", args[0], strings.Join(names, ", "))
+			os.Exit(1)
 		}
 
-		provider = args[0]
-
-		switch provider {
-		case "google", "gmail":
-			_, err := providers.SetupGoogle()
-			if err != nil {
-				fmt.Println("Error setting up Google provider:", err)
-			}
-		// TODO: Add other providers here
-		default:
-			fmt.Println("Unsupported provider:", provider)
-			fmt.Println("Switching to default provider 'google'")
-			_, err := providers.SetupGoogle()
-			if err != nil {
-				fmt.Println("Error setting up Google provider:", err)
-			}
+		fmt.Println("No provider given, setting up the default provider 'google'")
+		if err := providers.Setup(cmd.Context(), "google", cmd.Flags()); err != nil {
+			fmt.Fprintf(os.Stderr, "Error setting up Google provider: %v\n", err)
+			os.Exit(1)
 		}
 	},
 }
 
+// newProviderSetupCmd creates the 'gomailit setup <name>' subcommand of a
+// registered provider.
+func newProviderSetupCmd(r *providers.Registration) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     r.Name,
+		Aliases: r.Aliases,
+		Short:   fmt.Sprintf("Setup %s: %s", r.Name, r.Description),
+		Args:    cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := providers.Setup(cmd.Context(), r.Name, cmd.Flags()); err != nil {
+				fmt.Fprintf(os.Stderr, "Error setting up %s provider: %v\n", r.Name, err)
+				os.Exit(1)
+			}
+		},
+	}
+	if r.Flags != nil {
+		r.Flags(cmd.Flags())
+	}
+	return cmd
+}
+
 func init() {
 	rootCmd.AddCommand(setupCmd)
 
+	for _, r := range providers.Registrations() {
+		setupCmd.AddCommand(newProviderSetupCmd(r))
+	}
+
 	// Here you will define your flags and configuration settings.
 
 	// Cobra supports Persistent Flags which will work for this command
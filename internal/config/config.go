@@ -0,0 +1,314 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+
+// Package config reads and writes the gomailit config file, config.toml in
+// the gomailit config directory, and applies the GOMAILIT_* environment
+// variables on top of it.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/latocchi/gomailit/internal/mail"
+	"github.com/latocchi/gomailit/internal/utils"
+)
+
+// Default names the profile kept directly in the config directory, which is
+// what installs without profiles use.
+const Default = "default"
+
+// Config is the content of the config file. The top-level provider, from
+// and signature belong to the default profile.
+type Config struct {
+	// DefaultProfile is the profile used when --profile is not given.
+	DefaultProfile string `toml:"default_profile"`
+	Provider       string `toml:"provider"`
+	From           string `toml:"from"`
+	Signature      string `toml:"signature"`
+	// Concurrency is how many messages are sent at the same time.
+	Concurrency int `toml:"concurrency"`
+	// HistoryKeep is how many rotated history files are kept. Zero keeps
+	// them all.
+	HistoryKeep int `toml:"history_keep"`
+
+	// RateLimits caps how fast each provider is used, keyed by provider
+	// name.
+	RateLimits map[string]*RateLimit `toml:"rate_limits"`
+	Profiles   map[string]*Profile   `toml:"profiles"`
+
+	// env holds the GOMAILIT_* overrides of the selected profile. A config
+	// with overrides is only for reading and cannot be saved.
+	env *Profile
+}
+
+// Profile is a named account with its own provider settings and token.
+type Profile struct {
+	Provider  string `toml:"provider"`
+	From      string `toml:"from"`
+	Signature string `toml:"signature"`
+}
+
+// RateLimit caps the sending rate of a provider. Zero means unlimited.
+type RateLimit struct {
+	PerSecond float64 `toml:"per_second"`
+	PerDay    int     `toml:"per_day"`
+}
+
+// DefaultConcurrency is used when the config does not set concurrency.
+const DefaultConcurrency = 5
+
+// EnvVars maps the environment variables that override the config file to
+// the keys they override. GOMAILIT_PROVIDER, GOMAILIT_FROM and
+// GOMAILIT_SIGNATURE apply to whichever profile is selected.
+var EnvVars = map[string]string{
+	"GOMAILIT_PROFILE":     "default_profile",
+	"GOMAILIT_PROVIDER":    "provider",
+	"GOMAILIT_FROM":        "from",
+	"GOMAILIT_SIGNATURE":   "signature",
+	"GOMAILIT_CONCURRENCY": "concurrency",
+}
+
+// path is the config file set with --config or $GOMAILIT_CONFIG.
+var path string
+
+// SetPath makes Load and Save use another config file.
+func SetPath(p string) {
+	path = p
+}
+
+// Path returns the config file in use: the one given with --config, else
+// $GOMAILIT_CONFIG, else config.toml in the gomailit config directory.
+func Path() string {
+	if path != "" {
+		return path
+	}
+	if p := os.Getenv("GOMAILIT_CONFIG"); p != "" {
+		return p
+	}
+	return utils.ConfigPath()
+}
+
+// LoadFile reads the config file as it is, without environment overrides.
+// A missing file yields an empty config.
+func LoadFile() (*Config, error) {
+	cfg := &Config{}
+	data, err := os.ReadFile(Path())
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read config: %v", err)
+	}
+	if err := cfg.parse(data); err != nil {
+		return nil, fmt.Errorf("%s: %v", Path(), err)
+	}
+	return cfg, nil
+}
+
+// Parse decodes a config file.
+func Parse(data []byte) (*Config, error) {
+	cfg := &Config{}
+	return cfg, cfg.parse(data)
+}
+
+// parse decodes data into c. Unknown keys are errors, so that typos do not
+// go unnoticed.
+func (c *Config) parse(data []byte) error {
+	meta, err := toml.Decode(string(data), c)
+	if err != nil {
+		return err
+	}
+	if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+		return fmt.Errorf("unknown key %s", undecoded[0])
+	}
+	return nil
+}
+
+// Load reads the config file and applies the GOMAILIT_* environment
+// variables on top of it. Use LoadFile to change and save the config.
+func Load() (*Config, error) {
+	cfg, err := LoadFile()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.env = &Profile{}
+	for name, key := range EnvVars {
+		value, ok := os.LookupEnv(name)
+		if !ok || value == "" {
+			continue
+		}
+		switch key {
+		case "provider":
+			cfg.env.Provider = value
+		case "from":
+			cfg.env.From = value
+		case "signature":
+			cfg.env.Signature = value
+		default:
+			if err := cfg.Set(key, value); err != nil {
+				return nil, fmt.Errorf("$%s: %v", name, err)
+			}
+		}
+	}
+	return cfg, nil
+}
+
+// Save writes the config file.
+func (c *Config) Save() error {
+	if c.env != nil {
+		return errors.New("unable to save config: it includes environment overrides")
+	}
+	return WriteFile(Path(), []byte(encode(c)))
+}
+
+// WriteFile replaces a config file through a temporary file, so that a
+// crash never leaves a half-written config behind.
+func WriteFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "config.*.tmp")
+	if err != nil {
+		return fmt.Errorf("unable to save config: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("unable to save config: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("unable to save config: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("unable to save config: %v", err)
+	}
+	return nil
+}
+
+// Validate checks the values of the config. Unknown keys and wrong types
+// are already rejected when it is read.
+func (c *Config) Validate() error {
+	var problems []string
+	if c.Concurrency < 0 {
+		problems = append(problems, "concurrency must not be negative")
+	}
+	if c.HistoryKeep < 0 {
+		problems = append(problems, "history_keep must not be negative")
+	}
+	for _, name := range sortedKeys(c.RateLimits) {
+		limit := c.RateLimits[name]
+		if limit != nil && (limit.PerSecond < 0 || limit.PerDay < 0) {
+			problems = append(problems, fmt.Sprintf("rate_limits.%s must not be negative", name))
+		}
+	}
+	for _, name := range sortedKeys(c.Profiles) {
+		if err := ValidateProfileName(name); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	if c.DefaultProfile != "" {
+		if _, ok := c.GetProfile(c.DefaultProfile); !ok {
+			problems = append(problems, fmt.Sprintf("default_profile %q does not exist", c.DefaultProfile))
+		}
+	}
+	for _, name := range c.ProfileNames() {
+		profile, _ := c.GetProfile(name)
+		if profile.From == "" {
+			continue
+		}
+		if _, err := mail.ParseAddressList([]string{profile.From}); err != nil {
+			problems = append(problems, fmt.Sprintf("profile %s: invalid from: %v", name, err))
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+// ConcurrencyOrDefault returns how many messages to send at the same time.
+func (c *Config) ConcurrencyOrDefault() int {
+	if c.Concurrency <= 0 {
+		return DefaultConcurrency
+	}
+	return c.Concurrency
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// GetProfile returns the settings of a profile. The default profile always
+// exists; it is backed by the top-level settings.
+func (c *Config) GetProfile(name string) (*Profile, bool) {
+	var profile Profile
+	if name == Default || name == "" {
+		profile = Profile{Provider: c.Provider, From: c.From, Signature: c.Signature}
+	} else if named, ok := c.Profiles[name]; ok && named != nil {
+		profile = *named
+	} else {
+		return nil, false
+	}
+
+	if c.env != nil {
+		if c.env.Provider != "" {
+			profile.Provider = c.env.Provider
+		}
+		if c.env.From != "" {
+			profile.From = c.env.From
+		}
+		if c.env.Signature != "" {
+			profile.Signature = c.env.Signature
+		}
+	}
+	return &profile, true
+}
+
+// SetProfile stores the settings of a profile, creating it if needed.
+func (c *Config) SetProfile(name string, profile *Profile) {
+	if name == Default || name == "" {
+		c.Provider, c.From, c.Signature = profile.Provider, profile.From, profile.Signature
+		return
+	}
+	if c.Profiles == nil {
+		c.Profiles = map[string]*Profile{}
+	}
+	c.Profiles[name] = profile
+}
+
+// ProfileNames returns the default profile followed by the named ones in
+// alphabetical order.
+func (c *Config) ProfileNames() []string {
+	return append([]string{Default}, sortedKeys(c.Profiles)...)
+}
+
+// DefaultProfileName returns the profile used when none is selected.
+func (c *Config) DefaultProfileName() string {
+	if c.DefaultProfile == "" {
+		return Default
+	}
+	return c.DefaultProfile
+}
+
+var profileName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// ValidateProfileName checks that a profile name is usable as a directory
+// name.
+func ValidateProfileName(name string) error {
+	if !profileName.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q, use letters, digits, - and _ only", name)
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Get returns the value of a dotted key such as rate_limits.google.per_day,
+// or the whole config for an empty key.
+func (c *Config) Get(key string) (string, error) {
+	if key == "" {
+		return strings.TrimSpace(encode(c)), nil
+	}
+	if c.env != nil {
+		// Profile overrides apply to whichever profile is selected.
+		switch {
+		case key == "provider" && c.env.Provider != "":
+			return c.env.Provider, nil
+		case key == "from" && c.env.From != "":
+			return c.env.From, nil
+		case key == "signature" && c.env.Signature != "":
+			return c.env.Signature, nil
+		}
+	}
+	v, err := lookup(reflect.ValueOf(c).Elem(), key, false)
+	if err != nil {
+		return "", err
+	}
+	if !v.IsValid() {
+		return "", nil
+	}
+	switch v.Kind() {
+	case reflect.Struct, reflect.Map:
+		return strings.TrimSpace(encodeSection(v)), nil
+	case reflect.Slice:
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = fmt.Sprint(v.Index(i).Interface())
+		}
+		return strings.Join(items, ","), nil
+	}
+	return fmt.Sprint(v.Interface()), nil
+}
+
+// Set parses value according to the type of a dotted key and stores it. An
+// empty value resets the key. Missing profiles and rate limits are created.
+func (c *Config) Set(key, value string) error {
+	v, err := lookup(reflect.ValueOf(c).Elem(), key, true)
+	if err != nil {
+		return err
+	}
+
+	if value == "" {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s must be an integer", key)
+		}
+		v.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("%s must be a number", key)
+		}
+		v.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s must be true or false", key)
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("%s is a table, set one of its keys instead", key)
+	}
+	return nil
+}
+
+// lookup walks a dotted key through structs and maps. With create, missing
+// map entries are added so that the returned value can be set; without it
+// an invalid Value is returned for them.
+func lookup(v reflect.Value, key string, create bool) (reflect.Value, error) {
+	parts := strings.Split(key, ".")
+	for i := 0; i < len(parts); i++ {
+		part := parts[i]
+		switch v.Kind() {
+		case reflect.Pointer:
+			if v.IsNil() {
+				if !create {
+					return reflect.Value{}, nil
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+			i--
+			continue
+		case reflect.Struct:
+			index, ok := tomlFields(v.Type())[part]
+			if !ok {
+				return reflect.Value{}, fmt.Errorf("unknown key %s", strings.Join(parts[:i+1], "."))
+			}
+			v = v.Field(index)
+		case reflect.Map:
+			if v.IsNil() {
+				if !create {
+					return reflect.Value{}, nil
+				}
+				v.Set(reflect.MakeMap(v.Type()))
+			}
+			elem := v.MapIndex(reflect.ValueOf(part))
+			if !elem.IsValid() {
+				if !create {
+					return reflect.Value{}, nil
+				}
+				elem = reflect.New(v.Type().Elem().Elem())
+				v.SetMapIndex(reflect.ValueOf(part), elem)
+			}
+			v = elem
+		default:
+			return reflect.Value{}, fmt.Errorf("unknown key %s", key)
+		}
+	}
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return reflect.Value{}, nil
+		}
+		v = v.Elem()
+	}
+	return v, nil
+}
+
+// encodeSection encodes a table or a map of tables for Get.
+func encodeSection(v reflect.Value) string {
+	var buf strings.Builder
+	if v.Kind() == reflect.Struct {
+		encodeTable(&buf, v, nil, false)
+		return buf.String()
+	}
+
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+	for _, key := range keys {
+		elem := v.MapIndex(key)
+		if elem.Kind() == reflect.Pointer {
+			elem = elem.Elem()
+		}
+		encodeTable(&buf, elem, []string{key.String()}, true)
+	}
+	return buf.String()
+}
@@ -0,0 +1,174 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSetGet(t *testing.T) {
+	tests := []struct {
+		key, value string
+		want       string
+	}{
+		{"provider", "smtp", "smtp"},
+		{"concurrency", "10", "10"},
+		{"history_keep", "12", "12"},
+		{"rate_limits.gmail.per_day", "500", "500"},
+		{"rate_limits.gmail.per_second", "0.5", "0.5"},
+		{"profiles.billing.from", "Billing <billing@example.com>", "Billing <billing@example.com>"},
+		{"profiles.billing.signature", "--\nBilling", "--\nBilling"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			cfg := &Config{}
+			if err := cfg.Set(tt.key, tt.value); err != nil {
+				t.Fatal(err)
+			}
+			got, err := cfg.Get(tt.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Get(%s) = %q, want %q", tt.key, got, tt.want)
+			}
+
+			// The value survives saving and loading.
+			parsed, err := Parse([]byte(encode(cfg)))
+			if err != nil {
+				t.Fatalf("%v in:\n%s", err, encode(cfg))
+			}
+			if got, _ := parsed.Get(tt.key); got != tt.want {
+				t.Errorf("after a round trip Get(%s) = %q, want %q", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetCreates(t *testing.T) {
+	cfg := &Config{}
+	if err := cfg.Set("rate_limits.gmail.per_day", "500"); err != nil {
+		t.Fatal(err)
+	}
+	if limit := cfg.RateLimits["gmail"]; limit == nil || limit.PerDay != 500 {
+		t.Errorf("rate limit = %+v", limit)
+	}
+	if err := cfg.Set("profiles.new.from", "new@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if profile := cfg.Profiles["new"]; profile == nil || profile.From != "new@example.com" {
+		t.Errorf("profile = %+v", profile)
+	}
+
+	want := `from = "new@example.com"`
+	if got, _ := cfg.Get("profiles.new"); got != want {
+		t.Errorf("Get(profiles.new) = %q, want %q", got, want)
+	}
+	want = "[gmail]\nper_day = 500"
+	if got, _ := cfg.Get("rate_limits"); got != want {
+		t.Errorf("Get(rate_limits) = %q, want %q", got, want)
+	}
+}
+
+func TestSetReset(t *testing.T) {
+	cfg, err := Parse([]byte(`
+from = "alice@example.com"
+concurrency = 10
+
+[rate_limits.gmail]
+per_second = 2.0
+per_day = 500
+
+[profiles.work]
+from = "bob@example.com"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"from", "concurrency", "rate_limits.gmail.per_day", "profiles.work.from"} {
+		if err := cfg.Set(key, ""); err != nil {
+			t.Fatalf("Set(%s, \"\"): %v", key, err)
+		}
+		if got, _ := cfg.Get(key); got != "" && got != "0" {
+			t.Errorf("after a reset Get(%s) = %q", key, got)
+		}
+	}
+
+	// Reset keys are left out of the file; the emptied profile is kept.
+	want := "[rate_limits.gmail]\nper_second = 2.0\n\n[profiles.work]\n"
+	if got := encode(cfg); got != want {
+		t.Errorf("encoded config:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestSetErrors(t *testing.T) {
+	tests := []struct {
+		key, value string
+		want       string
+	}{
+		{"nope", "1", "unknown key nope"},
+		{"profiles.work.token", "x", "unknown key profiles.work.token"},
+		{"rate_limits.gmail.per_week", "1", "unknown key rate_limits.gmail.per_week"},
+		{"from.name", "x", "unknown key from.name"},
+		{"concurrency", "many", "concurrency must be an integer"},
+		{"rate_limits.gmail.per_second", "fast", "rate_limits.gmail.per_second must be a number"},
+		{"rate_limits", "1", "rate_limits is a table, set one of its keys instead"},
+		{"rate_limits.gmail", "1", "rate_limits.gmail is a table, set one of its keys instead"},
+		{"profiles.work", "x", "profiles.work is a table, set one of its keys instead"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			err := (&Config{}).Set(tt.key, tt.value)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetMissing(t *testing.T) {
+	cfg := &Config{}
+	for _, key := range []string{"profiles.none.from", "rate_limits.gmail.per_day", "profiles.none"} {
+		got, err := cfg.Get(key)
+		if err != nil || got != "" {
+			t.Errorf("Get(%s) = %q, %v", key, got, err)
+		}
+	}
+	if cfg.Profiles != nil || cfg.RateLimits != nil {
+		t.Error("Get created entries")
+	}
+}
+
+func TestGetEnvOverrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := WriteFile(path, []byte("from = \"file@example.com\"\nconcurrency = 3\n")); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GOMAILIT_CONFIG", path)
+	t.Setenv("GOMAILIT_FROM", "env@example.com")
+	t.Setenv("GOMAILIT_CONCURRENCY", "8")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{"from": "env@example.com", "concurrency": "8"} {
+		if got, _ := cfg.Get(key); got != want {
+			t.Errorf("Get(%s) = %q, want %q", key, got, want)
+		}
+	}
+
+	file, err := LoadFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := file.Get("from"); got != "file@example.com" {
+		t.Errorf("LoadFile applied the override: from = %q", got)
+	}
+	if got, _ := file.Get(""); !strings.Contains(got, "concurrency = 3") {
+		t.Errorf("Get() = %q", got)
+	}
+}
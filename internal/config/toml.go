@@ -0,0 +1,184 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The config file is read with github.com/BurntSushi/toml. It is written by
+// the encoder below, which keeps the file readable for people editing it:
+// zero values are left out and multi-line strings such as signatures are
+// written as multi-line strings.
+
+// tomlFields maps the toml tags of a struct type to field indexes.
+func tomlFields(t reflect.Type) map[string]int {
+	fields := map[string]int{}
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("toml"), ",")
+		if name != "" && name != "-" {
+			fields[name] = i
+		}
+	}
+	return fields
+}
+
+// encode writes the struct v points to as TOML. Zero values are left out,
+// nested structs and maps become tables.
+func encode(v any) string {
+	var buf strings.Builder
+	encodeTable(&buf, reflect.ValueOf(v).Elem(), nil, false)
+	return strings.TrimLeft(buf.String(), "\n")
+}
+
+// encodeTable writes the fields of src under a [path] header. The header is
+// left out when the table has no values of its own, unless always is set,
+// which keeps empty map entries such as a profile with defaults only.
+func encodeTable(buf *strings.Builder, src reflect.Value, path []string, always bool) {
+	type table struct {
+		path   []string
+		value  reflect.Value
+		always bool
+	}
+	var tables []table
+	var lines []string
+
+	t := src.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("toml"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		field := src.Field(i)
+		if field.Kind() == reflect.Pointer {
+			if field.IsNil() {
+				continue
+			}
+			field = field.Elem()
+		}
+
+		switch field.Kind() {
+		case reflect.Struct:
+			tables = append(tables, table{append(clone(path), name), field, false})
+		case reflect.Map:
+			keys := field.MapKeys()
+			sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+			for _, key := range keys {
+				elem := field.MapIndex(key)
+				if elem.Kind() == reflect.Pointer {
+					if elem.IsNil() {
+						continue
+					}
+					elem = elem.Elem()
+				}
+				tables = append(tables, table{append(clone(path), name, key.String()), elem, true})
+			}
+		default:
+			if !field.IsZero() {
+				lines = append(lines, fmt.Sprintf("%s = %s\n", quoteKey(name), encodeValue(field)))
+			}
+		}
+	}
+
+	if len(path) > 0 && (len(lines) > 0 || always) {
+		writeHeader(buf, path)
+	}
+	for _, line := range lines {
+		buf.WriteString(line)
+	}
+	for _, table := range tables {
+		encodeTable(buf, table.value, table.path, table.always)
+	}
+}
+
+func writeHeader(buf *strings.Builder, path []string) {
+	keys := make([]string, len(path))
+	for i, key := range path {
+		keys[i] = quoteKey(key)
+	}
+	fmt.Fprintf(buf, "\n[%s]\n", strings.Join(keys, "."))
+}
+
+func encodeValue(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.String:
+		return quoteString(v.String())
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Float64:
+		s := strconv.FormatFloat(v.Float(), 'f', -1, 64)
+		if !strings.ContainsAny(s, ".e") {
+			s += ".0"
+		}
+		return s
+	case reflect.Slice:
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = encodeValue(v.Index(i))
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	}
+	return `""`
+}
+
+var bareKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func quoteKey(key string) string {
+	if bareKey.MatchString(key) {
+		return key
+	}
+	return quoteString(key)
+}
+
+// quoteString writes s as a basic string, or as a multi-line basic string
+// when it spans several lines, such as a signature.
+func quoteString(s string) string {
+	multiline := strings.Contains(s, "\n")
+
+	var buf strings.Builder
+	if multiline {
+		buf.WriteString("\"\"\"\n")
+	} else {
+		buf.WriteByte('"')
+	}
+	for _, r := range s {
+		switch {
+		case r == '\\':
+			buf.WriteString(`\\`)
+		case r == '"':
+			buf.WriteString(`\"`)
+		case r == '\n' && multiline:
+			buf.WriteByte('\n')
+		case r == '\n':
+			buf.WriteString(`\n`)
+		case r == '\t' && multiline:
+			buf.WriteByte('\t')
+		case r == '\t':
+			buf.WriteString(`\t`)
+		case r == '\r':
+			buf.WriteString(`\r`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&buf, `\u%04X`, r)
+		default:
+			buf.WriteRune(r)
+		}
+	}
+	if multiline {
+		buf.WriteString(`"""`)
+	} else {
+		buf.WriteByte('"')
+	}
+	return buf.String()
+}
+
+func clone(path []string) []string {
+	return append([]string(nil), path...)
+}
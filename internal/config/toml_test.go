@@ -0,0 +1,157 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSyntax(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want *Config
+	}{
+		{
+			name: "literal and multi-line strings",
+			src:  "from = 'C:\\Users\\bob'\nsignature = \"\"\"\nBest regards,\n  Bob\"\"\"",
+			want: &Config{From: `C:\Users\bob`, Signature: "Best regards,\n  Bob"},
+		},
+		{
+			name: "escapes",
+			src:  `signature = "tab\there \"quoted\" \u00e9"`,
+			want: &Config{Signature: "tab\there \"quoted\" é"},
+		},
+		{
+			name: "integers and floats",
+			src:  "concurrency = 1_0\n[rate_limits.gmail]\nper_second = 2\nper_day = 0x1F4",
+			want: &Config{Concurrency: 10, RateLimits: map[string]*RateLimit{"gmail": {PerSecond: 2, PerDay: 500}}},
+		},
+		{
+			name: "inline tables and dotted keys",
+			src:  "rate_limits = { gmail = { per_day = 500 }, smtp.per_second = 0.5 }",
+			want: &Config{RateLimits: map[string]*RateLimit{"gmail": {PerDay: 500}, "smtp": {PerSecond: 0.5}}},
+		},
+		{
+			name: "comments",
+			src:  "# leading\nprovider = \"smtp\" # trailing\n[profiles.work] # header\nfrom = \"# not a comment\"\r\n",
+			want: &Config{Provider: "smtp", Profiles: map[string]*Profile{"work": {From: "# not a comment"}}},
+		},
+		{
+			name: "empty",
+			src:  "",
+			want: &Config{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse([]byte(tt.src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"leading zero", "concurrency = 010", "line 1"},
+		{"bare string", "\n\nfrom = bob", "line 3"},
+		{"unterminated string", "provider = \"smtp\"\nfrom = \"open\nconcurrency = 2", "line 2"},
+		{"duplicate key", "provider = \"a\"\nprovider = \"b\"", "line 2"},
+		{"wrong type", "concurrency = \"5\"", "concurrency"},
+		{"table for a value", "[provider]", "provider"},
+		{"unknown key", "concurency = 5", "unknown key concurency"},
+		{"unknown nested key", "[profiles.\"team alias\"]\ntoken = \"x\"", `unknown key profiles."team alias".token`},
+		{"unknown table", "[profile.work]\nfrom = \"a@example.com\"", "unknown key profile"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.src))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	src := `
+default_profile = "work"
+provider = "gmail"
+concurrency = 100
+history_keep = 12
+
+[rate_limits]
+gmail = { per_second = 2, per_day = 500 }
+
+[profiles.work]
+provider = "outlook"
+from = "Bob <bob@example.com>"
+signature = """
+--
+Bob"""
+
+[profiles."team alias"]
+provider = "smtp"
+`
+	cfg, err := Parse([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.DefaultProfile != "work" || cfg.Provider != "gmail" || cfg.Concurrency != 100 || cfg.HistoryKeep != 12 {
+		t.Errorf("top-level values = %q %q %d %d", cfg.DefaultProfile, cfg.Provider, cfg.Concurrency, cfg.HistoryKeep)
+	}
+	if limit := cfg.RateLimits["gmail"]; limit == nil || limit.PerSecond != 2 || limit.PerDay != 500 {
+		t.Errorf("rate limit = %+v", limit)
+	}
+	work := cfg.Profiles["work"]
+	if work == nil || work.Provider != "outlook" || work.From != "Bob <bob@example.com>" || work.Signature != "--\nBob" {
+		t.Errorf("profile work = %+v", work)
+	}
+	if team := cfg.Profiles["team alias"]; team == nil || team.Provider != "smtp" {
+		t.Errorf("profile team alias = %+v", team)
+	}
+	for _, bad := range []string{
+		"concurrency = 010",
+		"concurency = 5",
+		"concurrency = \"5\"",
+		"[profiles.work]\nfrom = 5",
+		"[profiles.work]\ntoken = \"x\"",
+	} {
+		if _, err := Parse([]byte(bad)); err == nil {
+			t.Errorf("%q was accepted", bad)
+		}
+	}
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	cfg := &Config{
+		DefaultProfile: "work",
+		Provider:       "gmail",
+		Concurrency:    3,
+		HistoryKeep:    4,
+		RateLimits:     map[string]*RateLimit{"gmail": {PerSecond: 1, PerDay: 500}},
+		Profiles: map[string]*Profile{
+			"work":       {Provider: "outlook", Signature: "Best,\n\t\"Bob\" \\ team"},
+			"team alias": {},
+		},
+	}
+	got, err := Parse([]byte(encode(cfg)))
+	if err != nil {
+		t.Fatalf("%v in:\n%s", err, encode(cfg))
+	}
+	if !reflect.DeepEqual(got, cfg) {
+		t.Errorf("round trip gave %+v, want %+v", got, cfg)
+	}
+}
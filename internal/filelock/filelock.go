@@ -0,0 +1,29 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+
+// Package filelock lets gomailit processes that share a file, such as a
+// relay and a send started by cron, take turns reading and writing it. The
+// lock is an advisory one on a companion <path>.lock file, so that the file
+// itself can be replaced by a rename while it is held.
+package filelock
+
+import (
+	"fmt"
+	"os"
+)
+
+// With runs fn holding the lock of path, an exclusive one for writing or a
+// shared one for reading. It blocks until the lock is available.
+func With(path string, exclusive bool, fn func() error) error {
+	file, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("unable to lock %s: %v", path, err)
+	}
+	defer file.Close()
+	if err := lock(file, exclusive); err != nil {
+		return fmt.Errorf("unable to lock %s: %v", path, err)
+	}
+	defer unlock(file)
+	return fn()
+}
@@ -0,0 +1,19 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows)
+
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+
+package filelock
+
+import "os"
+
+// lock is a no-op where there is no file locking, leaving only the lock
+// between the goroutines of one process.
+func lock(file *os.File, exclusive bool) error {
+	return nil
+}
+
+func unlock(file *os.File) error {
+	return nil
+}
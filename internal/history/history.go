@@ -0,0 +1,336 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+
+// Package history keeps a log of every message gomailit sent or failed to
+// send. The log is a file of JSON records, one per line, that sends only
+// ever append to. It is rotated once it grows too large. Rotated files are
+// kept unless a limit is set.
+package history
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/latocchi/gomailit/internal/filelock"
+	"github.com/latocchi/gomailit/internal/mail"
+)
+
+// Outcome is whether a send succeeded.
+type Outcome string
+
+const (
+	Sent   Outcome = "sent"
+	Failed Outcome = "failed"
+)
+
+// Attachment identifies an attached file without storing its contents.
+type Attachment struct {
+	Name   string `json:"name"`
+	Size   int    `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Record describes one send.
+type Record struct {
+	Time     time.Time `json:"time"`
+	Profile  string    `json:"profile"`
+	Provider string    `json:"provider"`
+
+	From        string       `json:"from,omitempty"`
+	To          []string     `json:"to"`
+	Cc          []string     `json:"cc,omitempty"`
+	Bcc         []string     `json:"bcc,omitempty"`
+	Subject     string       `json:"subject"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+
+	// MessageID is the Message-ID header of the message.
+	MessageID string `json:"message_id,omitempty"`
+	// ProviderID is the ID the provider assigned to the sent message, and
+	// ThreadID the thread it was filed under, for providers that have them.
+	ProviderID string `json:"provider_id,omitempty"`
+	ThreadID   string `json:"thread_id,omitempty"`
+
+	Outcome Outcome `json:"outcome"`
+	Error   string  `json:"error,omitempty"`
+}
+
+// Recipients returns every recipient of the record.
+func (r *Record) Recipients() []string {
+	var all []string
+	all = append(all, r.To...)
+	all = append(all, r.Cc...)
+	return append(all, r.Bcc...)
+}
+
+// FromMessage returns a record describing msg, without the outcome.
+func FromMessage(msg *mail.Message) Record {
+	record := Record{
+		From:    msg.From,
+		To:      msg.To,
+		Cc:      msg.Cc,
+		Bcc:     msg.Bcc,
+		Subject: msg.Subject,
+	}
+	for key, value := range msg.Headers {
+		if strings.EqualFold(key, "Message-ID") {
+			record.MessageID = value
+		}
+	}
+	for _, attachment := range msg.Attachments {
+		record.Attachments = append(record.Attachments, newAttachment(attachment.Filename, attachment.Data))
+	}
+	return record
+}
+
+func newAttachment(name string, data []byte) Attachment {
+	sum := sha256.Sum256(data)
+	return Attachment{Name: name, Size: len(data), SHA256: hex.EncodeToString(sum[:])}
+}
+
+// Filter selects records. The zero Filter selects every record.
+type Filter struct {
+	// Since drops records older than it.
+	Since time.Time
+	// To keeps records with a recipient containing it, ignoring case.
+	To string
+	// Profile keeps the records of one profile.
+	Profile string
+	// Failed keeps failed sends only.
+	Failed bool
+}
+
+// Match reports whether the filter selects r.
+func (f Filter) Match(r *Record) bool {
+	if !f.Since.IsZero() && r.Time.Before(f.Since) {
+		return false
+	}
+	if f.Profile != "" && r.Profile != f.Profile {
+		return false
+	}
+	if f.Failed && r.Outcome != Failed {
+		return false
+	}
+	if f.To != "" {
+		for _, rcpt := range r.Recipients() {
+			if strings.Contains(strings.ToLower(rcpt), strings.ToLower(f.To)) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// DefaultMaxSize is the size at which the history file is rotated.
+const DefaultMaxSize = 10 << 20
+
+// Log is the history file. Once it grows past MaxSize it is renamed to
+// <path>.1, the older <path>.1 to <path>.2 and so on. Processes sharing the
+// log take turns through a lock on <path>.lock.
+type Log struct {
+	path string
+	// MaxSize is the size in bytes the file may grow to before it is
+	// rotated.
+	MaxSize int64
+	// Keep is how many rotated files are kept; older ones are deleted when
+	// the file rotates. Zero, the default, keeps every rotated file.
+	Keep int
+
+	mu sync.Mutex
+}
+
+// Open returns the history kept in the file at path, which is created by
+// the first Append.
+func Open(path string) *Log {
+	return &Log{path: path, MaxSize: DefaultMaxSize}
+}
+
+// Append adds a record to the end of the log, rotating it first if the
+// record would make it too large.
+func (l *Log) Append(record Record) error {
+	data, err := json.Marshal(&record)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0700); err != nil {
+		return fmt.Errorf("unable to write history: %v", err)
+	}
+	return l.locked(true, func() error {
+		if err := l.rotate(int64(len(data))); err != nil {
+			return fmt.Errorf("unable to rotate history: %v", err)
+		}
+		file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			return fmt.Errorf("unable to write history: %v", err)
+		}
+		if _, err := file.Write(data); err != nil {
+			file.Close()
+			return fmt.Errorf("unable to write history: %v", err)
+		}
+		return file.Close()
+	})
+}
+
+// rotate moves the file out of the way when adding size bytes would make
+// it larger than MaxSize.
+func (l *Log) rotate(size int64) error {
+	info, err := os.Stat(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Size() == 0 || info.Size()+size <= l.MaxSize {
+		return nil
+	}
+
+	rotated, err := l.rotatedFiles()
+	if err != nil {
+		return err
+	}
+	// Oldest first, so that every file moves to a free name.
+	for _, n := range rotated {
+		if l.Keep > 0 && n >= l.Keep {
+			if err := os.Remove(l.rotated(n)); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := os.Rename(l.rotated(n), l.rotated(n+1)); err != nil {
+			return err
+		}
+	}
+	return os.Rename(l.path, l.rotated(1))
+}
+
+// rotated returns the name of the nth rotated file, 1 being the newest.
+func (l *Log) rotated(n int) string {
+	return fmt.Sprintf("%s.%d", l.path, n)
+}
+
+// rotatedFiles returns the numbers of the rotated files that exist, oldest
+// first.
+func (l *Log) rotatedFiles() ([]int, error) {
+	entries, err := os.ReadDir(filepath.Dir(l.path))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rotated []int
+	for _, entry := range entries {
+		suffix, ok := strings.CutPrefix(entry.Name(), filepath.Base(l.path)+".")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.Atoi(suffix); err == nil && n > 0 && strconv.Itoa(n) == suffix {
+			rotated = append(rotated, n)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(rotated)))
+	return rotated, nil
+}
+
+// files returns the history files, oldest first.
+func (l *Log) files() ([]string, error) {
+	rotated, err := l.rotatedFiles()
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, n := range rotated {
+		files = append(files, l.rotated(n))
+	}
+	return append(files, l.path), nil
+}
+
+// locked runs fn holding the lock of the log, an exclusive one for writing
+// or a shared one for reading.
+func (l *Log) locked(exclusive bool, fn func() error) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return filelock.With(l.path, exclusive, fn)
+}
+
+// List returns the records the filter selects, oldest first. Rotated files
+// last written before filter.Since are not read at all.
+func (l *Log) List(filter Filter) ([]*Record, error) {
+	if _, err := os.Stat(filepath.Dir(l.path)); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	var records []*Record
+	err := l.locked(false, func() error {
+		files, err := l.files()
+		if err != nil {
+			return fmt.Errorf("unable to read history: %v", err)
+		}
+		for _, path := range files {
+			info, err := os.Stat(path)
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("unable to read history: %v", err)
+			}
+			if !filter.Since.IsZero() && info.ModTime().Before(filter.Since) {
+				continue
+			}
+			selected, err := readFile(path, filter)
+			if err != nil {
+				return err
+			}
+			records = append(records, selected...)
+		}
+		return nil
+	})
+	return records, err
+}
+
+// readFile returns the records of one history file the filter selects.
+func readFile(path string, filter Filter) ([]*Record, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read history: %v", err)
+	}
+	defer file.Close()
+
+	var records []*Record
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		record := &Record{}
+		// A crash may leave a partly written last line behind, which is
+		// skipped rather than making the whole history unreadable.
+		if err := json.Unmarshal(scanner.Bytes(), record); err != nil {
+			continue
+		}
+		if filter.Match(record) {
+			records = append(records, record)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read history: %v", err)
+	}
+	return records, nil
+}
@@ -0,0 +1,354 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFilterMatch(t *testing.T) {
+	now := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	record := &Record{
+		Time:    now,
+		Profile: "work",
+		To:      []string{"Bob@Example.com"},
+		Cc:      []string{"carol@example.com"},
+		Bcc:     []string{"audit@example.com"},
+		Outcome: Sent,
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   bool
+	}{
+		{"zero filter", Filter{}, true},
+		{"since before", Filter{Since: now.Add(-time.Hour)}, true},
+		{"since same time", Filter{Since: now}, true},
+		{"since after", Filter{Since: now.Add(time.Second)}, false},
+		{"to ignores case", Filter{To: "bob@example.COM"}, true},
+		{"to part of address", Filter{To: "example.com"}, true},
+		{"to in cc", Filter{To: "carol"}, true},
+		{"to in bcc", Filter{To: "audit@"}, true},
+		{"to no recipient", Filter{To: "dave@example.com"}, false},
+		{"profile", Filter{Profile: "work"}, true},
+		{"other profile", Filter{Profile: "default"}, false},
+		{"failed only", Filter{Failed: true}, false},
+		{"all match", Filter{Since: now.Add(-time.Hour), To: "bob", Profile: "work"}, true},
+		{"one does not match", Filter{Since: now.Add(-time.Hour), To: "bob", Profile: "home"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match(record); got != tt.want {
+				t.Errorf("Match = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	failed := &Record{Time: now, Outcome: Failed, Error: "boom"}
+	if !(Filter{Failed: true}).Match(failed) {
+		t.Error("Failed filter dropped a failed record")
+	}
+}
+
+// testRecord returns a record sent to rcpt at the given time.
+func testRecord(rcpt string, at time.Time) Record {
+	return Record{Time: at, Profile: "default", Provider: "smtp", To: []string{rcpt}, Subject: "hi", Outcome: Sent}
+}
+
+// recipients returns the first recipient of each record.
+func recipients(records []*Record) string {
+	var rcpts []string
+	for _, record := range records {
+		rcpts = append(rcpts, record.To[0])
+	}
+	return strings.Join(rcpts, ",")
+}
+
+func TestAppendList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gomailit", "history.jsonl")
+	log := Open(path)
+
+	if records, err := log.List(Filter{}); err != nil || records != nil {
+		t.Fatalf("List of a missing history = %v, %v", records, err)
+	}
+
+	now := time.Now()
+	for _, rcpt := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		if err := log.Append(testRecord(rcpt, now)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	failed := testRecord("d@example.com", now)
+	failed.Outcome, failed.Error = Failed, "550 rejected"
+	if err := log.Append(failed); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := log.List(Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := recipients(records); got != "a@example.com,b@example.com,c@example.com,d@example.com" {
+		t.Errorf("records = %s", got)
+	}
+	records, err = log.List(Filter{Failed: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Error != "550 rejected" {
+		t.Errorf("failed records = %+v", records)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("history mode = %o", perm)
+	}
+}
+
+func TestListSkipsCorruptLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	log := Open(path)
+	if err := log.Append(testRecord("a@example.com", time.Now())); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString("{\"time\":\"2025-\n")
+	file.Close()
+	if err := log.Append(testRecord("b@example.com", time.Now())); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := log.List(Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := recipients(records); got != "a@example.com,b@example.com" {
+		t.Errorf("records = %s", got)
+	}
+}
+
+func TestRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	log := Open(path)
+
+	now := time.Now()
+	line, err := json.Marshal(testRecord("0@example.com", now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every file holds two records.
+	log.MaxSize = int64(2 * (len(line) + 1))
+	log.Keep = 2
+
+	for i := range 7 {
+		if err := log.Append(testRecord(fmt.Sprintf("%d@example.com", i), now)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() > log.MaxSize {
+			t.Errorf("%s is %d bytes", filepath.Base(name), info.Size())
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("history.jsonl.3 kept: %v", err)
+	}
+
+	records, err := log.List(Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := recipients(records); got != "2@example.com,3@example.com,4@example.com,5@example.com,6@example.com" {
+		t.Errorf("records = %s", got)
+	}
+}
+
+func TestRotationKeepsEverything(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	log := Open(path)
+	if log.Keep != 0 {
+		t.Fatalf("Keep = %d, want 0", log.Keep)
+	}
+	log.MaxSize = 1
+
+	var want []string
+	for i := range 12 {
+		rcpt := fmt.Sprintf("%d@example.com", i)
+		want = append(want, rcpt)
+		if err := log.Append(testRecord(rcpt, time.Now())); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// One record per file: the newest in history.jsonl and the oldest in
+	// history.jsonl.11.
+	for n := 1; n <= 11; n++ {
+		if _, err := os.Stat(fmt.Sprintf("%s.%d", path, n)); err != nil {
+			t.Error(err)
+		}
+	}
+	records, err := log.List(Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := recipients(records); got != strings.Join(want, ",") {
+		t.Errorf("records = %s", got)
+	}
+}
+
+func TestRotationLowerKeep(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	log := Open(path)
+	log.MaxSize = 1
+	for i := range 6 {
+		if err := log.Append(testRecord(fmt.Sprintf("%d@example.com", i), time.Now())); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A missing file in between, and files that are not rotated history.
+	if err := os.Remove(path + ".3"); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{path + ".bak", path + ".07"} {
+		if err := os.WriteFile(name, []byte("{}\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	records, err := log.List(Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := recipients(records); got != "0@example.com,1@example.com,3@example.com,4@example.com,5@example.com" {
+		t.Errorf("records = %s", got)
+	}
+
+	// Lowering Keep deletes every file past it on the next rotation.
+	log.Keep = 2
+	if err := log.Append(testRecord("6@example.com", time.Now())); err != nil {
+		t.Fatal(err)
+	}
+	records, err = log.List(Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := recipients(records); got != "4@example.com,5@example.com,6@example.com" {
+		t.Errorf("records = %s", got)
+	}
+	for _, name := range []string{path + ".3", path + ".4", path + ".5"} {
+		if _, err := os.Stat(name); !os.IsNotExist(err) {
+			t.Errorf("%s kept: %v", filepath.Base(name), err)
+		}
+	}
+	for _, name := range []string{path + ".bak", path + ".07"} {
+		if _, err := os.Stat(name); err != nil {
+			t.Errorf("%s removed: %v", filepath.Base(name), err)
+		}
+	}
+}
+
+func TestListSkipsOldFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	log := Open(path)
+	log.MaxSize = 1
+
+	old := time.Now().Add(-48 * time.Hour)
+	if err := log.Append(testRecord("old@example.com", old)); err != nil {
+		t.Fatal(err)
+	}
+	if err := log.Append(testRecord("new@example.com", time.Now())); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path+".1", old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	// The rotated file is not read at all: a record in it that claims to
+	// be new is not listed.
+	file, err := os.OpenFile(path+".1", os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"time":"` + time.Now().Format(time.RFC3339) + `","to":["stale@example.com"],"outcome":"sent"}` + "\n")
+	file.Close()
+	if err := os.Chtimes(path+".1", old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := log.List(Filter{Since: time.Now().Add(-time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := recipients(records); got != "new@example.com" {
+		t.Errorf("records = %s", got)
+	}
+	records, err = log.List(Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := recipients(records); got != "old@example.com,stale@example.com,new@example.com" {
+		t.Errorf("records = %s", got)
+	}
+}
+
+// TestConcurrentAppend appends through separate Logs, the way separate
+// gomailit processes would, while the file rotates.
+func TestConcurrentAppend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	const writers, each = 8, 25
+
+	var wg sync.WaitGroup
+	for w := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			log := Open(path)
+			log.MaxSize = 4096
+			log.Keep = 100
+			for i := range each {
+				if err := log.Append(testRecord(fmt.Sprintf("%d-%d@example.com", w, i), time.Now())); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	log := Open(path)
+	log.Keep = 100
+	records, err := log.List(Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != writers*each {
+		t.Fatalf("%d records, want %d", len(records), writers*each)
+	}
+	seen := map[string]bool{}
+	for _, record := range records {
+		seen[record.To[0]] = true
+	}
+	if len(seen) != writers*each {
+		t.Errorf("%d distinct records, want %d", len(seen), writers*each)
+	}
+}
@@ -0,0 +1,87 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package history
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	netmail "net/mail"
+	"strings"
+)
+
+// FromRaw returns a record describing a pre-built message delivered from
+// from to recipients, without the outcome. The subject, Message-ID and
+// attachments are read from the message; a message that cannot be parsed
+// is recorded with its envelope only.
+func FromRaw(from string, recipients []string, raw []byte) Record {
+	record := Record{From: from, To: recipients}
+
+	msg, err := netmail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return record
+	}
+	decoder := &mime.WordDecoder{}
+	record.Subject = msg.Header.Get("Subject")
+	if subject, err := decoder.DecodeHeader(record.Subject); err == nil {
+		record.Subject = subject
+	}
+	record.MessageID = msg.Header.Get("Message-ID")
+	record.Attachments = rawAttachments(msg.Header.Get("Content-Type"), msg.Body)
+	return record
+}
+
+// rawAttachments walks a multipart body and returns its parts that carry
+// a file name.
+func rawAttachments(contentType string, body io.Reader) []Attachment {
+	mediaType, params, _ := mime.ParseMediaType(contentType)
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		return nil
+	}
+
+	var attachments []Attachment
+	reader := multipart.NewReader(body, params["boundary"])
+	for {
+		part, err := reader.NextRawPart()
+		if err != nil {
+			return attachments
+		}
+		if nested := rawAttachments(part.Header.Get("Content-Type"), part); nested != nil {
+			attachments = append(attachments, nested...)
+		} else if name := partFilename(part); name != "" {
+			data, _ := io.ReadAll(decode(part, part.Header.Get("Content-Transfer-Encoding")))
+			attachments = append(attachments, newAttachment(name, data))
+		}
+	}
+}
+
+// partFilename returns the file name of a part from its Content-Disposition
+// or, failing that, the name parameter of its Content-Type.
+func partFilename(part *multipart.Part) string {
+	if name := part.FileName(); name != "" {
+		return name
+	}
+	_, params, err := mime.ParseMediaType(part.Header.Get("Content-Type"))
+	if err != nil {
+		return ""
+	}
+	name, err := (&mime.WordDecoder{}).DecodeHeader(params["name"])
+	if err != nil {
+		return params["name"]
+	}
+	return name
+}
+
+func decode(r io.Reader, encoding string) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, r)
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	}
+	return r
+}
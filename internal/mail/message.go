@@ -0,0 +1,126 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package mail
+
+import (
+	"fmt"
+	netmail "net/mail"
+	"os"
+	"path/filepath"
+)
+
+// Message is a provider-neutral email. Providers translate it into whatever
+// their backend expects (a raw RFC 5322 stream, a JSON payload, ...).
+type Message struct {
+	From    string   `json:"from,omitempty"`
+	To      []string `json:"to,omitempty"`
+	Cc      []string `json:"cc,omitempty"`
+	Bcc     []string `json:"bcc,omitempty"`
+	ReplyTo []string `json:"reply_to,omitempty"`
+	Subject string   `json:"subject"`
+
+	// TextBody and HTMLBody hold the plain-text and HTML versions of the
+	// body. Either may be empty, but not both.
+	TextBody string `json:"text_body,omitempty"`
+	HTMLBody string `json:"html_body,omitempty"`
+
+	// Attachments with a ContentID are embedded in the HTML body, the
+	// others are regular attachments.
+	Attachments []Attachment `json:"attachments,omitempty"`
+
+	// Headers holds extra headers such as List-Unsubscribe or X-Campaign.
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// Attachment is a file attached to a Message.
+type Attachment struct {
+	Filename string `json:"filename"`
+	// ContentType may be left empty, in which case it is detected when the
+	// message is built.
+	ContentType string `json:"content_type,omitempty"`
+	Data        []byte `json:"data"`
+	// ContentID makes the attachment an inline part of the HTML body that
+	// is referenced as cid:<ContentID>.
+	ContentID string `json:"content_id,omitempty"`
+}
+
+// Recipients returns every envelope recipient of the message, including
+// Bcc recipients.
+func (m *Message) Recipients() []string {
+	recipients := make([]string, 0, len(m.To)+len(m.Cc)+len(m.Bcc))
+	recipients = append(recipients, m.To...)
+	recipients = append(recipients, m.Cc...)
+	recipients = append(recipients, m.Bcc...)
+	return recipients
+}
+
+// SetHeader sets a custom header on the message.
+func (m *Message) SetHeader(key, value string) {
+	if m.Headers == nil {
+		m.Headers = make(map[string]string)
+	}
+	m.Headers[key] = value
+}
+
+// Validate checks that the message can be sent.
+func (m *Message) Validate() error {
+	if len(m.Recipients()) == 0 {
+		return fmt.Errorf("message has no recipients")
+	}
+	return nil
+}
+
+// LoadAttachment reads the file at path into an Attachment.
+func LoadAttachment(path string) (Attachment, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Attachment{}, fmt.Errorf("unable to read attachment %s: %v", path, err)
+	}
+	return Attachment{Filename: filepath.Base(path), Data: data}, nil
+}
+
+// LoadAttachments reads every file in paths into Attachments.
+func LoadAttachments(paths []string) ([]Attachment, error) {
+	attachments := make([]Attachment, 0, len(paths))
+	for _, path := range paths {
+		attachment, err := LoadAttachment(path)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, attachment)
+	}
+	return attachments, nil
+}
+
+// BareAddress returns the addr-spec of an address such as
+// "Alice <alice@example.com>". Unparseable input is returned unchanged.
+func BareAddress(address string) string {
+	parsed, err := netmail.ParseAddress(address)
+	if err != nil {
+		return address
+	}
+	return parsed.Address
+}
+
+// ParseAddressList parses flag values that may each hold one or more
+// comma-separated addresses and returns the individual addresses.
+func ParseAddressList(values []string) ([]string, error) {
+	var addresses []string
+	for _, value := range values {
+		parsed, err := netmail.ParseAddressList(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address %q: %v", value, err)
+		}
+		for _, address := range parsed {
+			// Display names are kept readable here; they are RFC 2047
+			// encoded when the message is built.
+			if address.Name == "" {
+				addresses = append(addresses, address.Address)
+			} else {
+				addresses = append(addresses, fmt.Sprintf("%q <%s>", address.Name, address.Address))
+			}
+		}
+	}
+	return addresses, nil
+}
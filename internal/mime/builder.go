@@ -0,0 +1,471 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+
+// Package mime renders a mail.Message as an RFC 5322 / MIME byte stream
+// that any provider can submit.
+package mime
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	stdmime "mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/http"
+	netmail "net/mail"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/latocchi/gomailit/internal/mail"
+)
+
+// Builder renders messages. The zero value is ready to use; the function
+// fields exist so that output can be made deterministic.
+type Builder struct {
+	// IncludeBcc writes the Bcc header. Only enable it for backends such as
+	// the Gmail API that read the envelope from the headers and strip Bcc
+	// themselves.
+	IncludeBcc bool
+
+	// Now returns the time used for the Date header. Defaults to time.Now.
+	Now func() time.Time
+	// Boundary returns a new multipart boundary. Defaults to a random one.
+	Boundary func() string
+	// MessageID returns a new Message-ID for the given sender. Defaults to
+	// NewMessageID.
+	MessageID func(from string) string
+}
+
+// Build renders msg with the default Builder.
+func Build(msg *mail.Message) ([]byte, error) {
+	return (&Builder{}).Build(msg)
+}
+
+// Build renders msg as an RFC 5322 message.
+func (b *Builder) Build(msg *mail.Message) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := b.WriteTo(&buf, msg); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteTo renders msg as an RFC 5322 message to w.
+func (b *Builder) WriteTo(w io.Writer, msg *mail.Message) error {
+	header, err := b.header(msg)
+	if err != nil {
+		return err
+	}
+
+	root, err := b.body(msg)
+	if err != nil {
+		return err
+	}
+	for key, values := range root.header {
+		header[key] = values
+	}
+
+	if err := writeHeader(w, header); err != nil {
+		return err
+	}
+	return b.writePart(w, root)
+}
+
+// header returns the top-level headers of msg, without the Content-Type of
+// the body.
+func (b *Builder) header(msg *mail.Message) (textproto.MIMEHeader, error) {
+	header := textproto.MIMEHeader{}
+
+	addressHeaders := map[string][]string{
+		"To":       msg.To,
+		"Cc":       msg.Cc,
+		"Reply-To": msg.ReplyTo,
+	}
+	if msg.From != "" {
+		addressHeaders["From"] = []string{msg.From}
+	}
+	if b.IncludeBcc {
+		addressHeaders["Bcc"] = msg.Bcc
+	}
+	for key, addresses := range addressHeaders {
+		if len(addresses) == 0 {
+			continue
+		}
+		value, err := FormatAddressList(addresses)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s address: %v", key, err)
+		}
+		header.Set(key, value)
+	}
+
+	header.Set("Subject", EncodeHeader(msg.Subject))
+
+	for key, value := range msg.Headers {
+		if err := validHeaderName(key); err != nil {
+			return nil, err
+		}
+		header.Set(key, EncodeHeader(value))
+	}
+
+	if header.Get("Date") == "" {
+		now := time.Now
+		if b.Now != nil {
+			now = b.Now
+		}
+		header.Set("Date", now().Format(time.RFC1123Z))
+	}
+	if header.Get("Message-Id") == "" {
+		newID := NewMessageID
+		if b.MessageID != nil {
+			newID = b.MessageID
+		}
+		header.Set("Message-Id", newID(msg.From))
+	}
+	header.Set("MIME-Version", "1.0")
+
+	return header, nil
+}
+
+// part is a node of the MIME tree. Leaves carry a body, multipart nodes
+// carry children.
+type part struct {
+	header   textproto.MIMEHeader
+	body     []byte
+	children []*part
+	boundary string
+}
+
+// body builds the MIME tree of msg:
+//
+//	multipart/mixed              (only with attachments)
+//	  multipart/alternative      (only with both text and HTML)
+//	    text/plain
+//	    multipart/related        (only with inline images)
+//	      text/html
+//	      inline images...
+//	  attachments...
+func (b *Builder) body(msg *mail.Message) (*part, error) {
+	var inline, attached []*part
+	for _, attachment := range msg.Attachments {
+		if attachment.ContentID != "" && msg.HTMLBody != "" {
+			inline = append(inline, inlinePart(attachment))
+		} else {
+			attached = append(attached, attachmentPart(attachment))
+		}
+	}
+
+	var html *part
+	if msg.HTMLBody != "" {
+		html = textPart("html", msg.HTMLBody)
+		if len(inline) > 0 {
+			html = b.multipart("related", append([]*part{html}, inline...)...)
+		}
+	}
+
+	var content *part
+	switch {
+	case msg.TextBody != "" && html != nil:
+		content = b.multipart("alternative", textPart("plain", msg.TextBody), html)
+	case html != nil:
+		content = html
+	default:
+		content = textPart("plain", msg.TextBody)
+	}
+
+	if len(attached) == 0 {
+		return content, nil
+	}
+	return b.multipart("mixed", append([]*part{content}, attached...)...), nil
+}
+
+func (b *Builder) multipart(subtype string, children ...*part) *part {
+	boundary := randomBoundary()
+	if b.Boundary != nil {
+		boundary = b.Boundary()
+	}
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", stdmime.FormatMediaType("multipart/"+subtype, map[string]string{"boundary": boundary}))
+	return &part{header: header, children: children, boundary: boundary}
+}
+
+func textPart(subtype, body string) *part {
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", fmt.Sprintf("text/%s; charset=\"UTF-8\"", subtype))
+	header.Set("Content-Transfer-Encoding", "quoted-printable")
+
+	var buf bytes.Buffer
+	qp := quotedprintable.NewWriter(&buf)
+	qp.Write([]byte(body))
+	qp.Close()
+
+	return &part{header: header, body: buf.Bytes()}
+}
+
+func attachmentPart(attachment mail.Attachment) *part {
+	contentType := attachment.ContentType
+	if contentType == "" {
+		contentType = DetectContentType(attachment.Filename, attachment.Data)
+	}
+
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", formatMediaType(contentType, "name", attachment.Filename))
+	header.Set("Content-Disposition", formatMediaType("attachment", "filename", attachment.Filename))
+	header.Set("Content-Transfer-Encoding", "base64")
+
+	return &part{header: header, body: encodeBase64Lines(attachment.Data)}
+}
+
+func inlinePart(attachment mail.Attachment) *part {
+	p := attachmentPart(attachment)
+	p.header.Set("Content-Disposition", formatMediaType("inline", "filename", attachment.Filename))
+	p.header.Set("Content-ID", "<"+attachment.ContentID+">")
+	return p
+}
+
+// formatMediaType adds a parameter to a media type such as
+// "text/plain; charset=utf-8", RFC 2231-encoding the value when needed.
+func formatMediaType(value, param, paramValue string) string {
+	mediaType, params, err := stdmime.ParseMediaType(value)
+	if err != nil {
+		mediaType, params = "application/octet-stream", map[string]string{}
+	}
+	params[param] = paramValue
+	return stdmime.FormatMediaType(mediaType, params)
+}
+
+func (b *Builder) writePart(w io.Writer, p *part) error {
+	if p.children == nil {
+		_, err := w.Write(p.body)
+		return err
+	}
+
+	mw := multipart.NewWriter(w)
+	if err := mw.SetBoundary(p.boundary); err != nil {
+		return fmt.Errorf("invalid boundary %q: %v", p.boundary, err)
+	}
+	for _, child := range p.children {
+		pw, err := mw.CreatePart(child.header)
+		if err != nil {
+			return err
+		}
+		if err := b.writePart(pw, child); err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+// headerOrder is the order in which well-known headers are written. Other
+// headers follow in alphabetical order.
+var headerOrder = []string{
+	"From", "Sender", "To", "Cc", "Bcc", "Reply-To", "Subject", "Date", "Message-Id",
+	"In-Reply-To", "References", "Mime-Version", "Content-Type", "Content-Transfer-Encoding",
+}
+
+func writeHeader(w io.Writer, header textproto.MIMEHeader) error {
+	var keys []string
+	seen := map[string]bool{}
+	for _, key := range headerOrder {
+		if _, ok := header[key]; ok {
+			keys = append(keys, key)
+			seen[key] = true
+		}
+	}
+	var rest []string
+	for key := range header {
+		if !seen[key] {
+			rest = append(rest, key)
+		}
+	}
+	sort.Strings(rest)
+	keys = append(keys, rest...)
+
+	var buf bytes.Buffer
+	for _, key := range keys {
+		name := key
+		switch key {
+		case "Message-Id":
+			name = "Message-ID"
+		case "Mime-Version":
+			name = "MIME-Version"
+		}
+		for _, value := range header[key] {
+			buf.WriteString(foldHeader(name, sanitize(value)))
+		}
+	}
+	buf.WriteString("\r\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// validHeaderName rejects names of custom headers that would break the
+// header section, such as ones containing a line break or a colon.
+func validHeaderName(name string) error {
+	if name == "" {
+		return fmt.Errorf("invalid header name: empty")
+	}
+	for _, c := range []byte(name) {
+		// RFC 5322 field names are printable US-ASCII except the colon.
+		if c < 33 || c > 126 || c == ':' {
+			return fmt.Errorf("invalid header name %q", name)
+		}
+	}
+	return nil
+}
+
+// maxEncodedWord is the longest an RFC 2047 encoded-word may be.
+const maxEncodedWord = 75
+
+// EncodeHeader RFC 2047-encodes an unstructured header value if it contains
+// non-ASCII characters. Long values are split into several encoded-words
+// so that the header can be folded.
+func EncodeHeader(value string) string {
+	value = sanitize(value)
+	encoded := stdmime.QEncoding.Encode("UTF-8", value)
+	if encoded == value || len(encoded) <= maxEncodedWord {
+		return encoded
+	}
+
+	var words []string
+	var chunk, text string
+	for _, r := range value {
+		next := qEncodeWord(text + string(r))
+		if len(next) > maxEncodedWord && text != "" {
+			words = append(words, chunk)
+			text = ""
+			next = qEncodeWord(string(r))
+		}
+		text += string(r)
+		chunk = next
+	}
+	words = append(words, chunk)
+	// The whitespace between adjacent encoded-words is not part of the
+	// decoded text.
+	return strings.Join(words, " ")
+}
+
+// qEncodeWord returns s as a single "Q" encoded-word, even when s is plain
+// ASCII, since the words of a split value must all be encoded.
+func qEncodeWord(s string) string {
+	var buf strings.Builder
+	buf.WriteString("=?UTF-8?q?")
+	for _, c := range []byte(s) {
+		switch {
+		case c == ' ':
+			buf.WriteByte('_')
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9', strings.IndexByte("!*+-/", c) >= 0:
+			buf.WriteByte(c)
+		default:
+			fmt.Fprintf(&buf, "=%02X", c)
+		}
+	}
+	buf.WriteString("?=")
+	return buf.String()
+}
+
+// FormatAddressList parses addresses and formats them as an address list
+// header value, RFC 2047-encoding display names where needed.
+func FormatAddressList(addresses []string) (string, error) {
+	formatted := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		parsed, err := netmail.ParseAddress(address)
+		if err != nil {
+			return "", fmt.Errorf("%s: %v", address, err)
+		}
+		if parsed.Name == "" {
+			formatted = append(formatted, parsed.Address)
+		} else {
+			formatted = append(formatted, parsed.String())
+		}
+	}
+	return strings.Join(formatted, ", "), nil
+}
+
+// DetectContentType guesses the media type of an attachment from its file
+// extension, falling back to sniffing its content.
+func DetectContentType(filename string, data []byte) string {
+	if contentType := stdmime.TypeByExtension(strings.ToLower(filepath.Ext(filename))); contentType != "" {
+		return contentType
+	}
+	return http.DetectContentType(data)
+}
+
+// NewMessageID returns a new globally unique Message-ID using the domain of
+// from, or the local host name when from has none.
+func NewMessageID(from string) string {
+	domain := ""
+	address := mail.BareAddress(from)
+	if at := strings.LastIndex(address, "@"); at >= 0 {
+		domain = address[at+1:]
+	}
+	if domain == "" {
+		domain, _ = os.Hostname()
+	}
+	if domain == "" {
+		domain = "localhost"
+	}
+	return fmt.Sprintf("<%d.%s@%s>", time.Now().UnixNano(), randomHex(8), domain)
+}
+
+func randomBoundary() string {
+	return randomHex(15)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// sanitize strips line breaks so header values cannot inject headers.
+func sanitize(value string) string {
+	return strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ").Replace(value)
+}
+
+// foldHeader writes a header field, folding it at whitespace so that lines
+// stay within the recommended 78 characters where possible.
+func foldHeader(name, value string) string {
+	const limit = 78
+
+	var buf strings.Builder
+	line := name + ":"
+	for _, word := range strings.Split(value, " ") {
+		if len(line)+1+len(word) > limit && strings.TrimSpace(line) != name+":" {
+			buf.WriteString(line)
+			buf.WriteString("\r\n")
+			line = " " + word
+			continue
+		}
+		line += " " + word
+	}
+	buf.WriteString(line)
+	buf.WriteString("\r\n")
+	return buf.String()
+}
+
+func encodeBase64Lines(data []byte) []byte {
+	encoded := make([]byte, base64.StdEncoding.EncodedLen(len(data)))
+	base64.StdEncoding.Encode(encoded, data)
+
+	var buf bytes.Buffer
+	for i := 0; i < len(encoded); i += 76 {
+		end := i + 76
+		if end > len(encoded) {
+			end = len(encoded)
+		}
+		buf.Write(encoded[i:end])
+		buf.WriteString("\r\n")
+	}
+	return buf.Bytes()
+}
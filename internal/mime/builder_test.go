@@ -0,0 +1,199 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package mime
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	stdmime "mime"
+	netmail "net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/latocchi/gomailit/internal/mail"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// pngData starts with the PNG signature, so that it is sniffed as a PNG.
+var pngData = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01")
+
+// testBuilder returns a Builder whose output only depends on the message.
+func testBuilder(includeBcc bool) *Builder {
+	n := 0
+	return &Builder{
+		IncludeBcc: includeBcc,
+		Now:        func() time.Time { return time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC) },
+		Boundary: func() string {
+			n++
+			return fmt.Sprintf("boundary-%d", n)
+		},
+		MessageID: func(from string) string { return "<test@example.com>" },
+	}
+}
+
+func TestBuildGolden(t *testing.T) {
+	tests := []struct {
+		name       string
+		includeBcc bool
+		msg        *mail.Message
+	}{
+		{
+			name: "plain",
+			msg: &mail.Message{
+				From:     "alice@example.com",
+				To:       []string{"bob@example.com"},
+				Bcc:      []string{"audit@example.com"},
+				Subject:  "Hello",
+				TextBody: "Hi Bob,\n\nsee you tomorrow.\n",
+				Headers:  map[string]string{"X-Campaign": "june"},
+			},
+		},
+		{
+			name:       "bcc-included",
+			includeBcc: true,
+			msg: &mail.Message{
+				From:     "alice@example.com",
+				To:       []string{"bob@example.com"},
+				Bcc:      []string{"audit@example.com"},
+				Subject:  "Hello",
+				TextBody: "Hi Bob",
+			},
+		},
+		{
+			name: "alternative",
+			msg: &mail.Message{
+				From:     "alice@example.com",
+				To:       []string{"bob@example.com"},
+				Subject:  "Newsletter",
+				TextBody: "Hello in plain text",
+				HTMLBody: "<p>Hello in <b>HTML</b></p>",
+			},
+		},
+		{
+			name: "mixed",
+			msg: &mail.Message{
+				From:     "alice@example.com",
+				To:       []string{"bob@example.com"},
+				Subject:  "Files",
+				TextBody: "See attached",
+				Attachments: []mail.Attachment{
+					{Filename: "report.pdf", Data: []byte("%PDF-1.4 report")},
+					{Filename: "chart", Data: pngData},
+					{Filename: "notes", Data: []byte("plain notes")},
+					{Filename: "data.bin", ContentType: "application/x-custom", Data: []byte{0, 1, 2}},
+				},
+			},
+		},
+		{
+			name: "inline",
+			msg: &mail.Message{
+				From:     "alice@example.com",
+				To:       []string{"bob@example.com"},
+				Subject:  "Logo",
+				TextBody: "Our logo",
+				HTMLBody: `<p>Our logo</p><img src="cid:logo">`,
+				Attachments: []mail.Attachment{
+					{Filename: "logo.png", Data: pngData, ContentID: "logo"},
+					{Filename: "terms.pdf", Data: []byte("%PDF-1.4 terms")},
+				},
+			},
+		},
+		{
+			name: "encoded",
+			msg: &mail.Message{
+				From: `"Jürgen Müller" <juergen@example.com>`,
+				To: []string{
+					`"Zoë Ångström" <zoe@example.com>`,
+					"first.recipient@example.com", "second.recipient@example.com",
+					"third.recipient@example.com", "fourth.recipient@example.com",
+				},
+				Subject:  "Grüße aus München – die Rechnung für Juni ist da, bitte prüfen Sie die Beträge",
+				TextBody: "Grüße",
+				Headers:  map[string]string{"X-Note": "Ünïcödé header value"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := testBuilder(tt.includeBcc).Build(tt.msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			golden := filepath.Join("testdata", tt.name+".eml")
+			if *update {
+				if err := os.WriteFile(golden, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v (run go test -update to create it)", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("output differs from %s:\n%s", golden, got)
+			}
+
+			for i, line := range strings.Split(string(got), "\r\n") {
+				if len(line) > 998 {
+					t.Errorf("line %d is %d characters long", i+1, len(line))
+				}
+			}
+			if _, err := netmail.ReadMessage(bytes.NewReader(got)); err != nil {
+				t.Errorf("output does not parse: %v", err)
+			}
+		})
+	}
+}
+
+func TestBuildExcludesBcc(t *testing.T) {
+	msg := &mail.Message{To: []string{"bob@example.com"}, Bcc: []string{"audit@example.com"}, TextBody: "x"}
+	raw, err := Build(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(raw, []byte("audit@example.com")) {
+		t.Errorf("Bcc recipient in the message:\n%s", raw)
+	}
+}
+
+func TestBuildRejectsInvalidHeaderNames(t *testing.T) {
+	for _, name := range []string{"", "X-Foo\r\nBcc", "X-Foo\nBcc", "X Foo", "X-Foo:", "X-Föo", "X-Foo\t"} {
+		msg := &mail.Message{To: []string{"bob@example.com"}, TextBody: "x", Headers: map[string]string{name: "value"}}
+		if _, err := Build(msg); err == nil {
+			t.Errorf("header name %q was accepted", name)
+		}
+	}
+}
+
+func TestEncodeHeader(t *testing.T) {
+	decoder := &stdmime.WordDecoder{}
+	for _, value := range []string{
+		"plain ASCII stays as is",
+		"Grüße",
+		"Grüße aus München – die Rechnung für Juni ist da, bitte prüfen Sie die Beträge sorgfältig",
+		strings.Repeat("ä", 100),
+		"line\r\nbreak",
+	} {
+		encoded := EncodeHeader(value)
+		for _, word := range strings.Fields(encoded) {
+			if strings.HasPrefix(word, "=?") && len(word) > maxEncodedWord {
+				t.Errorf("encoded-word of %d characters: %s", len(word), word)
+			}
+		}
+		decoded, err := decoder.DecodeHeader(encoded)
+		if err != nil {
+			t.Fatalf("DecodeHeader(%q): %v", encoded, err)
+		}
+		if want := sanitize(value); decoded != want {
+			t.Errorf("EncodeHeader(%q) decodes to %q", value, decoded)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package mime
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	netmail "net/mail"
+	"strings"
+)
+
+// Envelope holds the SMTP envelope of a message: who it is from and who it
+// is delivered to, independently of its headers.
+type Envelope struct {
+	From       string
+	Recipients []string
+}
+
+// ParseEnvelope reads the envelope of a complete RFC 5322 message from its
+// From (or Sender) and To, Cc and Bcc headers.
+func ParseEnvelope(raw []byte) (Envelope, error) {
+	msg, err := netmail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return Envelope{}, fmt.Errorf("unable to parse message: %v", err)
+	}
+
+	var env Envelope
+	for _, key := range []string{"Sender", "From"} {
+		if msg.Header.Get(key) == "" {
+			continue
+		}
+		addresses, err := msg.Header.AddressList(key)
+		if err != nil {
+			return Envelope{}, fmt.Errorf("invalid %s header: %v", key, err)
+		}
+		if len(addresses) > 0 {
+			env.From = addresses[0].Address
+			break
+		}
+	}
+
+	for _, key := range []string{"To", "Cc", "Bcc"} {
+		if msg.Header.Get(key) == "" {
+			continue
+		}
+		addresses, err := msg.Header.AddressList(key)
+		if err != nil {
+			return Envelope{}, fmt.Errorf("invalid %s header: %v", key, err)
+		}
+		for _, address := range addresses {
+			env.Recipients = append(env.Recipients, address.Address)
+		}
+	}
+	return env, nil
+}
+
+// StripHeader removes every occurrence of a header, including its folded
+// continuation lines, from a raw message. It is used to drop Bcc before
+// handing a message to an SMTP server.
+func StripHeader(raw []byte, name string) []byte {
+	var out bytes.Buffer
+	reader := bufio.NewReader(bytes.NewReader(raw))
+
+	skipping := false
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			trimmed := strings.TrimRight(string(line), "\r\n")
+			if trimmed == "" {
+				// End of the header section, copy the body unchanged.
+				out.Write(line)
+				rest, _ := io.ReadAll(reader)
+				out.Write(rest)
+				return out.Bytes()
+			}
+
+			continuation := line[0] == ' ' || line[0] == '\t'
+			if !continuation {
+				colon := strings.IndexByte(trimmed, ':')
+				skipping = colon > 0 && strings.EqualFold(strings.TrimSpace(trimmed[:colon]), name)
+			}
+			if !skipping {
+				out.Write(line)
+			}
+		}
+		if err != nil {
+			return out.Bytes()
+		}
+	}
+}
+
+// HeaderValue returns the first value of a header in a raw message, or ""
+// when the message has no such header.
+func HeaderValue(raw []byte, name string) string {
+	msg, err := netmail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return ""
+	}
+	return msg.Header.Get(name)
+}
+
+// PrependHeader adds a header field at the top of a raw message.
+func PrependHeader(raw []byte, name, value string) []byte {
+	field := foldHeader(name, sanitize(value))
+	return append([]byte(field), raw...)
+}
@@ -0,0 +1,401 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+
+// Package outbox is a durable on-disk queue that records the delivery state
+// of every message, so that bulk sends can be retried and resumed.
+package outbox
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/latocchi/gomailit/internal/mail"
+)
+
+// State is the delivery state of an entry.
+type State string
+
+const (
+	Pending State = "pending"
+	Sent    State = "sent"
+	Failed  State = "failed"
+)
+
+// Entry is a queued message and its delivery state.
+type Entry struct {
+	ID       string        `json:"id"`
+	State    State         `json:"state"`
+	Provider string        `json:"provider"`
+	Message  *mail.Message `json:"message"`
+	// Run is the run that queued the entry, until that run has sent all of
+	// its messages.
+	Run string `json:"run,omitempty"`
+
+	Attempts  int    `json:"attempts"`
+	LastError string `json:"last_error,omitempty"`
+	// ProviderID is the ID the provider assigned to the sent message.
+	ProviderID string `json:"provider_id,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Outbox stores one JSON file per entry in a directory.
+type Outbox struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// Open opens the outbox in dir, creating the directory if needed.
+func Open(dir string) (*Outbox, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("unable to create outbox: %v", err)
+	}
+	return &Outbox{dir: dir}, nil
+}
+
+// Key returns the ID an entry for msg sent through provider gets. Sending
+// the same message through the same provider again yields the same key.
+func Key(provider string, msg *mail.Message) string {
+	copied := *msg
+	copied.Headers = make(map[string]string, len(msg.Headers))
+	for key, value := range msg.Headers {
+		// Message-ID is generated when the message is queued.
+		if !strings.EqualFold(key, "Message-ID") {
+			copied.Headers[key] = value
+		}
+	}
+
+	h := sha256.New()
+	h.Write([]byte(provider))
+	h.Write([]byte{0})
+	json.NewEncoder(h).Encode(&copied)
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// RunID identifies a run that sends msgs through provider. Running the same
+// send again yields the same ID, which is what lets an interrupted run
+// resume without sending a message twice.
+func RunID(provider string, msgs []*mail.Message) string {
+	keys := make([]string, 0, len(msgs))
+	for _, msg := range msgs {
+		keys = append(keys, Key(provider, msg))
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	for _, key := range keys {
+		h.Write([]byte(key))
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// Enqueue adds msg to the outbox as part of run. When the message is still
+// pending or failed, or was sent by run before it was interrupted, the
+// existing entry is returned instead and existing is true. A message that an
+// earlier, finished run sent is queued again. The same message queued twice
+// returns an entry with the same ID each time, which the caller delivers
+// only once.
+func (o *Outbox) Enqueue(run, provider string, msg *mail.Message, messageID string) (entry *Entry, existing bool, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	id := Key(provider, msg)
+	if entry, err := o.get(id); err == nil {
+		if entry.State != Sent || entry.Run == run {
+			entry.Run = run
+			return entry, true, o.save(entry)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, false, err
+	}
+
+	// The Message-ID is fixed here so that retries reuse it and receivers
+	// can recognise duplicates.
+	copied := *msg
+	copied.Headers = make(map[string]string, len(msg.Headers)+1)
+	for key, value := range msg.Headers {
+		copied.Headers[key] = value
+	}
+	if messageID != "" {
+		copied.Headers["Message-ID"] = messageID
+	}
+
+	now := time.Now()
+	entry = &Entry{
+		ID:        id,
+		State:     Pending,
+		Provider:  provider,
+		Message:   &copied,
+		Run:       run,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	return entry, false, o.save(entry)
+}
+
+// EndRuns finishes every run of entries that has sent all of its
+// messages, so that running the same send later sends them again.
+func (o *Outbox) EndRuns(entries []*Entry) error {
+	runs := map[string]bool{}
+	for _, entry := range entries {
+		if entry.Run != "" {
+			runs[entry.Run] = true
+		}
+	}
+	if len(runs) == 0 {
+		return nil
+	}
+
+	all, err := o.List()
+	if err != nil {
+		return err
+	}
+	for _, entry := range all {
+		if runs[entry.Run] && entry.State != Sent {
+			delete(runs, entry.Run)
+		}
+	}
+	for _, entry := range all {
+		if runs[entry.Run] {
+			entry.Run = ""
+			if err := o.Save(entry); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Save writes entry back to the outbox.
+func (o *Outbox) Save(entry *Entry) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	entry.UpdatedAt = time.Now()
+	return o.save(entry)
+}
+
+// Get returns the entry with the given ID.
+func (o *Outbox) Get(id string) (*Entry, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.get(id)
+}
+
+// List returns every entry in one of states, or every entry when no state
+// is given, oldest first.
+func (o *Outbox) List(states ...State) ([]*Entry, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	files, err := filepath.Glob(filepath.Join(o.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*Entry
+	for _, file := range files {
+		entry, err := o.get(strings.TrimSuffix(filepath.Base(file), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		if len(states) == 0 || hasState(entry, states) {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].CreatedAt.Before(entries[j].CreatedAt) })
+	return entries, nil
+}
+
+// Delete removes the entry with the given ID.
+func (o *Outbox) Delete(id string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return os.Remove(o.path(id))
+}
+
+// Purge removes every entry in one of states and returns how many were
+// removed.
+func (o *Outbox) Purge(states ...State) (int, error) {
+	entries, err := o.List(states...)
+	if err != nil {
+		return 0, err
+	}
+	for i, entry := range entries {
+		if err := o.Delete(entry.ID); err != nil {
+			return i, err
+		}
+	}
+	return len(entries), nil
+}
+
+func (o *Outbox) path(id string) string {
+	return filepath.Join(o.dir, id+".json")
+}
+
+func (o *Outbox) get(id string) (*Entry, error) {
+	data, err := os.ReadFile(o.path(id))
+	if err != nil {
+		return nil, err
+	}
+	entry := &Entry{}
+	if err := json.Unmarshal(data, entry); err != nil {
+		return nil, fmt.Errorf("corrupt outbox entry %s: %v", id, err)
+	}
+	return entry, nil
+}
+
+// save writes the entry to a temporary file first so that a crash never
+// leaves a half-written entry behind.
+func (o *Outbox) save(entry *Entry) error {
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(o.dir, entry.ID+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("unable to write outbox entry: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("unable to write outbox entry: %v", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("unable to write outbox entry: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("unable to write outbox entry: %v", err)
+	}
+	return os.Rename(tmp.Name(), o.path(entry.ID))
+}
+
+func hasState(entry *Entry, states []State) bool {
+	for _, state := range states {
+		if entry.State == state {
+			return true
+		}
+	}
+	return false
+}
+
+// Sender delivers a message and returns the provider's message ID.
+// providers.Provider satisfies it.
+type Sender interface {
+	Send(ctx context.Context, msg *mail.Message) (string, error)
+}
+
+// RetryPolicy controls how Deliver retries transient errors.
+type RetryPolicy struct {
+	// Attempts is the maximum number of attempts per Deliver call.
+	Attempts int
+	// Initial is the delay before the first retry. It doubles with every
+	// further retry up to Max.
+	Initial time.Duration
+	Max     time.Duration
+	// IsTransient reports whether an error is worth retrying.
+	IsTransient func(error) bool
+	// RetryAfter returns how long the provider asked to wait before the
+	// next attempt, or zero. A longer wait than the backoff is honoured.
+	RetryAfter func(error) time.Duration
+	// Postpone reports whether an error means the message can only be sent
+	// later, such as a used up daily quota. The entry then stays pending.
+	Postpone func(error) bool
+}
+
+// DefaultRetryPolicy retries transient errors three times, waiting 2s, 4s
+// and 8s (plus jitter) in between.
+func DefaultRetryPolicy(isTransient func(error) bool) RetryPolicy {
+	return RetryPolicy{Attempts: 4, Initial: 2 * time.Second, Max: time.Minute, IsTransient: isTransient}
+}
+
+// Deliver sends the message of entry, retrying transient errors with
+// exponential backoff, and records the outcome. When ctx is cancelled the
+// entry stays pending so that it can be resumed.
+func (o *Outbox) Deliver(ctx context.Context, sender Sender, entry *Entry, policy RetryPolicy) error {
+	if entry.State == Sent {
+		return nil
+	}
+	entry.State = Pending
+
+	delay := policy.Initial
+	for attempt := 1; ; attempt++ {
+		entry.Attempts++
+		id, err := sender.Send(ctx, entry.Message)
+		if err == nil {
+			entry.State = Sent
+			entry.ProviderID = id
+			entry.LastError = ""
+			return o.Save(entry)
+		}
+
+		entry.LastError = err.Error()
+		if ctx.Err() != nil || (policy.Postpone != nil && policy.Postpone(err)) {
+			entry.State = Pending
+			if saveErr := o.Save(entry); saveErr != nil {
+				return saveErr
+			}
+			return err
+		}
+
+		transient := policy.IsTransient != nil && policy.IsTransient(err)
+		if !transient || attempt >= policy.Attempts {
+			entry.State = Failed
+			if saveErr := o.Save(entry); saveErr != nil {
+				return saveErr
+			}
+			return err
+		}
+
+		if err := o.Save(entry); err != nil {
+			return err
+		}
+		wait := jitter(delay)
+		if policy.RetryAfter != nil {
+			wait = max(wait, policy.RetryAfter(err))
+		}
+		if err := sleep(ctx, wait); err != nil {
+			return err
+		}
+		delay *= 2
+		if policy.Max > 0 && delay > policy.Max {
+			delay = policy.Max
+		}
+	}
+}
+
+// jitter spreads retries of concurrent senders by up to 20%.
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return d + time.Duration(rand.Int64N(int64(d)/5+1))
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,202 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package outbox
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/latocchi/gomailit/internal/mail"
+)
+
+type fakeSender struct {
+	mu   sync.Mutex
+	sent []string
+	fail map[string]bool
+}
+
+func (s *fakeSender) Send(ctx context.Context, msg *mail.Message) (string, error) {
+	if s.fail[msg.To[0]] {
+		return "", errors.New("rejected")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, msg.To[0])
+	return "id-" + msg.To[0], nil
+}
+
+func messages(to ...string) []*mail.Message {
+	var msgs []*mail.Message
+	for _, rcpt := range to {
+		msgs = append(msgs, &mail.Message{To: []string{rcpt}, Subject: "backup done", TextBody: "All good."})
+	}
+	return msgs
+}
+
+// send runs a send of msgs the way 'gomailit send' does, delivering the
+// entries concurrently, and returns how many messages were skipped as
+// already sent or queued.
+func send(t *testing.T, box *Outbox, sender Sender, msgs []*mail.Message) int {
+	t.Helper()
+	run := RunID("smtp", msgs)
+	var entries []*Entry
+	queued := map[string]bool{}
+	skipped := 0
+	for _, msg := range msgs {
+		entry, existing, err := box.Enqueue(run, "smtp", msg, "<id@example.com>")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if queued[entry.ID] {
+			skipped++
+			continue
+		}
+		queued[entry.ID] = true
+		if existing && entry.State == Sent {
+			skipped++
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	var wg sync.WaitGroup
+	for _, entry := range entries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			box.Deliver(context.Background(), sender, entry, RetryPolicy{Attempts: 1})
+		}()
+	}
+	wg.Wait()
+	if err := box.EndRuns(entries); err != nil {
+		t.Fatal(err)
+	}
+	return skipped
+}
+
+func TestSameSendRunsAgainAfterFinishing(t *testing.T) {
+	box, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender := &fakeSender{}
+
+	// A daily cron job sending the same message must send it every day.
+	for day := 1; day <= 3; day++ {
+		if skipped := send(t, box, sender, messages("admin@example.com")); skipped != 0 {
+			t.Fatalf("day %d: %d messages skipped", day, skipped)
+		}
+	}
+	if len(sender.sent) != 3 {
+		t.Errorf("sent %d messages, want 3", len(sender.sent))
+	}
+}
+
+func TestInterruptedSendResumes(t *testing.T) {
+	box, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs := messages("a@example.com", "b@example.com", "c@example.com")
+
+	// b fails, so the run is not over.
+	sender := &fakeSender{fail: map[string]bool{"b@example.com": true}}
+	if skipped := send(t, box, sender, msgs); skipped != 0 {
+		t.Fatalf("first run skipped %d messages", skipped)
+	}
+
+	// Running it again only sends what is left.
+	sender = &fakeSender{}
+	if skipped := send(t, box, sender, msgs); skipped != 2 {
+		t.Errorf("resumed run skipped %d messages, want 2", skipped)
+	}
+	if len(sender.sent) != 1 || sender.sent[0] != "b@example.com" {
+		t.Errorf("resumed run sent %v, want only b@example.com", sender.sent)
+	}
+
+	// Now that every message was sent, the next run is a new one.
+	sender = &fakeSender{}
+	if skipped := send(t, box, sender, msgs); skipped != 0 {
+		t.Errorf("later run skipped %d messages", skipped)
+	}
+	if len(sender.sent) != 3 {
+		t.Errorf("later run sent %v, want all 3", sender.sent)
+	}
+}
+
+func TestOtherRunDoesNotSkip(t *testing.T) {
+	box, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An unfinished run that already sent a@example.com.
+	sender := &fakeSender{fail: map[string]bool{"b@example.com": true}}
+	send(t, box, sender, messages("a@example.com", "b@example.com"))
+
+	// An unrelated send of the same message still sends it.
+	sender = &fakeSender{}
+	if skipped := send(t, box, sender, messages("a@example.com")); skipped != 0 {
+		t.Errorf("unrelated run skipped %d messages", skipped)
+	}
+}
+
+func TestDuplicateRecipientSentOnce(t *testing.T) {
+	dir := t.TempDir()
+	box, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs := messages("a@example.com", "b@example.com", "a@example.com")
+
+	sender := &fakeSender{}
+	if skipped := send(t, box, sender, msgs); skipped != 1 {
+		t.Errorf("skipped %d messages, want 1", skipped)
+	}
+	slices.Sort(sender.sent)
+	if strings.Join(sender.sent, ",") != "a@example.com,b@example.com" {
+		t.Errorf("sent %v, want a@example.com and b@example.com once", sender.sent)
+	}
+	entries, err := box.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if entry.State != Sent || entry.Attempts != 1 {
+			t.Errorf("entry for %s: %s after %d attempts", entry.Message.To[0], entry.State, entry.Attempts)
+		}
+	}
+
+	// Resuming an interrupted run in a new process also sends the
+	// duplicate once.
+	box, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender = &fakeSender{fail: map[string]bool{"b@example.com": true}}
+	send(t, box, sender, msgs)
+	box, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender = &fakeSender{}
+	if skipped := send(t, box, sender, msgs); skipped != 2 {
+		t.Errorf("resumed run skipped %d messages, want 2", skipped)
+	}
+	if strings.Join(sender.sent, ",") != "b@example.com" {
+		t.Errorf("resumed run sent %v, want only b@example.com", sender.sent)
+	}
+}
+
+func TestRunIDIgnoresOrder(t *testing.T) {
+	if RunID("smtp", messages("a@example.com", "b@example.com")) != RunID("smtp", messages("b@example.com", "a@example.com")) {
+		t.Error("run ID depends on the order of the messages")
+	}
+	if RunID("smtp", messages("a@example.com")) == RunID("ses", messages("a@example.com")) {
+		t.Error("run ID does not depend on the provider")
+	}
+}
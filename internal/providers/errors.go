@@ -0,0 +1,182 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/textproto"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/latocchi/gomailit/internal/ratelimit"
+	"google.golang.org/api/googleapi"
+)
+
+// IsTransient reports whether a send error is likely to go away on retry:
+// rate limiting, server-side failures, temporary SMTP rejections and
+// network hiccups. Authentication and validation errors are permanent.
+func IsTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Code == http.StatusTooManyRequests || apiErr.Code >= 500 || isRateLimitReason(apiErr)
+	}
+
+	var httpErr *APIError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode == http.StatusTooManyRequests || httpErr.StatusCode == http.StatusRequestTimeout || httpErr.StatusCode >= 500
+	}
+
+	var smtpErr *textproto.Error
+	if errors.As(err, &smtpErr) {
+		return smtpErr.Code >= 400 && smtpErr.Code < 500
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// isRateLimitReason reports whether Gmail rejected a request with one of its
+// rate limit reasons, which it sometimes sends as 403 instead of 429.
+func isRateLimitReason(err *googleapi.Error) bool {
+	for _, item := range err.Errors {
+		switch item.Reason {
+		case "rateLimitExceeded", "userRateLimitExceeded", "backendError":
+			return true
+		}
+	}
+	return false
+}
+
+// IsQuotaExceeded reports whether a send failed because the daily sending
+// limit is used up, either the one configured in rate_limits or the
+// provider's own. Such messages can only be sent the next day.
+func IsQuotaExceeded(err error) bool {
+	if errors.Is(err, ratelimit.ErrDailyLimit) {
+		return true
+	}
+
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		for _, item := range apiErr.Errors {
+			if item.Reason == "dailyLimitExceeded" {
+				return true
+			}
+		}
+		return strings.Contains(apiErr.Message, "sending limit exceeded")
+	}
+
+	var httpErr *APIError
+	if errors.As(err, &httpErr) {
+		return httpErr.QuotaExceeded
+	}
+
+	// Gmail's SMTP servers answer "550 5.4.5 Daily user sending limit
+	// exceeded".
+	var smtpErr *textproto.Error
+	return errors.As(err, &smtpErr) && strings.HasPrefix(smtpErr.Msg, "5.4.5")
+}
+
+// IsTemporary reports whether a message that could not be sent can be sent
+// later, so that a client handing it over should keep it and retry.
+func IsTemporary(err error) bool {
+	return IsTransient(err) || IsQuotaExceeded(err)
+}
+
+// retryAfterMessage matches the time Gmail puts in the message of
+// userRateLimitExceeded errors instead of a Retry-After header.
+var retryAfterMessage = regexp.MustCompile(`Retry after (\S+)`)
+
+// RetryAfter returns how long a provider that rejected a request for
+// sending too much asked to wait, or zero if it did not say.
+func RetryAfter(err error) time.Duration {
+	var httpErr *APIError
+	if errors.As(err, &httpErr) {
+		return httpErr.RetryAfter
+	}
+
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		return 0
+	}
+	if d := parseRetryAfter(apiErr.Header.Get("Retry-After")); d > 0 {
+		return d
+	}
+	if m := retryAfterMessage.FindStringSubmatch(apiErr.Message); m != nil {
+		if at, err := time.Parse(time.RFC3339, m[1]); err == nil {
+			return max(0, time.Until(at))
+		}
+	}
+	return 0
+}
+
+// parseRetryAfter parses a Retry-After header, which holds either seconds
+// or a date.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(0, time.Until(at))
+	}
+	return 0
+}
+
+// APIError is an error response of a provider's HTTP API.
+type APIError struct {
+	Provider   string
+	StatusCode int
+	// Code and Message are the provider's error code and description, if
+	// it sent any.
+	Code    string
+	Message string
+	// RetryAfter is how long the provider asked to wait before trying
+	// again.
+	RetryAfter time.Duration
+	// QuotaExceeded is set when the provider refused to send because the
+	// account's sending quota or credits are used up.
+	QuotaExceeded bool
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("%s API error %d", e.Provider, e.StatusCode)
+	if e.Code != "" {
+		msg += " " + e.Code
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+// newAPIError describes a failed response. The body is kept as the message
+// until the provider fills in its own code and message.
+func newAPIError(provider string, resp *http.Response, body []byte) *APIError {
+	return &APIError{
+		Provider:   provider,
+		StatusCode: resp.StatusCode,
+		Message:    strings.TrimSpace(string(body)),
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+}
@@ -0,0 +1,130 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/textproto"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/latocchi/gomailit/internal/ratelimit"
+	"google.golang.org/api/googleapi"
+)
+
+func gmailError(code int, reason, message string) *googleapi.Error {
+	return &googleapi.Error{Code: code, Message: message, Errors: []googleapi.ErrorItem{{Reason: reason, Message: message}}}
+}
+
+func TestErrorClasses(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		transient bool
+		quota     bool
+	}{
+		{name: "nil", err: nil},
+		{name: "canceled", err: context.Canceled},
+		{name: "deadline", err: fmt.Errorf("send: %w", context.DeadlineExceeded), transient: true},
+		{name: "connection refused", err: &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, transient: true},
+		{name: "connection reset", err: fmt.Errorf("write: %w", syscall.ECONNRESET), transient: true},
+		{name: "network timeout", err: &net.DNSError{Err: "timeout", IsTimeout: true}, transient: true},
+		{name: "unknown host", err: &net.DNSError{Err: "no such host", IsNotFound: true}},
+
+		{name: "gmail 429", err: &googleapi.Error{Code: http.StatusTooManyRequests}, transient: true},
+		{name: "gmail 500", err: &googleapi.Error{Code: http.StatusInternalServerError}, transient: true},
+		{name: "gmail rateLimitExceeded", err: gmailError(http.StatusForbidden, "rateLimitExceeded", "Rate Limit Exceeded"), transient: true},
+		{name: "gmail userRateLimitExceeded", err: gmailError(http.StatusForbidden, "userRateLimitExceeded", "User-rate limit exceeded"), transient: true},
+		{name: "gmail dailyLimitExceeded", err: gmailError(http.StatusForbidden, "dailyLimitExceeded", "Daily Limit Exceeded"), quota: true},
+		{name: "gmail sending limit", err: &googleapi.Error{Code: http.StatusBadRequest, Message: "User-rate limit exceeded: sending limit exceeded"}, quota: true},
+		{name: "gmail invalid argument", err: gmailError(http.StatusBadRequest, "invalidArgument", "Invalid To header")},
+		{name: "gmail unauthorized", err: &googleapi.Error{Code: http.StatusUnauthorized}},
+
+		{name: "api 429", err: &APIError{StatusCode: http.StatusTooManyRequests}, transient: true},
+		{name: "api 408", err: &APIError{StatusCode: http.StatusRequestTimeout}, transient: true},
+		{name: "api 503", err: &APIError{StatusCode: http.StatusServiceUnavailable}, transient: true},
+		{name: "api 400", err: &APIError{StatusCode: http.StatusBadRequest}},
+		{name: "api quota", err: &APIError{StatusCode: http.StatusUnauthorized, QuotaExceeded: true}, quota: true},
+
+		{name: "smtp 421", err: &textproto.Error{Code: 421, Msg: "4.7.0 Try again later"}, transient: true},
+		{name: "smtp 451", err: &textproto.Error{Code: 451, Msg: "4.3.0 Mail server temporarily rejected message"}, transient: true},
+		{name: "smtp 452", err: fmt.Errorf("rcpt: %w", &textproto.Error{Code: 452, Msg: "4.5.3 Too many recipients"}), transient: true},
+		{name: "smtp 5.4.5", err: &textproto.Error{Code: 550, Msg: "5.4.5 Daily user sending limit exceeded."}, quota: true},
+		{name: "smtp 550", err: &textproto.Error{Code: 550, Msg: "5.1.1 The email account does not exist"}},
+		{name: "smtp 535", err: &textproto.Error{Code: 535, Msg: "5.7.8 Username and Password not accepted"}},
+
+		{name: "daily limit", err: fmt.Errorf("%w: 500 messages sent through smtp today", ratelimit.ErrDailyLimit), quota: true},
+		{name: "other", err: errors.New("invalid address")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsTransient(tt.err); got != tt.transient {
+				t.Errorf("IsTransient = %v, want %v", got, tt.transient)
+			}
+			if got := IsQuotaExceeded(tt.err); got != tt.quota {
+				t.Errorf("IsQuotaExceeded = %v, want %v", got, tt.quota)
+			}
+			if got := IsTemporary(tt.err); got != (tt.transient || tt.quota) {
+				t.Errorf("IsTemporary = %v", got)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		min   time.Duration
+		max   time.Duration
+	}{
+		{"", 0, 0},
+		{"0", 0, 0},
+		{"30", 30 * time.Second, 30 * time.Second},
+		{"3600", time.Hour, time.Hour},
+		{time.Now().Add(2 * time.Minute).UTC().Format(http.TimeFormat), 119 * time.Second, 2 * time.Minute},
+		{time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), 0, 0},
+		{"soon", 0, 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value); got < tt.min || got > tt.max {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v to %v", tt.value, got, tt.min, tt.max)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	withHeader := func(value string) *googleapi.Error {
+		err := gmailError(http.StatusTooManyRequests, "rateLimitExceeded", "Rate Limit Exceeded")
+		err.Header = http.Header{"Retry-After": {value}}
+		return err
+	}
+	retryAt := time.Now().Add(90 * time.Second).UTC()
+
+	tests := []struct {
+		name string
+		err  error
+		min  time.Duration
+		max  time.Duration
+	}{
+		{"api seconds", fmt.Errorf("send: %w", &APIError{StatusCode: 429, RetryAfter: 10 * time.Second}), 10 * time.Second, 10 * time.Second},
+		{"gmail header seconds", withHeader("20"), 20 * time.Second, 20 * time.Second},
+		{"gmail header date", withHeader(retryAt.Format(http.TimeFormat)), 88 * time.Second, 90 * time.Second},
+		{"gmail message", gmailError(http.StatusTooManyRequests, "userRateLimitExceeded", "User-rate limit exceeded.  Retry after "+retryAt.Format(time.RFC3339)), 88 * time.Second, 90 * time.Second},
+		{"gmail without a time", gmailError(http.StatusTooManyRequests, "rateLimitExceeded", "Rate Limit Exceeded"), 0, 0},
+		{"smtp", &textproto.Error{Code: 421, Msg: "4.7.0 Try again later"}, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RetryAfter(tt.err); got < tt.min || got > tt.max {
+				t.Errorf("RetryAfter = %v, want %v to %v", got, tt.min, tt.max)
+			}
+		})
+	}
+}
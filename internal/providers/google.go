@@ -4,255 +4,499 @@ Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
 package providers
 
 import (
-	"bufio"
-	"bytes"
 	"context"
-	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
-	"mime/multipart"
-	"mime/quotedprintable"
-	"net"
-	"net/http"
-	"net/textproto"
 	"os"
-	"os/exec"
-	"path/filepath"
+	"strings"
 	"sync"
 
+	"github.com/latocchi/gomailit/internal/mail"
+	"github.com/latocchi/gomailit/internal/mime"
+	"github.com/latocchi/gomailit/internal/tokenstore"
 	"github.com/latocchi/gomailit/internal/utils"
+	"github.com/spf13/pflag"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/gmail/v1"
 	"google.golang.org/api/option"
 )
 
-type AttachmentPart struct {
-	Header textproto.MIMEHeader
-	Body   []byte
-	Err    error
-}
-
-func buildMessageWithAttachments(to, subject, body string, attachments []string) *gmail.Message {
-	var buf bytes.Buffer
-	writer := multipart.NewWriter(&buf)
-	boundary := writer.Boundary()
-
-	headers := fmt.Sprintf(
-		"To: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: multipart/mixed; boundary=%s\r\n\r\n",
-		to, subject, boundary,
-	)
-	buf.WriteString(headers)
-
-	bodyHeader := textproto.MIMEHeader{}
-	bodyHeader.Set("Content-Type", "text/plain; charset=\"UTF-8\"")
-	bodyHeader.Set("Content-Transfer-Encoding", "quoted-printable")
-
-	bodyPart, _ := writer.CreatePart(bodyHeader)
-	qp := quotedprintable.NewWriter(bodyPart)
-	qp.Write([]byte(body))
-	qp.Close()
-
-	var wg sync.WaitGroup
-	results := make(chan AttachmentPart, len(attachments))
-
-	for _, path := range attachments {
-		wg.Add(1)
-		go func(p string) {
-			defer wg.Done()
-			data, err := os.ReadFile(path)
+func init() {
+	Register(Registration{
+		Name:        "google",
+		Aliases:     []string{"gmail"},
+		Description: "Gmail with OAuth2, through the REST API or SMTP (XOAUTH2)",
+		Flags: func(flags *pflag.FlagSet) {
+			flags.String("token-store", "", "Where to keep the OAuth token: keyring, file or encrypted-file (default keyring)")
+			flags.String("import-credentials", "", "Copy an OAuth client secret file into the gomailit config directory")
+			flags.String("service-account", "", "Service account key file, for sending without a browser through domain-wide delegation")
+			flags.String("subject", "", "User to impersonate with --service-account, e.g. alice@example.com")
+			flags.Bool("no-browser", false, "Print the authorization link and read the code back instead of opening a browser")
+			flags.String("transport", "", "Send through the Gmail API or through smtp.gmail.com with XOAUTH2: api or smtp (default api)")
+			flags.String("account", "", "Gmail address to log in to SMTP as, looked up through the Gmail API when omitted")
+		},
+		Setup: func(ctx context.Context, flags *pflag.FlagSet) (err error) {
+			settings, err := LoadGoogleConfig()
 			if err != nil {
-				results <- AttachmentPart{Err: err}
-				return
+				return err
+			}
+			previous := *settings
+			transportChanged := false
+			if flags.Changed("transport") {
+				transport, _ := flags.GetString("transport")
+				if transport != GoogleTransportAPI && transport != GoogleTransportSMTP {
+					return fmt.Errorf("unsupported transport %q, use api or smtp", transport)
+				}
+				transportChanged = transport != settings.transport()
+				settings.Transport = transport
+			}
+			if account, _ := flags.GetString("account"); account != "" {
+				settings.Account = mail.BareAddress(account)
+			}
+			// The scopes depend on the transport, so it is saved before
+			// authorizing, and put back when the new transport cannot log in.
+			if err := SaveGoogleConfig(settings); err != nil {
+				return err
+			}
+			defer func() {
+				if err != nil {
+					SaveGoogleConfig(&previous)
+				}
+			}()
+
+			key, _ := flags.GetString("service-account")
+			subject, _ := flags.GetString("subject")
+			if key != "" {
+				if err := setupServiceAccount(ctx, key, subject); err != nil {
+					return err
+				}
+				return setupGoogleSMTP(ctx, settings)
+			}
+			if subject != "" {
+				return errors.New("--subject is only used with --service-account")
+			}
+			if err := removeServiceAccount(); err != nil {
+				return err
 			}
 
-			encoded := make([]byte, base64.StdEncoding.EncodedLen(len(data)))
-			base64.StdEncoding.Encode(encoded, data)
+			if value, _ := flags.GetString("token-store"); value != "" {
+				kind, err := tokenstore.ParseKind(value)
+				if err != nil {
+					return err
+				}
+				if err := googleToken.switchStore(kind); err != nil {
+					return err
+				}
+			}
+			if src, _ := flags.GetString("import-credentials"); src != "" {
+				if err := importGoogleCredentials(src); err != nil {
+					return err
+				}
+			}
+			// SMTP needs the full Gmail scope, which the current token was
+			// not granted.
+			if transportChanged && settings.Transport == GoogleTransportSMTP {
+				if err := googleToken.delete(); err != nil {
+					return err
+				}
+			}
+			noBrowser, _ := flags.GetBool("no-browser")
+			if err := setupGoogle(ctx, defaultWebAuth(noBrowser)); err != nil {
+				return err
+			}
+			return setupGoogleSMTP(ctx, settings)
+		},
+		New: func(ctx context.Context) (Provider, error) {
+			return NewGoogleProvider()
+		},
+	})
+}
 
-			filename := filepath.Base(path)
+// Supported values for GoogleConfig.Transport.
+const (
+	GoogleTransportAPI  = "api"
+	GoogleTransportSMTP = "smtp"
+)
 
-			attachmentHeader := textproto.MIMEHeader{}
-			attachmentHeader.Set("Content-Type", fmt.Sprintf("application/octet-stream; name=\"%s\"", filename))
-			attachmentHeader.Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
-			attachmentHeader.Set("Content-Transfer-Encoding", "base64")
+// gmailSMTP is the server of the smtp transport.
+var gmailSMTP = SMTPConfig{Host: "smtp.gmail.com", Port: 587, Security: SMTPSecurityStartTLS}
+
+// GoogleConfig holds the settings of the Google provider other than its
+// credential.
+type GoogleConfig struct {
+	// Transport is "api" (the Gmail REST API) or "smtp" (smtp.gmail.com
+	// with XOAUTH2, for networks that block the API).
+	Transport string `json:"transport,omitempty"`
+	// Account is the address the smtp transport logs in as.
+	Account string `json:"account,omitempty"`
+}
 
-			results <- AttachmentPart{Header: attachmentHeader, Body: encoded, Err: nil}
-		}(path)
+func (c *GoogleConfig) transport() string {
+	if c.Transport == "" {
+		return GoogleTransportAPI
 	}
+	return c.Transport
+}
 
-	go func() {
-		wg.Wait()
-		close(results)
-	}()
-
-	for part := range results {
-		if part.Err != nil {
-			panic(part.Err)
-		}
+func SaveGoogleConfig(config *GoogleConfig) error {
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to encode google config: %v", err)
+	}
+	if err := os.WriteFile(utils.GoogleConfigPath(), data, 0600); err != nil {
+		return fmt.Errorf("unable to save google config: %v", err)
+	}
+	return nil
+}
 
-		w, _ := writer.CreatePart(part.Header)
+// LoadGoogleConfig reads the Google settings. Installs without any have
+// always used the API.
+func LoadGoogleConfig() (*GoogleConfig, error) {
+	config := &GoogleConfig{}
+	data, err := os.ReadFile(utils.GoogleConfigPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return config, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to read google config: %v", err)
+	}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("unable to parse google config: %v", err)
+	}
+	return config, nil
+}
 
-		for i := 0; i < len(part.Body); i += 76 {
-			end := i + 76
-			if end > len(part.Body) {
-				end = len(part.Body)
-			}
-			w.Write(part.Body[i:end])
-			w.Write([]byte("\r\n"))
+// setupGoogleSMTP finds the account the smtp transport logs in as, unless
+// it was given with --account, and checks that it can log in.
+func setupGoogleSMTP(ctx context.Context, settings *GoogleConfig) error {
+	if settings.transport() != GoogleTransportSMTP {
+		return nil
+	}
+	p, err := newGmailAPIProvider(ctx)
+	if err != nil {
+		return err
+	}
+	if settings.Account == "" {
+		if sa, err := loadServiceAccount(); err == nil {
+			settings.Account = sa.Subject
+		} else if settings.Account, err = p.Account(ctx); err != nil {
+			return fmt.Errorf("%v, pass the address with --account", err)
 		}
 	}
-
-	writer.Close()
-	raw := utils.EncodeURLSafeBase64(buf.Bytes())
-	return &gmail.Message{Raw: raw}
-
+	p.useSMTP(settings.Account)
+	if err := p.smtp.login(ctx); err != nil {
+		return fmt.Errorf("unable to log in to %s as %s: %v", gmailSMTP.Addr(), settings.Account, err)
+	}
+	if err := SaveGoogleConfig(settings); err != nil {
+		return err
+	}
+	fmt.Printf("Google provider sends through %s as %s with XOAUTH2\n", gmailSMTP.Addr(), settings.Account)
+	return nil
 }
 
-func buildMessage(to, subject, body string) *gmail.Message {
-	message := []byte(
-		fmt.Sprintf("To: %s\r\n", to) +
-			fmt.Sprintf("Subject: %s\r\n", subject) +
-			"MIME-Version: 1.0\r\n" +
-			"Content-Type: text/plain; charset=\"utf-8\"\r\n\r\n" +
-			body,
-	)
-	raw := utils.EncodeURLSafeBase64(message)
-	return &gmail.Message{Raw: raw}
+// GoogleProvider sends email through the Gmail REST API, or through Gmail's
+// SMTP server, using the stored OAuth2 token or service account. It holds
+// one client, which is safe for concurrent use; token refreshes are
+// serialised so that goroutines sending at the same time refresh only once.
+type GoogleProvider struct {
+	srv *gmail.Service
+	// smtp is set for the smtp transport, which logs in as account.
+	smtp    *SMTPProvider
+	account string
+	tokens  oauth2.TokenSource
+	// threads maps the IDs of messages sent through the API to their
+	// thread IDs.
+	threads sync.Map
 }
 
-func SendEmailGMail(to, subject, body string, attachments []string) error {
-	srv, err := GetGoogleService()
+// NewGoogleProvider builds the Gmail client of the selected profile. It
+// never starts the authorization flow, that is what setup is for.
+func NewGoogleProvider() (*GoogleProvider, error) {
+	settings, err := LoadGoogleConfig()
 	if err != nil {
-		return fmt.Errorf("unable to get google mail service: %v", err)
+		return nil, err
+	}
+	// The client outlives any single request, so it must not be tied to a
+	// request context.
+	p, err := newGmailAPIProvider(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	if settings.transport() != GoogleTransportSMTP {
+		return p, nil
 	}
 
-	if len(attachments) > 0 {
-		mail := buildMessageWithAttachments(to, subject, body, attachments)
-		err = send(srv, mail)
-		if err != nil {
-			return err
-		}
-		// fmt.Println("Email with attachments sent successfully to " + to + "!")
-		return nil
+	if settings.Account == "" {
+		return nil, errors.New("no SMTP account configured, run 'gomailit setup google --transport smtp' again")
 	}
+	p.useSMTP(settings.Account)
+	return p, nil
+}
 
-	mail := buildMessage(to, subject, body)
+// useSMTP makes the provider send through Gmail's SMTP server, logging in
+// as account with the provider's tokens.
+func (p *GoogleProvider) useSMTP(account string) {
+	config := gmailSMTP
+	config.Auth = SMTPAuthXOAUTH2
+	config.Username = account
+	config.From = account
+	p.smtp = NewSMTPProvider(&config)
+	p.smtp.tokens = p.tokens
+	p.account = account
+}
 
-	err = send(srv, mail)
-	if err != nil {
-		return err
+// newGmailService builds a Gmail API client. Tests replace it to count the
+// clients built.
+var newGmailService = gmail.NewService
+
+// newGmailAPIProvider returns a provider sending through the Gmail API.
+func newGmailAPIProvider(ctx context.Context) (*GoogleProvider, error) {
+	ts, _, err := googleTokenSource(ctx)
+	if errors.Is(err, ErrNotLoggedIn) {
+		return nil, fmt.Errorf("no token found, please run 'gomailit setup google' first to set up the Google provider")
+	} else if err != nil {
+		return nil, err
 	}
-	return nil
-}
 
-func send(srv *gmail.Service, mail *gmail.Message) error {
-	_, err := srv.Users.Messages.Send("me", mail).Do()
+	srv, err := newGmailService(ctx, option.WithHTTPClient(oauth2.NewClient(ctx, ts)))
 	if err != nil {
-		return fmt.Errorf("unable to send email: %v", err)
+		return nil, fmt.Errorf("unable to retrieve Gmail client: %v", err)
 	}
-	return nil
+	return &GoogleProvider{srv: srv, tokens: ts}, nil
 }
 
-func GetGoogleService() (*gmail.Service, error) {
-	client, err := SetupGoogle()
+// Account returns the address of the mailbox the provider sends from.
+func (p *GoogleProvider) Account(ctx context.Context) (string, error) {
+	if p.smtp != nil {
+		return p.account, nil
+	}
+	profile, err := p.srv.Users.GetProfile("me").Context(ctx).Do()
 	if err != nil {
-		return nil, fmt.Errorf("unable to setup google client: %v", err)
+		return "", fmt.Errorf("unable to get user profile: %v", err)
 	}
+	return profile.EmailAddress, nil
+}
 
-	srv, err := gmail.NewService(context.Background(), option.WithHTTPClient(client))
+// ValidateFrom checks that from is a verified send-as alias of the account.
+// Over SMTP the aliases cannot be listed, and Gmail replaces a From
+// address that is not one with the account's own.
+func (p *GoogleProvider) ValidateFrom(ctx context.Context, from string) error {
+	if p.smtp != nil {
+		return nil
+	}
+	aliases, err := p.srv.Users.Settings.SendAs.List("me").Context(ctx).Do()
 	if err != nil {
-		return nil, fmt.Errorf("unable to retrieve Gmail client: %v", err)
+		return fmt.Errorf("unable to list send-as aliases, run 'gomailit setup google' again to grant access: %v", err)
 	}
 
-	return srv, nil
+	address := mail.BareAddress(from)
+	for _, alias := range aliases.SendAs {
+		if !strings.EqualFold(alias.SendAsEmail, address) {
+			continue
+		}
+		if alias.VerificationStatus != "" && alias.VerificationStatus != "accepted" {
+			return fmt.Errorf("send-as alias %s is not verified", address)
+		}
+		return nil
+	}
+	return fmt.Errorf("%s is not a send-as alias of this Gmail account", address)
 }
 
-func SetupGoogle() (*http.Client, error) {
-	b, err := os.ReadFile("credentials.json")
-	if err != nil {
-		return nil, fmt.Errorf("unable to read client secret file: %v", err)
+func (p *GoogleProvider) Send(ctx context.Context, msg *mail.Message) (string, error) {
+	if err := msg.Validate(); err != nil {
+		return "", err
+	}
+	if p.smtp != nil {
+		return p.smtp.Send(ctx, msg)
 	}
 
-	config, err := google.ConfigFromJSON(b, gmail.GmailSendScope, gmail.GmailMetadataScope)
+	// Gmail derives the envelope from the headers, so Bcc must be included.
+	builder := &mime.Builder{IncludeBcc: true}
+	raw, err := builder.Build(msg)
 	if err != nil {
-		return nil, fmt.Errorf("unable to parse client secret file to config: %v", err)
+		return "", fmt.Errorf("unable to build message: %v", err)
 	}
 
-	client := getClient(config)
-
-	return client, nil
+	message := &gmail.Message{Raw: utils.EncodeURLSafeBase64(raw)}
+	return p.send(ctx, message)
 }
 
-// Retrieve a token, saves the token, then returns the generated client.
-func getClient(config *oauth2.Config) *http.Client {
-	// The file token.json stores the user's access and refresh tokens, and is
-	// created automatically when the authorization flow completes for the first
-	// time.
-	tokFile := utils.TokenPath()
-	tok, err := tokenFromFile(tokFile)
+// SendRaw submits a pre-built message. The Gmail API always delivers to
+// the addresses in the To, Cc and Bcc headers, so envelope recipients that
+// are not among them are added as Bcc; over SMTP they may differ.
+func (p *GoogleProvider) SendRaw(ctx context.Context, env mime.Envelope, raw []byte) (string, error) {
+	if p.smtp != nil {
+		return p.smtp.SendRaw(ctx, env, raw)
+	}
+	raw, err := withEnvelopeBcc("the Gmail API", env, raw)
 	if err != nil {
-		tok = getTokenFromWeb(config)
-		saveToken(tokFile, tok)
+		return "", err
 	}
-	return config.Client(context.Background(), tok)
+
+	return p.send(ctx, &gmail.Message{Raw: utils.EncodeURLSafeBase64(raw)})
 }
 
-// Request a token from the web, then returns the retrieved token.
-func getTokenFromWeb(config *oauth2.Config) *oauth2.Token {
-	// Create local server to listen for redirect
-	listener, err := net.Listen("tcp", "localhost:8080")
+// withEnvelopeBcc prepares a pre-built message for an API that delivers to
+// the To, Cc and Bcc headers rather than to an envelope. Envelope
+// recipients missing from the headers, such as sendmail arguments or the
+// Bcc recipients of an SMTP client, are added to the Bcc header, which the
+// API removes on delivery. Header recipients missing from the envelope
+// would still get the message, so they are an error.
+func withEnvelopeBcc(service string, env mime.Envelope, raw []byte) ([]byte, error) {
+	headers, err := mime.ParseEnvelope(raw)
 	if err != nil {
-		log.Fatalf("Unable to start local server: %v", err)
+		return nil, err
 	}
-	defer listener.Close()
 
-	config.RedirectURL = "http://localhost:8080"
-	authURL := config.AuthCodeURL("state-token", oauth2.AccessTypeOffline)
+	inEnvelope := map[string]bool{}
+	for _, address := range env.Recipients {
+		inEnvelope[strings.ToLower(mail.BareAddress(address))] = true
+	}
+	inHeaders := map[string]bool{}
+	for _, address := range headers.Recipients {
+		address = strings.ToLower(address)
+		if !inEnvelope[address] {
+			return nil, fmt.Errorf("%s delivers to every To, Cc and Bcc header recipient, so %s cannot be left out", service, address)
+		}
+		inHeaders[address] = true
+	}
 
-	fmt.Printf("Your browser will open for authorization.\nIf it doesn't, open this link manually:\n%v\n", authURL)
-	exec.Command("xdg-open", authURL).Start()
+	var extra []string
+	for _, address := range env.Recipients {
+		bare := mail.BareAddress(address)
+		if !inHeaders[strings.ToLower(bare)] {
+			extra = append(extra, bare)
+			inHeaders[strings.ToLower(bare)] = true
+		}
+	}
+	if len(extra) == 0 {
+		return raw, nil
+	}
 
-	codeChan := make(chan string)
+	bcc := strings.Join(extra, ", ")
+	if existing := mime.HeaderValue(raw, "Bcc"); existing != "" {
+		bcc = existing + ", " + bcc
+	}
+	return mime.PrependHeader(mime.StripHeader(raw, "Bcc"), "Bcc", bcc), nil
+}
 
-	go func() {
-		conn, _ := listener.Accept()
-		defer conn.Close()
+func (p *GoogleProvider) send(ctx context.Context, mail *gmail.Message) (string, error) {
+	sent, err := p.srv.Users.Messages.Send("me", mail).Context(ctx).Do()
+	if err != nil {
+		return "", fmt.Errorf("unable to send email: %w", err)
+	}
+	p.threads.Store(sent.Id, sent.ThreadId)
+	return sent.Id, nil
+}
 
-		req, _ := http.ReadRequest(bufio.NewReader(conn))
-		code := req.URL.Query().Get("code")
+// ThreadID returns the Gmail thread of a message sent through the API.
+// Messages sent over SMTP have no known thread.
+func (p *GoogleProvider) ThreadID(id string) string {
+	thread, _ := p.threads.Load(id)
+	s, _ := thread.(string)
+	return s
+}
 
-		conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Type: text/html\r\n\r\nYou may now close this window."))
-		codeChan <- code
-	}()
+// googleScopes returns the Gmail scopes gomailit asks for. Logging in to
+// SMTP needs full access to the mailbox, so the smtp transport adds it.
+func googleScopes() []string {
+	scopes := []string{gmail.GmailSendScope, gmail.GmailMetadataScope, gmail.GmailSettingsBasicScope}
+	if settings, err := LoadGoogleConfig(); err == nil && settings.transport() == GoogleTransportSMTP {
+		scopes = append(scopes, gmail.MailGoogleComScope)
+	}
+	return scopes
+}
 
-	code := <-codeChan
-	tok, err := config.Exchange(context.Background(), code)
+// setupGoogle makes sure the selected profile has a token, running the
+// authorization flow when it has none.
+func setupGoogle(ctx context.Context, auth webAuth) error {
+	config, err := googleOAuthConfig()
 	if err != nil {
-		log.Fatalf("Unable to retrieve token from web: %v", err)
+		return err
 	}
-	return tok
+
+	// The token holds the user's access and refresh tokens, and is saved
+	// automatically when the authorization flow completes for the first time.
+	_, err = googleToken.authorize(ctx, config, auth)
+	return err
 }
 
-// Retrieves a token from a local file.
-func tokenFromFile(file string) (*oauth2.Token, error) {
-	f, err := os.Open(file)
+// googleOAuthConfig reads the OAuth client secret.
+func googleOAuthConfig() (*oauth2.Config, error) {
+	path, err := googleCredentialsPath()
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
-	tok := &oauth2.Token{}
-	err = json.NewDecoder(f).Decode(tok)
-	return tok, err
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read client secret file: %v", err)
+	}
+
+	config, err := google.ConfigFromJSON(b, googleScopes()...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse client secret file to config: %v", err)
+	}
+	return config, nil
 }
 
-// Saves a token to a file path.
-func saveToken(path string, token *oauth2.Token) {
-	fmt.Printf("Saving credential file to: %s\n", path)
-	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+// CredentialsEnv names the environment variable pointing at the Google OAuth
+// client secret.
+const CredentialsEnv = "GOMAILIT_GOOGLE_CREDENTIALS"
+
+// credentialsPath is the client secret given with --credentials.
+var credentialsPath string
+
+// SetGoogleCredentialsPath makes the Google provider read its OAuth client
+// secret from path.
+func SetGoogleCredentialsPath(path string) {
+	credentialsPath = path
+}
+
+// googleCredentialsPath returns the client secret to use: the one given with
+// --credentials, else $GOMAILIT_GOOGLE_CREDENTIALS, else credentials.json in
+// the config directory. Older versions read credentials.json from the
+// working directory, so that is still tried last.
+func googleCredentialsPath() (string, error) {
+	if credentialsPath != "" {
+		return credentialsPath, nil
+	}
+	if path := os.Getenv(CredentialsEnv); path != "" {
+		return path, nil
+	}
+	path := utils.CredentialsPath()
+	if utils.FileExists(path) {
+		return path, nil
+	}
+	if utils.FileExists("credentials.json") {
+		fmt.Fprintf(os.Stderr, "Using credentials.json from the working directory, run 'gomailit setup google --import-credentials credentials.json' to use it from anywhere\n")
+		return "credentials.json", nil
+	}
+	return "", fmt.Errorf("no client secret file found at %s, run 'gomailit setup google --import-credentials <file>' or pass --credentials", path)
+}
+
+// importGoogleCredentials copies a client secret file into the config
+// directory, readable by the owner only.
+func importGoogleCredentials(src string) error {
+	b, err := os.ReadFile(src)
 	if err != nil {
-		log.Fatalf("Unable to cache oauth token: %v", err)
+		return fmt.Errorf("unable to read client secret file: %v", err)
 	}
-	defer f.Close()
-	json.NewEncoder(f).Encode(token)
+	if _, err := google.ConfigFromJSON(b); err != nil {
+		return fmt.Errorf("unable to parse client secret file %s: %v", src, err)
+	}
+
+	dst := utils.CredentialsPath()
+	if err := os.WriteFile(dst, b, 0600); err != nil {
+		return fmt.Errorf("unable to save client secret file: %v", err)
+	}
+	// WriteFile keeps the mode of an existing file.
+	if err := os.Chmod(dst, 0600); err != nil {
+		return fmt.Errorf("unable to save client secret file: %v", err)
+	}
+	fmt.Printf("Imported client secret to %s\n", dst)
+	return nil
 }
+
+// googleToken is the OAuth token of the Google provider.
+var googleToken = &oauthToken{provider: "google"}
@@ -0,0 +1,234 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package providers
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/latocchi/gomailit/internal/mime"
+	"github.com/latocchi/gomailit/internal/tokenstore"
+	"github.com/latocchi/gomailit/internal/utils"
+	"github.com/spf13/pflag"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/option"
+)
+
+func TestWithEnvelopeBcc(t *testing.T) {
+	tests := []struct {
+		name       string
+		raw        string
+		recipients []string
+		wantBcc    string
+		wantErr    bool
+	}{
+		{
+			name:       "sendmail without recipient headers",
+			raw:        "Subject: backup done\r\n\r\nAll good.\r\n",
+			recipients: []string{"admin@example.com"},
+			wantBcc:    "admin@example.com",
+		},
+		{
+			name:       "headers match the envelope",
+			raw:        "To: bob@example.com\r\nCc: Carol <carol@example.com>\r\n\r\nHi\r\n",
+			recipients: []string{"BOB@example.com", "carol@example.com"},
+		},
+		{
+			name:       "relay client keeps Bcc in the envelope",
+			raw:        "To: bob@example.com\r\nBcc: audit@example.com\r\n\r\nHi\r\n",
+			recipients: []string{"bob@example.com", "audit@example.com", "hidden@example.com"},
+			wantBcc:    "audit@example.com, hidden@example.com",
+		},
+		{
+			name:       "header recipient left out",
+			raw:        "To: bob@example.com, carol@example.com\r\n\r\nHi\r\n",
+			recipients: []string{"bob@example.com"},
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := withEnvelopeBcc("the API", mime.Envelope{Recipients: tt.recipients}, []byte(tt.raw))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("no error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bcc := mime.HeaderValue(got, "Bcc"); bcc != tt.wantBcc {
+				t.Errorf("Bcc = %q, want %q", bcc, tt.wantBcc)
+			}
+			if strings.Count(strings.ToLower(string(got)), "bcc:") > 1 {
+				t.Errorf("more than one Bcc header:\n%s", got)
+			}
+			env, err := mime.ParseEnvelope(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(env.Recipients) != len(tt.recipients) {
+				t.Errorf("headers now name %v, want %v", env.Recipients, tt.recipients)
+			}
+		})
+	}
+}
+
+// setupGoogleSMTPTransport runs 'gomailit setup google --transport smtp'
+// for a service account impersonating alice@example.com, against an SMTP
+// stand-in for smtp.gmail.com.
+func setupGoogleSMTPTransport(t *testing.T, token string) error {
+	t.Helper()
+	jwt := newJWTServer(t)
+	server := newSMTPStandIn(t, nil, false)
+	server.username = "alice@example.com"
+	server.password = token
+	host, port, _ := net.SplitHostPort(server.addr)
+	saved := gmailSMTP
+	gmailSMTP = SMTPConfig{Host: host, Security: SMTPSecurityNone}
+	gmailSMTP.Port, _ = strconv.Atoi(port)
+	t.Cleanup(func() { gmailSMTP = saved })
+
+	r, err := Lookup("google")
+	if err != nil {
+		t.Fatal(err)
+	}
+	flags := pflag.NewFlagSet("setup", pflag.ContinueOnError)
+	r.Flags(flags)
+	args := []string{"--service-account", jwt.keyFile(t), "--subject", "alice@example.com", "--transport", "smtp"}
+	if err := flags.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return r.Setup(context.Background(), flags)
+}
+
+func TestGoogleSMTPTransportSetup(t *testing.T) {
+	useTempConfigDir(t)
+
+	// The service account token is "service-token", which the server does
+	// not accept here.
+	err := setupGoogleSMTPTransport(t, "other-token")
+	if err == nil || !strings.Contains(err.Error(), "unable to log in") {
+		t.Fatalf("error = %v, want the login to fail", err)
+	}
+	settings, err := LoadGoogleConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.transport() != GoogleTransportAPI {
+		t.Errorf("transport = %s after a failed login, want api", settings.transport())
+	}
+
+	if err := setupGoogleSMTPTransport(t, "service-token"); err != nil {
+		t.Fatal(err)
+	}
+	if settings, err = LoadGoogleConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if settings.transport() != GoogleTransportSMTP || settings.Account != "alice@example.com" {
+		t.Errorf("settings = %+v, want the smtp transport as alice@example.com", settings)
+	}
+}
+
+// countGmailServices makes Get build fresh providers and counts the Gmail
+// clients they build.
+func countGmailServices(t *testing.T) *atomic.Int32 {
+	t.Helper()
+	instancesMu.Lock()
+	clear(instances)
+	instancesMu.Unlock()
+	t.Cleanup(func() {
+		instancesMu.Lock()
+		clear(instances)
+		instancesMu.Unlock()
+	})
+
+	var built atomic.Int32
+	saved := newGmailService
+	newGmailService = func(ctx context.Context, opts ...option.ClientOption) (*gmail.Service, error) {
+		built.Add(1)
+		return saved(ctx, opts...)
+	}
+	t.Cleanup(func() { newGmailService = saved })
+	return &built
+}
+
+// writeGoogleCredentials imports an OAuth client secret.
+func writeGoogleCredentials(t *testing.T) {
+	t.Helper()
+	credentials := `{"installed":{"client_id":"id","client_secret":"secret","auth_uri":"https://accounts.example.com/auth","token_uri":"https://accounts.example.com/token","redirect_uris":["http://localhost"]}}`
+	if err := os.WriteFile(utils.CredentialsPath(), []byte(credentials), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// getConcurrently calls Get for the google provider from n goroutines at
+// once.
+func getConcurrently(n int) ([]Provider, []error) {
+	providers := make([]Provider, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			providers[i], errs[i] = Get(context.Background(), "google")
+		}()
+	}
+	wg.Wait()
+	return providers, errs
+}
+
+func TestGoogleSharedClient(t *testing.T) {
+	useTempConfigDir(t)
+	built := countGmailServices(t)
+	writeGoogleCredentials(t)
+	if err := googleToken.save(&oauth2.Token{AccessToken: "access", RefreshToken: "refresh", Expiry: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+
+	providers, errs := getConcurrently(10)
+	for i, err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if providers[i] != providers[0] {
+			t.Errorf("goroutine %d got a provider of its own", i)
+		}
+	}
+	if n := built.Load(); n != 1 {
+		t.Errorf("built %d Gmail clients, want 1", n)
+	}
+}
+
+func TestGoogleSharedClientNoToken(t *testing.T) {
+	useTempConfigDir(t)
+	built := countGmailServices(t)
+	writeGoogleCredentials(t)
+
+	// Sending never starts the authorization flow, so goroutines without a
+	// token all point at setup instead of opening a browser each.
+	_, errs := getConcurrently(10)
+	for _, err := range errs {
+		if err == nil || !strings.Contains(err.Error(), "gomailit setup google") {
+			t.Errorf("error = %v, want a pointer to setup", err)
+		}
+	}
+	if n := built.Load(); n != 0 {
+		t.Errorf("built %d Gmail clients without a token", n)
+	}
+	if _, err := googleToken.load(); !errors.Is(err, tokenstore.ErrNotFound) {
+		t.Errorf("token after Get: %v, want none", err)
+	}
+}
@@ -0,0 +1,233 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/latocchi/gomailit/internal/tokenstore"
+	"github.com/latocchi/gomailit/internal/utils"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/option"
+)
+
+// Google's token introspection and revocation endpoints.
+var (
+	tokenInfoURL = "https://oauth2.googleapis.com/tokeninfo"
+	revokeURL    = "https://oauth2.googleapis.com/revoke"
+)
+
+// ErrNotLoggedIn is returned when the selected profile has no Google
+// credential.
+var ErrNotLoggedIn = errors.New("not logged in, run 'gomailit setup google' first")
+
+// GoogleAuthStatus describes the Google credential of the selected profile.
+type GoogleAuthStatus struct {
+	// Account is the address of the mailbox the credential sends from.
+	Account string
+	Scopes  []string
+	// Expiry is when the current access token expires.
+	Expiry          time.Time
+	HasRefreshToken bool
+	// ServiceAccount is the service account e-mail when one is used
+	// instead of an OAuth token.
+	ServiceAccount string
+	// Store is where the credential is kept.
+	Store string
+	// Transport is "api" or "smtp".
+	Transport string
+}
+
+// googleTokenSource returns the token source of the selected profile
+// without ever starting the authorization flow.
+func googleTokenSource(ctx context.Context) (oauth2.TokenSource, *GoogleAuthStatus, error) {
+	sa, err := loadServiceAccount()
+	if err == nil {
+		conf, err := sa.jwtConfig()
+		if err != nil {
+			return nil, nil, err
+		}
+		status := &GoogleAuthStatus{ServiceAccount: conf.Email, Store: utils.ServiceAccountPath()}
+		return conf.TokenSource(ctx), status, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, err
+	}
+
+	tok, err := googleToken.load()
+	if errors.Is(err, tokenstore.ErrNotFound) {
+		return nil, nil, ErrNotLoggedIn
+	} else if err != nil {
+		return nil, nil, err
+	}
+	config, err := googleOAuthConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	status := &GoogleAuthStatus{HasRefreshToken: tok.RefreshToken != "", Store: googleToken.store().Location()}
+	return googleToken.tokenSource(config, tok), status, nil
+}
+
+// GetGoogleAuthStatus looks up the account and scopes of the credential,
+// refreshing the access token first if it has expired. When the lookup
+// fails, the locally known part of the status is returned with the error.
+func GetGoogleAuthStatus(ctx context.Context) (*GoogleAuthStatus, error) {
+	ts, status, err := googleTokenSource(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tok, err := ts.Token()
+	if err != nil {
+		return status, fmt.Errorf("unable to get access token: %v", err)
+	}
+	status.Expiry = tok.Expiry
+
+	if status.Scopes, err = tokenScopes(ctx, tok.AccessToken); err != nil {
+		return status, err
+	}
+	// The smtp transport is for networks where the Gmail API cannot be
+	// reached, so the account is not looked up there.
+	settings, err := LoadGoogleConfig()
+	if err != nil {
+		return status, err
+	}
+	status.Transport = settings.transport()
+	if status.Transport == GoogleTransportSMTP {
+		status.Account = settings.Account
+		return status, nil
+	}
+	srv, err := gmail.NewService(ctx, option.WithHTTPClient(oauth2.NewClient(ctx, oauth2.StaticTokenSource(tok))))
+	if err != nil {
+		return status, fmt.Errorf("unable to retrieve Gmail client: %v", err)
+	}
+	profile, err := srv.Users.GetProfile("me").Context(ctx).Do()
+	if err != nil {
+		return status, fmt.Errorf("unable to get account: %v", err)
+	}
+	status.Account = profile.EmailAddress
+	return status, nil
+}
+
+// tokenScopes asks Google which scopes an access token was granted.
+func tokenScopes(ctx context.Context, accessToken string) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tokenInfoURL+"?access_token="+url.QueryEscape(accessToken), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get token info: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("unable to get token info: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var info struct {
+		Scope string `json:"scope"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, fmt.Errorf("unable to parse token info: %v", err)
+	}
+	return strings.Fields(info.Scope), nil
+}
+
+// RefreshGoogleToken gets a new access token now and saves it. Service
+// account tokens are not stored, so for them this only checks that a token
+// can still be obtained.
+func RefreshGoogleToken(ctx context.Context) (*oauth2.Token, error) {
+	if _, err := loadServiceAccount(); err == nil {
+		ts, _, err := googleTokenSource(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return ts.Token()
+	}
+
+	tok, err := googleToken.load()
+	if errors.Is(err, tokenstore.ErrNotFound) {
+		return nil, ErrNotLoggedIn
+	} else if err != nil {
+		return nil, err
+	}
+	if tok.RefreshToken == "" {
+		return nil, errors.New("the token has no refresh token, run 'gomailit setup google' again")
+	}
+	config, err := googleOAuthConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// Without an access token the source has to refresh.
+	ts := googleToken.tokenSource(config, &oauth2.Token{RefreshToken: tok.RefreshToken})
+	tok, err = ts.Token()
+	if err != nil {
+		return nil, fmt.Errorf("unable to refresh token: %v", err)
+	}
+	return tok, nil
+}
+
+// RevokeGoogleToken revokes the grant at Google and then deletes the token.
+// The token is kept when revocation fails, so that it can be retried.
+func RevokeGoogleToken(ctx context.Context) error {
+	if _, err := loadServiceAccount(); err == nil {
+		return errors.New("service account keys are revoked by deleting the key in the Google Cloud console, use 'gomailit auth logout' to remove it here")
+	}
+
+	tok, err := googleToken.load()
+	if errors.Is(err, tokenstore.ErrNotFound) {
+		return ErrNotLoggedIn
+	} else if err != nil {
+		return err
+	}
+
+	// Revoking the refresh token revokes the whole grant, including the
+	// access tokens issued from it.
+	value := tok.RefreshToken
+	if value == "" {
+		value = tok.AccessToken
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, revokeURL, strings.NewReader(url.Values{"token": {value}}.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to revoke token: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	// Google answers invalid_token for a grant that is already revoked or
+	// expired, which leaves nothing to revoke.
+	if resp.StatusCode != http.StatusOK && !strings.Contains(string(body), "invalid_token") {
+		return fmt.Errorf("unable to revoke token: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return googleToken.delete()
+}
+
+// LogoutGoogle forgets the Google credential of the selected profile
+// without revoking it: the token in every store and the service account
+// key.
+func LogoutGoogle() error {
+	if err := googleToken.delete(); err != nil {
+		return err
+	}
+	if err := os.Remove(utils.ServiceAccountPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("unable to remove service account: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package providers
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/latocchi/gomailit/internal/mail"
+	"github.com/latocchi/gomailit/internal/tokenstore"
+	"github.com/latocchi/gomailit/internal/utils"
+	"github.com/spf13/pflag"
+	"golang.org/x/oauth2"
+)
+
+// apiClient sends the requests of the email API providers.
+var apiClient = &http.Client{Timeout: 2 * time.Minute}
+
+// doAPI sends req and returns the headers and body of a successful
+// response. Failed responses are turned into an error by apiError.
+func doAPI(req *http.Request, apiError func(resp *http.Response, body []byte) error) (http.Header, []byte, error) {
+	resp, err := apiClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode >= 300 {
+		return nil, nil, apiError(resp, body)
+	}
+	return resp.Header, body, nil
+}
+
+// apiConfig is implemented by the settings of the email API providers.
+type apiConfig interface {
+	// secret returns the field holding the API key or token, which is kept
+	// in the token store rather than the settings file, or nil when the
+	// settings have no secret.
+	secret() *string
+}
+
+// The API keys and tokens of the email API providers are kept in the token
+// store chosen with 'setup <provider> --token-store', as the access token of
+// a token that never expires, so that their settings files hold no secret.
+var (
+	jmapToken     = &oauthToken{provider: "jmap"}
+	mailgunKey    = &oauthToken{provider: "mailgun"}
+	postmarkToken = &oauthToken{provider: "postmark"}
+	sendgridKey   = &oauthToken{provider: "sendgrid"}
+	sesSecret     = &oauthToken{provider: "ses"}
+)
+
+// tokenStoreFlag adds --token-store to the setup flags of an API provider,
+// whose secret is called what.
+func tokenStoreFlag(flags *pflag.FlagSet, what string) {
+	flags.String("token-store", "", "Where to keep the "+what+": keyring, file or encrypted-file (default keyring)")
+}
+
+// useTokenStoreFlag moves the secret of an API provider to the store given
+// with --token-store, if any.
+func useTokenStoreFlag(flags *pflag.FlagSet, token *oauthToken) error {
+	value, _ := flags.GetString("token-store")
+	if value == "" {
+		return nil
+	}
+	kind, err := tokenstore.ParseKind(value)
+	if err != nil {
+		return err
+	}
+	return token.switchStore(kind)
+}
+
+// saveAPIConfig writes the settings of an email API provider, and its
+// secret to the token store.
+func saveAPIConfig(token *oauthToken, config apiConfig) error {
+	provider := token.provider
+	if secret := config.secret(); secret != nil && *secret != "" {
+		if err := token.save(&oauth2.Token{AccessToken: *secret}); err != nil {
+			return fmt.Errorf("unable to save %s API key: %v", provider, err)
+		}
+	} else {
+		// Best effort, a secret left by an earlier setup is unused.
+		token.delete()
+	}
+	fmt.Printf("Saving %s settings to: %s\n", provider, utils.APIConfigPath(provider))
+	return writeAPIConfig(provider, config)
+}
+
+// writeAPIConfig writes the settings of an email API provider without its
+// secret.
+func writeAPIConfig(provider string, config apiConfig) error {
+	if secret := config.secret(); secret != nil {
+		value := *secret
+		*secret = ""
+		defer func() { *secret = value }()
+	}
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to encode %s settings: %v", provider, err)
+	}
+	if err := os.WriteFile(utils.APIConfigPath(provider), data, 0600); err != nil {
+		return fmt.Errorf("unable to save %s settings: %v", provider, err)
+	}
+	return nil
+}
+
+// loadAPIConfig reads the settings saved by saveAPIConfig into config, and
+// the secret from the token store. A secret that an older version saved in
+// the settings file is moved into the token store.
+func loadAPIConfig(token *oauthToken, config apiConfig) error {
+	provider := token.provider
+	data, err := os.ReadFile(utils.APIConfigPath(provider))
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%s is not set up, please run 'gomailit setup %s' first", provider, provider)
+	} else if err != nil {
+		return fmt.Errorf("unable to load %s settings: %v", provider, err)
+	}
+	if err := json.Unmarshal(data, config); err != nil {
+		return fmt.Errorf("unable to parse %s settings: %v", provider, err)
+	}
+
+	secret := config.secret()
+	switch {
+	case secret == nil:
+	case *secret != "":
+		if err := token.save(&oauth2.Token{AccessToken: *secret}); err != nil {
+			return fmt.Errorf("unable to move %s API key to the token store: %v", provider, err)
+		}
+		if err := writeAPIConfig(provider, config); err != nil {
+			return err
+		}
+	default:
+		tok, err := token.load()
+		if errors.Is(err, tokenstore.ErrNotFound) {
+			return fmt.Errorf("%s API key not found in %s, please run 'gomailit setup %s' again", provider, token.store().Location(), provider)
+		}
+		if err != nil {
+			return fmt.Errorf("unable to load %s API key: %v", provider, err)
+		}
+		*secret = tok.AccessToken
+	}
+	return nil
+}
+
+// promptSecret asks for a secret that was not given as a flag.
+func promptSecret(prompt string) string {
+	fmt.Print(prompt)
+	reader := bufio.NewReader(os.Stdin)
+	secret, _ := reader.ReadString('\n')
+	return strings.TrimRight(secret, "\r\n")
+}
+
+// withSender returns msg with the configured sender filled in when it has
+// none. Unlike mailbox providers, email APIs cannot tell who is sending.
+func withSender(msg *mail.Message, from, provider string) (*mail.Message, error) {
+	if msg.From != "" {
+		return msg, nil
+	}
+	if from == "" {
+		return nil, fmt.Errorf("no sender address configured, pass --from or set it with 'gomailit setup %s --from'", provider)
+	}
+	copied := *msg
+	copied.From = from
+	return &copied, nil
+}
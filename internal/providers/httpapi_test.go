@@ -0,0 +1,305 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package providers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/latocchi/gomailit/internal/mail"
+	"github.com/latocchi/gomailit/internal/tokenstore"
+	"github.com/latocchi/gomailit/internal/utils"
+	"github.com/spf13/pflag"
+)
+
+// apiResponse is what the fake API answers.
+type apiResponse struct {
+	status int
+	header map[string]string
+	body   string
+}
+
+// apiRequest is a request received by the fake API.
+type apiRequest struct {
+	method string
+	path   string
+	header http.Header
+	body   []byte
+}
+
+// apiServer is a fake email API that records the requests it receives and
+// gives every one the same response.
+type apiServer struct {
+	*httptest.Server
+	response apiResponse
+
+	mu       sync.Mutex
+	requests []apiRequest
+}
+
+func newAPIServer(t *testing.T, response apiResponse) *apiServer {
+	s := &apiServer{response: response}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		s.requests = append(s.requests, apiRequest{r.Method, r.URL.Path, r.Header.Clone(), body})
+		s.mu.Unlock()
+
+		for name, value := range s.response.header {
+			w.Header().Set(name, value)
+		}
+		w.WriteHeader(s.response.status)
+		io.WriteString(w, s.response.body)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// request returns the only request the fake API received.
+func (s *apiServer) request(t *testing.T) apiRequest {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.requests) != 1 {
+		t.Fatalf("API received %d requests, want 1", len(s.requests))
+	}
+	return s.requests[0]
+}
+
+// useURL points an endpoint variable to the fake API for the test.
+func useURL(t *testing.T, endpoint *string, url string) {
+	saved := *endpoint
+	*endpoint = url
+	t.Cleanup(func() { *endpoint = saved })
+}
+
+// apiTestMessage has a recipient of every kind, both bodies and an
+// attachment.
+func apiTestMessage() *mail.Message {
+	return &mail.Message{
+		From:     "Alice <alice@example.com>",
+		To:       []string{"Bob <bob@example.com>"},
+		Cc:       []string{"carol@example.com"},
+		Bcc:      []string{"audit@example.com"},
+		ReplyTo:  []string{"support@example.com"},
+		Subject:  "Report",
+		TextBody: "See attached",
+		HTMLBody: "<p>See attached</p>",
+		Headers:  map[string]string{"X-Campaign": "june"},
+		Attachments: []mail.Attachment{
+			{Filename: "report.pdf", Data: []byte("%PDF-1.4 report")},
+		},
+	}
+}
+
+// errorCase is an error response of a provider's API and how it must be
+// classified.
+type errorCase struct {
+	name       string
+	response   apiResponse
+	message    string
+	transient  bool
+	quota      bool
+	retryAfter time.Duration
+}
+
+// checkAPIError sends with a provider whose API answers with each error
+// case and checks how the error is classified.
+func checkAPIError(t *testing.T, cases []errorCase, send func(t *testing.T, url string) error) {
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newAPIServer(t, tt.response)
+			err := send(t, server.URL)
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("error = %v, want an APIError", err)
+			}
+			if apiErr.StatusCode != tt.response.status || apiErr.Message != tt.message {
+				t.Errorf("error = %d %q, want %d %q", apiErr.StatusCode, apiErr.Message, tt.response.status, tt.message)
+			}
+			if IsTransient(err) != tt.transient {
+				t.Errorf("IsTransient(%v) = %v", err, !tt.transient)
+			}
+			if IsQuotaExceeded(err) != tt.quota {
+				t.Errorf("IsQuotaExceeded(%v) = %v", err, !tt.quota)
+			}
+			if got := RetryAfter(err); got != tt.retryAfter {
+				t.Errorf("RetryAfter = %v, want %v", got, tt.retryAfter)
+			}
+		})
+	}
+}
+
+func TestAPIConfigSecretInTokenStore(t *testing.T) {
+	tests := []struct {
+		token  *oauthToken
+		config apiConfig
+		loaded apiConfig
+	}{
+		{sendgridKey, &SendGridConfig{APIKey: "SG.secret", From: "noreply@example.com"}, &SendGridConfig{}},
+		{mailgunKey, &MailgunConfig{Domain: "mg.example.com", APIKey: "key-secret", Region: "eu"}, &MailgunConfig{}},
+		{postmarkToken, &PostmarkConfig{ServerToken: "pm-secret", MessageStream: "outbound"}, &PostmarkConfig{}},
+		{sesSecret, &SESConfig{Region: "eu-west-1", AccessKeyID: "AKIAEXAMPLE", SecretAccessKey: "aws-secret"}, &SESConfig{}},
+		{jmapToken, &JMAPConfig{SessionURL: "https://jmap.example.com/session", Token: "jmap-secret"}, &JMAPConfig{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.token.provider, func(t *testing.T) {
+			useTempConfigDir(t)
+			secret := *tt.config.secret()
+
+			if err := saveAPIConfig(tt.token, tt.config); err != nil {
+				t.Fatal(err)
+			}
+			if *tt.config.secret() != secret {
+				t.Error("saving cleared the secret of the config")
+			}
+			data, err := os.ReadFile(utils.APIConfigPath(tt.token.provider))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(data), secret) {
+				t.Errorf("%s.json holds the secret:\n%s", tt.token.provider, data)
+			}
+			tok, err := tt.token.newStore(tokenstore.Keyring).Load()
+			if err != nil || tok.AccessToken != secret {
+				t.Errorf("keyring holds %v, %v", tok, err)
+			}
+
+			if err := loadAPIConfig(tt.token, tt.loaded); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(tt.loaded, tt.config) {
+				t.Errorf("loaded %+v, want %+v", tt.loaded, tt.config)
+			}
+		})
+	}
+}
+
+func TestAPIConfigLegacySecretMoved(t *testing.T) {
+	useTempConfigDir(t)
+
+	legacy := `{"api_key": "SG.secret", "from": "noreply@example.com"}`
+	if err := os.MkdirAll(filepath.Dir(utils.APIConfigPath("sendgrid")), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(utils.APIConfigPath("sendgrid"), []byte(legacy), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	config := &SendGridConfig{}
+	if err := loadAPIConfig(sendgridKey, config); err != nil {
+		t.Fatal(err)
+	}
+	if config.APIKey != "SG.secret" || config.From != "noreply@example.com" {
+		t.Errorf("loaded %+v", config)
+	}
+	data, _ := os.ReadFile(utils.APIConfigPath("sendgrid"))
+	if strings.Contains(string(data), "SG.secret") || !strings.Contains(string(data), "noreply@example.com") {
+		t.Errorf("sendgrid.json after the move:\n%s", data)
+	}
+	tok, err := sendgridKey.load()
+	if err != nil || tok.AccessToken != "SG.secret" {
+		t.Errorf("token store holds %v, %v", tok, err)
+	}
+
+	// The next load reads the key from the token store.
+	config = &SendGridConfig{}
+	if err := loadAPIConfig(sendgridKey, config); err != nil {
+		t.Fatal(err)
+	}
+	if config.APIKey != "SG.secret" {
+		t.Errorf("API key = %q", config.APIKey)
+	}
+}
+
+func TestAPIConfigMissingSecret(t *testing.T) {
+	useTempConfigDir(t)
+
+	if err := saveAPIConfig(postmarkToken, &PostmarkConfig{ServerToken: "pm-secret"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := postmarkToken.delete(); err != nil {
+		t.Fatal(err)
+	}
+	err := loadAPIConfig(postmarkToken, &PostmarkConfig{})
+	if err == nil || !strings.Contains(err.Error(), "please run 'gomailit setup postmark' again") {
+		t.Errorf("error = %v", err)
+	}
+
+	err = loadAPIConfig(mailgunKey, &MailgunConfig{})
+	if err == nil || !strings.Contains(err.Error(), "mailgun is not set up") {
+		t.Errorf("error = %v", err)
+	}
+}
+
+func TestAPIConfigSESWithoutAccessKey(t *testing.T) {
+	useTempConfigDir(t)
+
+	// A key left by an earlier setup with --access-key-id is removed.
+	if err := saveAPIConfig(sesSecret, &SESConfig{Region: "eu-west-1", AccessKeyID: "AKIAEXAMPLE", SecretAccessKey: "aws-secret"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveAPIConfig(sesSecret, &SESConfig{Region: "us-east-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sesSecret.load(); !errors.Is(err, tokenstore.ErrNotFound) {
+		t.Errorf("secret left in the token store: %v", err)
+	}
+
+	config := &SESConfig{}
+	if err := loadAPIConfig(sesSecret, config); err != nil {
+		t.Fatal(err)
+	}
+	if config.Region != "us-east-1" || config.SecretAccessKey != "" {
+		t.Errorf("loaded %+v", config)
+	}
+}
+
+func TestAPISetupTokenStore(t *testing.T) {
+	useTempConfigDir(t)
+
+	r, err := Lookup("sendgrid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	flags := pflag.NewFlagSet("setup", pflag.ContinueOnError)
+	r.Flags(flags)
+	if err := flags.Parse([]string{"--api-key", "SG.secret", "--token-store", "encrypted-file"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Setup(context.Background(), flags); err != nil {
+		t.Fatal(err)
+	}
+
+	if kind := sendgridKey.store().Kind(); kind != tokenstore.EncryptedFile {
+		t.Errorf("store = %s, want encrypted-file", kind)
+	}
+	if _, err := sendgridKey.newStore(tokenstore.Keyring).Load(); !errors.Is(err, tokenstore.ErrNotFound) {
+		t.Errorf("API key in the keyring: %v", err)
+	}
+	config := &SendGridConfig{}
+	if err := loadAPIConfig(sendgridKey, config); err != nil {
+		t.Fatal(err)
+	}
+	if config.APIKey != "SG.secret" {
+		t.Errorf("API key = %q", config.APIKey)
+	}
+
+	flags = pflag.NewFlagSet("setup", pflag.ContinueOnError)
+	r.Flags(flags)
+	flags.Parse([]string{"--api-key", "SG.secret", "--token-store", "vault"})
+	if err := r.Setup(context.Background(), flags); err == nil || !strings.Contains(err.Error(), "unknown token store") {
+		t.Errorf("error = %v", err)
+	}
+}
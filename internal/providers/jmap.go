@@ -0,0 +1,529 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	netmail "net/mail"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/latocchi/gomailit/internal/mail"
+	"github.com/latocchi/gomailit/internal/mime"
+	"github.com/spf13/pflag"
+)
+
+// Capabilities of the JMAP core, mail (RFC 8621) and submission specs.
+const (
+	jmapCore       = "urn:ietf:params:jmap:core"
+	jmapMail       = "urn:ietf:params:jmap:mail"
+	jmapSubmission = "urn:ietf:params:jmap:submission"
+)
+
+func init() {
+	Register(Registration{
+		Name:        "jmap",
+		Aliases:     []string{"fastmail"},
+		Description: "JMAP servers such as Fastmail, Stalwart or Cyrus",
+		Flags: func(flags *pflag.FlagSet) {
+			flags.String("url", "", "JMAP session URL, or the server's host to look it up at /.well-known/jmap")
+			flags.String("token", "", "API token for bearer authentication, prompted for when omitted")
+			flags.String("from", "", "Sender address, defaults to the first identity of the account")
+			tokenStoreFlag(flags, "API token")
+		},
+		Setup: func(ctx context.Context, flags *pflag.FlagSet) error {
+			config := &JMAPConfig{}
+			rawURL, _ := flags.GetString("url")
+			config.Token, _ = flags.GetString("token")
+			config.From, _ = flags.GetString("from")
+
+			if rawURL == "" {
+				return errors.New("--url is required, e.g. https://api.fastmail.com/jmap/session")
+			}
+			sessionURL, err := jmapSessionURL(rawURL)
+			if err != nil {
+				return err
+			}
+			config.SessionURL = sessionURL
+			if config.Token == "" {
+				config.Token = promptSecret("JMAP API token: ")
+			}
+			if config.Token == "" {
+				return errors.New("an API token is required")
+			}
+
+			// Check the token and the sender before saving anything.
+			p := NewJMAPProvider(config)
+			session, err := p.connect(ctx)
+			if err != nil {
+				return err
+			}
+			if config.From != "" {
+				if _, err := session.identity(config.From); err != nil {
+					return err
+				}
+			}
+			if err := useTokenStoreFlag(flags, jmapToken); err != nil {
+				return err
+			}
+			if err := saveAPIConfig(jmapToken, config); err != nil {
+				return err
+			}
+			fmt.Printf("JMAP provider set up for %s\n", session.Username)
+			return nil
+		},
+		New: func(ctx context.Context) (Provider, error) {
+			config := &JMAPConfig{}
+			if err := loadAPIConfig(jmapToken, config); err != nil {
+				return nil, err
+			}
+			return NewJMAPProvider(config), nil
+		},
+	})
+}
+
+// jmapSessionURL returns the session resource of a server. A bare host is
+// looked up at the well-known location of RFC 8620.
+func jmapSessionURL(rawURL string) (string, error) {
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "https://" + rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("invalid JMAP URL %q", rawURL)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/.well-known/jmap"
+	}
+	return u.String(), nil
+}
+
+// JMAPConfig holds the settings saved by 'gomailit setup jmap'.
+type JMAPConfig struct {
+	SessionURL string `json:"session_url"`
+	// Token is kept in the token store. It is only read from jmap.json
+	// files written by older versions.
+	Token string `json:"token,omitempty"`
+	From  string `json:"from,omitempty"`
+}
+
+func (c *JMAPConfig) secret() *string {
+	return &c.Token
+}
+
+// JMAPProvider sends email through a JMAP server: it uploads the message,
+// imports it into the Sent mailbox and submits it with an explicit
+// envelope. The session is looked up on first use and then shared.
+type JMAPProvider struct {
+	config *JMAPConfig
+
+	mu      sync.Mutex
+	session *jmapSession
+}
+
+func NewJMAPProvider(config *JMAPConfig) *JMAPProvider {
+	return &JMAPProvider{config: config}
+}
+
+// jmapSession is the part of the session resource gomailit uses, together
+// with what it looked up in the account.
+type jmapSession struct {
+	Username        string            `json:"username"`
+	APIURL          string            `json:"apiUrl"`
+	UploadURL       string            `json:"uploadUrl"`
+	PrimaryAccounts map[string]string `json:"primaryAccounts"`
+
+	accountID  string
+	mailboxID  string
+	identities []jmapIdentity
+}
+
+type jmapIdentity struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// identity returns the identity allowed to send as from. Identities whose
+// address starts with "*@" cover a whole domain.
+func (s *jmapSession) identity(from string) (*jmapIdentity, error) {
+	address := strings.ToLower(mail.BareAddress(from))
+	_, domain, _ := strings.Cut(address, "@")
+	var wildcard *jmapIdentity
+	for i, identity := range s.identities {
+		email := strings.ToLower(identity.Email)
+		if email == address {
+			return &s.identities[i], nil
+		}
+		if email == "*@"+domain && wildcard == nil {
+			wildcard = &s.identities[i]
+		}
+	}
+	if wildcard != nil {
+		return wildcard, nil
+	}
+	return nil, fmt.Errorf("%s is not an identity of the JMAP account %s", address, s.Username)
+}
+
+// connect returns the session, fetching it and the account's Sent mailbox
+// and identities the first time.
+func (p *JMAPProvider) connect(ctx context.Context) (*jmapSession, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.session != nil {
+		return p.session, nil
+	}
+
+	data, err := p.do(ctx, http.MethodGet, p.config.SessionURL, "", nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get JMAP session: %w", err)
+	}
+	session := &jmapSession{}
+	if err := json.Unmarshal(data, session); err != nil {
+		return nil, fmt.Errorf("unable to parse JMAP session: %v", err)
+	}
+	session.accountID = session.PrimaryAccounts[jmapMail]
+	if session.accountID == "" || session.PrimaryAccounts[jmapSubmission] == "" {
+		return nil, errors.New("the JMAP server does not offer mail submission for this account")
+	}
+	if session.PrimaryAccounts[jmapSubmission] != session.accountID {
+		return nil, errors.New("the JMAP server submits mail from another account than it stores it in, which is not supported")
+	}
+	// Some servers give the URLs as paths. They are not parsed as URLs,
+	// since the upload URL is a template whose braces would get escaped.
+	base, _ := url.Parse(p.config.SessionURL)
+	for _, link := range []*string{&session.APIURL, &session.UploadURL} {
+		if strings.HasPrefix(*link, "/") {
+			*link = base.Scheme + "://" + base.Host + *link
+		}
+	}
+
+	responses, err := p.call(ctx, session, []jmapCall{
+		{"Mailbox/get", map[string]any{"accountId": session.accountID, "ids": nil, "properties": []string{"id", "role"}}, "0"},
+		{"Identity/get", map[string]any{"accountId": session.accountID, "ids": nil}, "1"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var mailboxes struct {
+		List []struct {
+			ID   string `json:"id"`
+			Role string `json:"role"`
+		} `json:"list"`
+	}
+	if err := json.Unmarshal(responses[0], &mailboxes); err != nil {
+		return nil, fmt.Errorf("unable to parse JMAP mailboxes: %v", err)
+	}
+	// Sent mail is kept in the Sent mailbox, or in Drafts if there is none.
+	for _, role := range []string{"sent", "drafts"} {
+		for _, mailbox := range mailboxes.List {
+			if mailbox.Role == role && session.mailboxID == "" {
+				session.mailboxID = mailbox.ID
+			}
+		}
+	}
+	if session.mailboxID == "" {
+		return nil, errors.New("the JMAP account has no Sent or Drafts mailbox to keep sent mail in")
+	}
+
+	var identities struct {
+		List []jmapIdentity `json:"list"`
+	}
+	if err := json.Unmarshal(responses[1], &identities); err != nil {
+		return nil, fmt.Errorf("unable to parse JMAP identities: %v", err)
+	}
+	if len(identities.List) == 0 {
+		return nil, errors.New("the JMAP account has no identity to send as")
+	}
+	session.identities = identities.List
+
+	p.session = session
+	return session, nil
+}
+
+// Send returns the ID of the EmailSubmission created for the message.
+func (p *JMAPProvider) Send(ctx context.Context, msg *mail.Message) (string, error) {
+	if err := msg.Validate(); err != nil {
+		return "", err
+	}
+	session, err := p.connect(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if msg.From == "" {
+		copied := *msg
+		copied.From = p.config.From
+		if copied.From == "" {
+			first := session.identities[0]
+			copied.From = (&netmail.Address{Name: first.Name, Address: first.Email}).String()
+		}
+		msg = &copied
+	}
+	identity, err := session.identity(msg.From)
+	if err != nil {
+		return "", err
+	}
+
+	// Bcc recipients only go into the envelope, never the headers.
+	raw, err := mime.Build(msg)
+	if err != nil {
+		return "", fmt.Errorf("unable to build message: %v", err)
+	}
+	return p.submit(ctx, session, identity, mail.BareAddress(msg.From), bareAddresses(msg.Recipients()), raw)
+}
+
+// Account returns the address messages without a From are sent as: the
+// configured sender, or else the first identity of the account.
+func (p *JMAPProvider) Account(ctx context.Context) (string, error) {
+	if p.config.From != "" {
+		return mail.BareAddress(p.config.From), nil
+	}
+	session, err := p.connect(ctx)
+	if err != nil {
+		return "", err
+	}
+	return session.identities[0].Email, nil
+}
+
+// SendRaw submits a pre-built message to the envelope recipients.
+func (p *JMAPProvider) SendRaw(ctx context.Context, env mime.Envelope, raw []byte) (string, error) {
+	if len(env.Recipients) == 0 {
+		return "", errors.New("message has no recipients")
+	}
+	from := env.From
+	if from == "" {
+		from = mail.BareAddress(p.config.From)
+	}
+	if from == "" {
+		return "", errors.New("no envelope sender, the message has no From header and none is configured")
+	}
+	session, err := p.connect(ctx)
+	if err != nil {
+		return "", err
+	}
+	identity, err := session.identity(from)
+	if err != nil {
+		return "", err
+	}
+	return p.submit(ctx, session, identity, from, env.Recipients, mime.StripHeader(raw, "Bcc"))
+}
+
+// submit uploads the message, imports it into the Sent mailbox and submits
+// it in one request. The imported copy is removed again when the
+// submission is refused, so that Sent only holds mail that was sent.
+func (p *JMAPProvider) submit(ctx context.Context, session *jmapSession, identity *jmapIdentity, from string, recipients []string, raw []byte) (string, error) {
+	uploadURL := strings.ReplaceAll(session.UploadURL, "{accountId}", url.PathEscape(session.accountID))
+	data, err := p.do(ctx, http.MethodPost, uploadURL, "message/rfc822", raw)
+	if err != nil {
+		return "", fmt.Errorf("unable to upload message: %w", err)
+	}
+	var blob struct {
+		BlobID string `json:"blobId"`
+	}
+	if err := json.Unmarshal(data, &blob); err != nil {
+		return "", fmt.Errorf("unable to parse JMAP upload response: %v", err)
+	}
+
+	rcptTo := []map[string]string{}
+	for _, rcpt := range recipients {
+		rcptTo = append(rcptTo, map[string]string{"email": rcpt})
+	}
+	responses, err := p.call(ctx, session, []jmapCall{
+		{"Email/import", map[string]any{
+			"accountId": session.accountID,
+			"emails": map[string]any{
+				"m": map[string]any{
+					"blobId":     blob.BlobID,
+					"mailboxIds": map[string]bool{session.mailboxID: true},
+					"keywords":   map[string]bool{"$seen": true},
+				},
+			},
+		}, "0"},
+		{"EmailSubmission/set", map[string]any{
+			"accountId": session.accountID,
+			"create": map[string]any{
+				"s": map[string]any{
+					"identityId": identity.ID,
+					"emailId":    "#m",
+					"envelope": map[string]any{
+						"mailFrom": map[string]string{"email": from},
+						"rcptTo":   rcptTo,
+					},
+				},
+			},
+		}, "1"},
+	})
+	if err != nil {
+		// The import may have worked although the submission failed.
+		if responses != nil && responses[0] != nil {
+			if emailID, err := jmapCreated(responses[0], "m", "import"); err == nil {
+				p.discard(ctx, session, emailID)
+			}
+		}
+		return "", fmt.Errorf("unable to send email: %w", err)
+	}
+
+	emailID, err := jmapCreated(responses[0], "m", "import")
+	if err != nil {
+		return "", fmt.Errorf("unable to send email: %w", err)
+	}
+	submissionID, err := jmapCreated(responses[1], "s", "submission")
+	if err != nil {
+		p.discard(ctx, session, emailID)
+		return "", fmt.Errorf("unable to send email: %w", err)
+	}
+	return submissionID, nil
+}
+
+// discard removes the imported copy of a message that was not sent. It is
+// best effort, the send already failed.
+func (p *JMAPProvider) discard(ctx context.Context, session *jmapSession, emailID string) {
+	p.call(context.WithoutCancel(ctx), session, []jmapCall{
+		{"Email/set", map[string]any{"accountId": session.accountID, "destroy": []string{emailID}}, "0"},
+	})
+}
+
+// jmapCall is one method call of a JMAP request.
+type jmapCall struct {
+	name   string
+	args   any
+	callID string
+}
+
+func (c jmapCall) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]any{c.name, c.args, c.callID})
+}
+
+// call sends the method calls in one request and returns the arguments of
+// their responses in order. A method that failed fails the whole call, and
+// its response is left nil in the results returned with the error.
+func (p *JMAPProvider) call(ctx context.Context, session *jmapSession, calls []jmapCall) ([]json.RawMessage, error) {
+	request := map[string]any{
+		"using":       []string{jmapCore, jmapMail, jmapSubmission},
+		"methodCalls": calls,
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	data, err := p.do(ctx, http.MethodPost, session.APIURL, "application/json", body)
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		MethodResponses [][3]json.RawMessage `json:"methodResponses"`
+	}
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, fmt.Errorf("unable to parse JMAP response: %v", err)
+	}
+	// Responses are matched to the calls by their call ID.
+	results := make([]json.RawMessage, len(calls))
+	var failed error
+	for _, r := range response.MethodResponses {
+		var name, callID string
+		json.Unmarshal(r[0], &name)
+		json.Unmarshal(r[2], &callID)
+		i := slices.IndexFunc(calls, func(c jmapCall) bool { return c.callID == callID })
+		if i < 0 {
+			continue
+		}
+		if name == "error" {
+			var methodErr struct {
+				Type        string `json:"type"`
+				Description string `json:"description"`
+			}
+			json.Unmarshal(r[1], &methodErr)
+			if failed == nil {
+				failed = fmt.Errorf("JMAP %s failed: %s", calls[i].name, describeJMAPError(methodErr.Type, methodErr.Description))
+			}
+			continue
+		}
+		results[i] = r[1]
+	}
+	if failed != nil {
+		return results, failed
+	}
+	if slices.ContainsFunc(results, func(r json.RawMessage) bool { return r == nil }) {
+		return nil, errors.New("incomplete JMAP response")
+	}
+	return results, nil
+}
+
+// jmapCreated returns the server ID of an object created by a /set or
+// /import call, or why it was not created.
+func jmapCreated(response json.RawMessage, creationID, what string) (string, error) {
+	var result struct {
+		Created map[string]struct {
+			ID string `json:"id"`
+		} `json:"created"`
+		NotCreated map[string]struct {
+			Type        string `json:"type"`
+			Description string `json:"description"`
+		} `json:"notCreated"`
+	}
+	if err := json.Unmarshal(response, &result); err != nil {
+		return "", fmt.Errorf("unable to parse JMAP %s response: %v", what, err)
+	}
+	if created, ok := result.Created[creationID]; ok {
+		return created.ID, nil
+	}
+	if failed, ok := result.NotCreated[creationID]; ok {
+		return "", fmt.Errorf("JMAP %s refused: %s", what, describeJMAPError(failed.Type, failed.Description))
+	}
+	return "", fmt.Errorf("JMAP %s not created", what)
+}
+
+func describeJMAPError(kind, description string) string {
+	if description == "" {
+		return kind
+	}
+	return kind + ": " + description
+}
+
+// do sends an authenticated request and returns the response body.
+func (p *JMAPProvider) do(ctx context.Context, method, target, contentType string, body []byte) ([]byte, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.config.Token)
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	_, data, err := doAPI(req, jmapError)
+	return data, err
+}
+
+// jmapError turns a request-level JMAP error, an RFC 7807 problem
+// document, into an APIError.
+func jmapError(resp *http.Response, body []byte) error {
+	apiErr := newAPIError("JMAP", resp, body)
+	var problem struct {
+		Type   string `json:"type"`
+		Detail string `json:"detail"`
+	}
+	if json.Unmarshal(body, &problem) == nil && (problem.Type != "" || problem.Detail != "") {
+		if problem.Type != "about:blank" {
+			apiErr.Code = strings.TrimPrefix(problem.Type, "urn:ietf:params:jmap:error:")
+		}
+		apiErr.Message = problem.Detail
+	}
+	return apiErr
+}
@@ -0,0 +1,418 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/latocchi/gomailit/internal/mail"
+	"github.com/latocchi/gomailit/internal/mime"
+)
+
+// jmapServer is a fake JMAP server with one account, A1, that can send as
+// alice@example.com and keeps sent mail in mailbox M-sent.
+type jmapServer struct {
+	*httptest.Server
+
+	// responses overrides the response of a method, keyed by method name.
+	responses map[string][]any
+	// sessionStatus makes the session resource fail with a problem
+	// document.
+	sessionStatus int
+
+	mu       sync.Mutex
+	sessions int
+	uploads  [][]byte
+	calls    map[string][]map[string]any
+}
+
+func newJMAPServer(t *testing.T) *jmapServer {
+	s := &jmapServer{responses: map[string][]any{}, calls: map[string][]map[string]any{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/jmap", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/jmap/session", http.StatusTemporaryRedirect)
+	})
+	mux.HandleFunc("GET /jmap/session", s.session)
+	mux.HandleFunc("POST /jmap/upload/{account}/", s.upload)
+	mux.HandleFunc("POST /jmap/api", s.api)
+	s.Server = httptest.NewTLSServer(s.authorized(mux))
+	t.Cleanup(s.Close)
+
+	saved := apiClient
+	apiClient = s.Client()
+	t.Cleanup(func() { apiClient = saved })
+	return s
+}
+
+func (s *jmapServer) authorized(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer jmap-token" {
+			problem(w, http.StatusUnauthorized, "about:blank", "invalid token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func problem(w http.ResponseWriter, status int, kind, detail string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{"type": kind, "status": status, "detail": detail})
+}
+
+func (s *jmapServer) session(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.sessions++
+	s.mu.Unlock()
+	if s.sessionStatus != 0 {
+		problem(w, s.sessionStatus, "urn:ietf:params:jmap:error:limit", "too many sessions")
+		return
+	}
+	// The API URL is a path, the upload URL a template.
+	json.NewEncoder(w).Encode(map[string]any{
+		"username":  "alice@example.com",
+		"apiUrl":    "/jmap/api",
+		"uploadUrl": s.URL + "/jmap/upload/{accountId}/",
+		"primaryAccounts": map[string]string{
+			jmapMail:       "A1",
+			jmapSubmission: "A1",
+		},
+	})
+}
+
+func (s *jmapServer) upload(w http.ResponseWriter, r *http.Request) {
+	if r.PathValue("account") != "A1" || r.Header.Get("Content-Type") != "message/rfc822" {
+		problem(w, http.StatusBadRequest, "about:blank", "bad upload")
+		return
+	}
+	data, _ := io.ReadAll(r.Body)
+	s.mu.Lock()
+	s.uploads = append(s.uploads, data)
+	s.mu.Unlock()
+	fmt.Fprintf(w, `{"accountId":"A1","blobId":"B1","type":"message/rfc822","size":%d}`, len(data))
+}
+
+func (s *jmapServer) api(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Using       []string            `json:"using"`
+		MethodCalls [][]json.RawMessage `json:"methodCalls"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		problem(w, http.StatusBadRequest, "urn:ietf:params:jmap:error:notRequest", err.Error())
+		return
+	}
+	if !reflect.DeepEqual(request.Using, []string{jmapCore, jmapMail, jmapSubmission}) {
+		problem(w, http.StatusBadRequest, "urn:ietf:params:jmap:error:unknownCapability", "missing capability")
+		return
+	}
+
+	var responses [][]any
+	for _, call := range request.MethodCalls {
+		var name, callID string
+		var args map[string]any
+		json.Unmarshal(call[0], &name)
+		json.Unmarshal(call[1], &args)
+		json.Unmarshal(call[2], &callID)
+		s.mu.Lock()
+		s.calls[name] = append(s.calls[name], args)
+		s.mu.Unlock()
+
+		if args["accountId"] != "A1" {
+			responses = append(responses, []any{"error", map[string]string{"type": "accountNotFound"}, callID})
+			continue
+		}
+		if response, ok := s.responses[name]; ok {
+			responses = append(responses, []any{response[0], response[1], callID})
+			continue
+		}
+		var result any
+		switch name {
+		case "Mailbox/get":
+			result = map[string]any{"list": []map[string]string{{"id": "M-inbox", "role": "inbox"}, {"id": "M-sent", "role": "sent"}}}
+		case "Identity/get":
+			result = map[string]any{"list": []jmapIdentity{{ID: "I1", Name: "Alice", Email: "alice@example.com"}}}
+		case "Email/import":
+			result = map[string]any{"created": map[string]any{"m": map[string]string{"id": "E1", "blobId": "B1"}}}
+		case "EmailSubmission/set":
+			result = map[string]any{"created": map[string]any{"s": map[string]string{"id": "S1"}}}
+		case "Email/set":
+			result = map[string]any{"destroyed": args["destroy"]}
+		default:
+			responses = append(responses, []any{"error", map[string]string{"type": "unknownMethod"}, callID})
+			continue
+		}
+		responses = append(responses, []any{name, result, callID})
+	}
+	json.NewEncoder(w).Encode(map[string]any{"methodResponses": responses, "sessionState": "1"})
+}
+
+// call returns the arguments of the only call of method.
+func (s *jmapServer) call(t *testing.T, method string) map[string]any {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.calls[method]) != 1 {
+		t.Fatalf("%s called %d times, want once", method, len(s.calls[method]))
+	}
+	return s.calls[method][0]
+}
+
+// provider returns a provider set up with the server's host only, so that
+// the session is discovered at /.well-known/jmap.
+func (s *jmapServer) provider(t *testing.T) *JMAPProvider {
+	sessionURL, err := jmapSessionURL(strings.TrimPrefix(s.URL, "https://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(sessionURL, "/.well-known/jmap") {
+		t.Fatalf("session URL = %s", sessionURL)
+	}
+	return NewJMAPProvider(&JMAPConfig{SessionURL: sessionURL, Token: "jmap-token"})
+}
+
+// jsonValue round-trips v through JSON, the way the server sees it.
+func jsonValue(t *testing.T, v any) any {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	return decoded
+}
+
+func TestJMAPSend(t *testing.T) {
+	server := newJMAPServer(t)
+	p := server.provider(t)
+
+	msg := &mail.Message{
+		To:       []string{"Bob <bob@example.com>"},
+		Cc:       []string{"carol@example.com"},
+		Bcc:      []string{"audit@example.com"},
+		Subject:  "Report",
+		TextBody: "Hi",
+	}
+	id, err := p.Send(context.Background(), msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "S1" {
+		t.Errorf("id = %q", id)
+	}
+
+	// The sender defaults to the account's first identity.
+	upload := string(server.uploads[0])
+	if !strings.Contains(upload, "From: \"Alice\" <alice@example.com>\r\n") || strings.Contains(upload, "audit@example.com") {
+		t.Errorf("uploaded message:\n%s", upload)
+	}
+	wantImport := map[string]any{
+		"accountId": "A1",
+		"emails": map[string]any{"m": map[string]any{
+			"blobId":     "B1",
+			"mailboxIds": map[string]any{"M-sent": true},
+			"keywords":   map[string]any{"$seen": true},
+		}},
+	}
+	if got := server.call(t, "Email/import"); !reflect.DeepEqual(got, wantImport) {
+		t.Errorf("Email/import = %v\nwant %v", got, wantImport)
+	}
+	wantSubmission := jsonValue(t, map[string]any{
+		"accountId": "A1",
+		"create": map[string]any{"s": map[string]any{
+			"identityId": "I1",
+			"emailId":    "#m",
+			"envelope": map[string]any{
+				"mailFrom": map[string]string{"email": "alice@example.com"},
+				"rcptTo":   []map[string]string{{"email": "bob@example.com"}, {"email": "carol@example.com"}, {"email": "audit@example.com"}},
+			},
+		}},
+	})
+	if got := server.call(t, "EmailSubmission/set"); !reflect.DeepEqual(got, wantSubmission) {
+		t.Errorf("EmailSubmission/set = %v\nwant %v", got, wantSubmission)
+	}
+
+	// The session is looked up once and then reused.
+	if _, err := p.Send(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	if server.sessions != 1 {
+		t.Errorf("session fetched %d times", server.sessions)
+	}
+}
+
+func TestJMAPSendRaw(t *testing.T) {
+	server := newJMAPServer(t)
+
+	raw := []byte("From: alice@example.com\r\nTo: bob@example.com\r\nBcc: audit@example.com\r\nSubject: hi\r\n\r\nHi\r\n")
+	env := mime.Envelope{From: "alice@example.com", Recipients: []string{"bob@example.com", "audit@example.com"}}
+	if _, err := server.provider(t).SendRaw(context.Background(), env, raw); err != nil {
+		t.Fatal(err)
+	}
+
+	if upload := string(server.uploads[0]); upload != "From: alice@example.com\r\nTo: bob@example.com\r\nSubject: hi\r\n\r\nHi\r\n" {
+		t.Errorf("uploaded message = %q", upload)
+	}
+	submission := server.call(t, "EmailSubmission/set")
+	envelope := submission["create"].(map[string]any)["s"].(map[string]any)["envelope"]
+	want := jsonValue(t, map[string]any{
+		"mailFrom": map[string]string{"email": "alice@example.com"},
+		"rcptTo":   []map[string]string{{"email": "bob@example.com"}, {"email": "audit@example.com"}},
+	})
+	if !reflect.DeepEqual(envelope, want) {
+		t.Errorf("envelope = %v\nwant %v", envelope, want)
+	}
+}
+
+func TestJMAPUnknownIdentity(t *testing.T) {
+	server := newJMAPServer(t)
+	msg := &mail.Message{From: "mallory@example.org", To: []string{"bob@example.com"}, TextBody: "Hi"}
+	_, err := server.provider(t).Send(context.Background(), msg)
+	if err == nil || !strings.Contains(err.Error(), "not an identity") {
+		t.Errorf("error = %v, want the sender to be refused", err)
+	}
+	if len(server.uploads) != 0 {
+		t.Error("message uploaded for an unknown identity")
+	}
+}
+
+func TestJMAPAccount(t *testing.T) {
+	server := newJMAPServer(t)
+	provider := server.provider(t)
+	if _, ok := Provider(provider).(AccountReporter); !ok {
+		t.Fatal("JMAPProvider does not report its account")
+	}
+	account, err := provider.Account(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account != "alice@example.com" {
+		t.Errorf("account = %q, want the first identity", account)
+	}
+
+	provider.config.From = "Alice <alice+news@example.com>"
+	if account, err := provider.Account(context.Background()); err != nil || account != "alice+news@example.com" {
+		t.Errorf("account = %q, %v, want the configured sender", account, err)
+	}
+}
+
+func TestJMAPErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		responses map[string][]any
+		want      string
+		// discarded is whether the imported copy must be removed again.
+		discarded bool
+	}{
+		{
+			name: "submission method error",
+			responses: map[string][]any{
+				"EmailSubmission/set": {"error", map[string]string{"type": "forbiddenFrom", "description": "not allowed"}},
+			},
+			want:      "JMAP EmailSubmission/set failed: forbiddenFrom: not allowed",
+			discarded: true,
+		},
+		{
+			name: "submission not created",
+			responses: map[string][]any{
+				"EmailSubmission/set": {"EmailSubmission/set", map[string]any{"notCreated": map[string]any{"s": map[string]string{"type": "forbiddenToSend", "description": "daily limit reached"}}}},
+			},
+			want:      "JMAP submission refused: forbiddenToSend: daily limit reached",
+			discarded: true,
+		},
+		{
+			name: "import method error",
+			responses: map[string][]any{
+				"Email/import":        {"error", map[string]string{"type": "overQuota"}},
+				"EmailSubmission/set": {"error", map[string]string{"type": "invalidResultReference"}},
+			},
+			want: "JMAP Email/import failed: overQuota",
+		},
+		{
+			name: "import not created",
+			responses: map[string][]any{
+				"Email/import":        {"Email/import", map[string]any{"notCreated": map[string]any{"m": map[string]string{"type": "blobNotFound"}}}},
+				"EmailSubmission/set": {"EmailSubmission/set", map[string]any{"notCreated": map[string]any{"s": map[string]string{"type": "invalidProperties"}}}},
+			},
+			want: "JMAP import refused: blobNotFound",
+		},
+		{
+			name: "no sent mailbox",
+			responses: map[string][]any{
+				"Mailbox/get": {"Mailbox/get", map[string]any{"list": []map[string]string{{"id": "M-inbox", "role": "inbox"}}}},
+			},
+			want: "no Sent or Drafts mailbox",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newJMAPServer(t)
+			server.responses = tt.responses
+			msg := &mail.Message{To: []string{"bob@example.com"}, TextBody: "Hi"}
+			_, err := server.provider(t).Send(context.Background(), msg)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("error = %v, want %q", err, tt.want)
+			}
+
+			server.mu.Lock()
+			destroys := server.calls["Email/set"]
+			server.mu.Unlock()
+			if tt.discarded {
+				if len(destroys) != 1 || !reflect.DeepEqual(destroys[0]["destroy"], []any{"E1"}) {
+					t.Errorf("Email/set calls = %v, want the imported copy destroyed", destroys)
+				}
+			} else if len(destroys) != 0 {
+				t.Errorf("Email/set called: %v", destroys)
+			}
+		})
+	}
+}
+
+func TestJMAPRequestErrors(t *testing.T) {
+	server := newJMAPServer(t)
+	p := server.provider(t)
+	p.config.Token = "wrong"
+	msg := &mail.Message{To: []string{"bob@example.com"}, TextBody: "Hi"}
+
+	_, err := p.Send(context.Background(), msg)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized || apiErr.Message != "invalid token" || apiErr.Code != "" {
+		t.Errorf("wrong token: %v", err)
+	}
+
+	server.sessionStatus = http.StatusTooManyRequests
+	p.config.Token = "jmap-token"
+	_, err = p.Send(context.Background(), msg)
+	if !errors.As(err, &apiErr) || apiErr.Code != "limit" || apiErr.Message != "too many sessions" || !IsTransient(err) {
+		t.Errorf("rate limited session: %v", err)
+	}
+}
+
+func TestJMAPSessionURL(t *testing.T) {
+	for input, want := range map[string]string{
+		"fastmail.com":                           "https://fastmail.com/.well-known/jmap",
+		"https://mail.example.com/":              "https://mail.example.com/.well-known/jmap",
+		"https://api.fastmail.com/jmap/session":  "https://api.fastmail.com/jmap/session",
+		"http://localhost:8080/.well-known/jmap": "http://localhost:8080/.well-known/jmap",
+	} {
+		if got, err := jmapSessionURL(input); err != nil || got != want {
+			t.Errorf("jmapSessionURL(%q) = %q, %v, want %q", input, got, err, want)
+		}
+	}
+	if _, err := jmapSessionURL("https://"); err == nil {
+		t.Error("URL without host accepted")
+	}
+}
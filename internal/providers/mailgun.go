@@ -0,0 +1,167 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+
+	"github.com/latocchi/gomailit/internal/mail"
+	"github.com/latocchi/gomailit/internal/mime"
+	"github.com/spf13/pflag"
+)
+
+// mailgunURLs are the API endpoints of Mailgun's regions.
+var mailgunURLs = map[string]string{
+	"us": "https://api.mailgun.net",
+	"eu": "https://api.eu.mailgun.net",
+}
+
+func init() {
+	Register(Registration{
+		Name:        "mailgun",
+		Description: "Mailgun (messages.mime API)",
+		Flags: func(flags *pflag.FlagSet) {
+			flags.String("domain", "", "Sending domain, e.g. mg.example.com")
+			flags.String("api-key", "", "Mailgun API key or domain sending key, prompted for when omitted")
+			flags.String("region", "us", "Region of the domain: us or eu")
+			flags.String("from", "", "Sender address at the sending domain")
+			tokenStoreFlag(flags, "API key")
+		},
+		Setup: func(ctx context.Context, flags *pflag.FlagSet) error {
+			config := &MailgunConfig{}
+			config.Domain, _ = flags.GetString("domain")
+			config.APIKey, _ = flags.GetString("api-key")
+			config.Region, _ = flags.GetString("region")
+			config.From, _ = flags.GetString("from")
+
+			if config.Domain == "" {
+				return errors.New("--domain is required")
+			}
+			if _, ok := mailgunURLs[config.Region]; !ok {
+				return fmt.Errorf("unsupported mailgun region: %s", config.Region)
+			}
+			if config.APIKey == "" {
+				config.APIKey = promptSecret(fmt.Sprintf("Mailgun API key for %s: ", config.Domain))
+			}
+			if config.APIKey == "" {
+				return errors.New("an API key is required")
+			}
+			if err := useTokenStoreFlag(flags, mailgunKey); err != nil {
+				return err
+			}
+			if err := saveAPIConfig(mailgunKey, config); err != nil {
+				return err
+			}
+			fmt.Printf("Mailgun provider configured for %s\n", config.Domain)
+			return nil
+		},
+		New: func(ctx context.Context) (Provider, error) {
+			config := &MailgunConfig{}
+			if err := loadAPIConfig(mailgunKey, config); err != nil {
+				return nil, err
+			}
+			return &MailgunProvider{config: config}, nil
+		},
+	})
+}
+
+// MailgunConfig holds the settings saved by 'gomailit setup mailgun'.
+type MailgunConfig struct {
+	Domain string `json:"domain"`
+	// APIKey is kept in the token store. It is only read from mailgun.json
+	// files written by older versions.
+	APIKey string `json:"api_key,omitempty"`
+	// Region is "us" or "eu".
+	Region string `json:"region"`
+	From   string `json:"from,omitempty"`
+}
+
+func (c *MailgunConfig) secret() *string {
+	return &c.APIKey
+}
+
+// MailgunProvider sends email through Mailgun's messages.mime endpoint,
+// which takes the MIME message and the recipients separately.
+type MailgunProvider struct {
+	config *MailgunConfig
+}
+
+func (p *MailgunProvider) Send(ctx context.Context, msg *mail.Message) (string, error) {
+	if err := msg.Validate(); err != nil {
+		return "", err
+	}
+	msg, err := withSender(msg, p.config.From, "mailgun")
+	if err != nil {
+		return "", err
+	}
+	raw, err := mime.Build(msg)
+	if err != nil {
+		return "", fmt.Errorf("unable to build message: %v", err)
+	}
+	return p.send(ctx, bareAddresses(msg.Recipients()), raw)
+}
+
+// SendRaw submits a pre-built message to the envelope recipients.
+func (p *MailgunProvider) SendRaw(ctx context.Context, env mime.Envelope, raw []byte) (string, error) {
+	if len(env.Recipients) == 0 {
+		return "", errors.New("message has no recipients")
+	}
+	return p.send(ctx, env.Recipients, mime.StripHeader(raw, "Bcc"))
+}
+
+// send returns the ID Mailgun gives the message, which is also its
+// Message-ID.
+func (p *MailgunProvider) send(ctx context.Context, recipients []string, raw []byte) (string, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for _, rcpt := range recipients {
+		form.WriteField("to", rcpt)
+	}
+	part, err := form.CreateFormFile("message", "message.eml")
+	if err != nil {
+		return "", err
+	}
+	part.Write(raw)
+	if err := form.Close(); err != nil {
+		return "", err
+	}
+
+	endpoint := mailgunURLs[p.config.Region] + "/v3/" + url.PathEscape(p.config.Domain) + "/messages.mime"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, &body)
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth("api", p.config.APIKey)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	_, data, err := doAPI(req, mailgunError)
+	if err != nil {
+		return "", fmt.Errorf("unable to send email: %w", err)
+	}
+	var response struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(data, &response); err != nil {
+		return "", fmt.Errorf("unable to parse Mailgun response: %v", err)
+	}
+	return response.ID, nil
+}
+
+func mailgunError(resp *http.Response, body []byte) error {
+	apiErr := newAPIError("Mailgun", resp, body)
+	var payload struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &payload) == nil && payload.Message != "" {
+		apiErr.Message = payload.Message
+	}
+	return apiErr
+}
@@ -0,0 +1,140 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	stdmime "mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/latocchi/gomailit/internal/mime"
+)
+
+// useMailgunURL points the US region to the fake API for the test.
+func useMailgunURL(t *testing.T, url string) {
+	saved := mailgunURLs["us"]
+	mailgunURLs["us"] = url
+	t.Cleanup(func() { mailgunURLs["us"] = saved })
+}
+
+func mailgunTestProvider() *MailgunProvider {
+	return &MailgunProvider{config: &MailgunConfig{Domain: "mg.example.com", APIKey: "key-123", Region: "us"}}
+}
+
+// mailgunForm decodes a messages.mime request into its recipients and
+// message.
+func mailgunForm(t *testing.T, req apiRequest) (to []string, message string) {
+	t.Helper()
+	if req.method != http.MethodPost || req.path != "/v3/mg.example.com/messages.mime" {
+		t.Errorf("request = %s %s", req.method, req.path)
+	}
+	if auth := req.header.Get("Authorization"); auth != "Basic "+base64.StdEncoding.EncodeToString([]byte("api:key-123")) {
+		t.Errorf("Authorization = %q", auth)
+	}
+	mediaType, params, err := stdmime.ParseMediaType(req.header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" {
+		t.Fatalf("Content-Type = %q", req.header.Get("Content-Type"))
+	}
+	form, err := multipart.NewReader(bytes.NewReader(req.body), params["boundary"]).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := form.File["message"]
+	if len(files) != 1 {
+		t.Fatalf("%d message parts", len(files))
+	}
+	f, err := files[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return form.Value["to"], string(data)
+}
+
+func TestMailgunSend(t *testing.T) {
+	server := newAPIServer(t, apiResponse{status: http.StatusOK, body: `{"id":"<mg-id@mg.example.com>","message":"Queued. Thank you."}`})
+	useMailgunURL(t, server.URL)
+
+	id, err := mailgunTestProvider().Send(context.Background(), apiTestMessage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "<mg-id@mg.example.com>" {
+		t.Errorf("id = %q", id)
+	}
+
+	to, message := mailgunForm(t, server.request(t))
+	if strings.Join(to, ",") != "bob@example.com,carol@example.com,audit@example.com" {
+		t.Errorf("to = %v", to)
+	}
+	if !strings.Contains(message, "Subject: Report\r\n") || strings.Contains(message, "audit@example.com") {
+		t.Errorf("message:\n%s", message)
+	}
+}
+
+func TestMailgunSendRaw(t *testing.T) {
+	server := newAPIServer(t, apiResponse{status: http.StatusOK, body: `{"id":"<mg-id@mg.example.com>"}`})
+	useMailgunURL(t, server.URL)
+
+	raw := []byte("To: bob@example.com\r\nBcc: audit@example.com\r\nSubject: hi\r\n\r\nHi\r\n")
+	env := mime.Envelope{From: "alice@example.com", Recipients: []string{"bob@example.com", "audit@example.com"}}
+	if _, err := mailgunTestProvider().SendRaw(context.Background(), env, raw); err != nil {
+		t.Fatal(err)
+	}
+
+	to, message := mailgunForm(t, server.request(t))
+	if strings.Join(to, ",") != "bob@example.com,audit@example.com" {
+		t.Errorf("to = %v", to)
+	}
+	if message != "To: bob@example.com\r\nSubject: hi\r\n\r\nHi\r\n" {
+		t.Errorf("message = %q", message)
+	}
+}
+
+func TestMailgunErrors(t *testing.T) {
+	checkAPIError(t, []errorCase{
+		{
+			name: "rate limited",
+			response: apiResponse{
+				status: http.StatusTooManyRequests,
+				header: map[string]string{"Retry-After": "10"},
+				body:   `{"message":"Too many requests"}`,
+			},
+			message:    "Too many requests",
+			transient:  true,
+			retryAfter: 10 * time.Second,
+		},
+		{
+			name:     "wrong key",
+			response: apiResponse{status: http.StatusUnauthorized, body: "Forbidden"},
+			message:  "Forbidden",
+		},
+		{
+			name:     "invalid request",
+			response: apiResponse{status: http.StatusBadRequest, body: `{"message":"to parameter is not a valid address. please check documentation"}`},
+			message:  "to parameter is not a valid address. please check documentation",
+		},
+		{
+			name:      "server error",
+			response:  apiResponse{status: http.StatusBadGateway, body: "bad gateway"},
+			message:   "bad gateway",
+			transient: true,
+		},
+	}, func(t *testing.T, url string) error {
+		useMailgunURL(t, url)
+		_, err := mailgunTestProvider().Send(context.Background(), apiTestMessage())
+		return err
+	})
+}
@@ -0,0 +1,226 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package providers
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// authTimeout is how long the authorization flow waits for the user.
+const authTimeout = 5 * time.Minute
+
+// webAuth holds the options of the OAuth authorization flow.
+type webAuth struct {
+	// NoBrowser prints the authorization link instead of opening it, and
+	// reads the code or the redirect URL pasted by the user from In.
+	NoBrowser bool
+	// Open opens a link in the browser, openBrowser by default.
+	Open func(link string) error
+	In   io.Reader
+	Out  io.Writer
+	// RedirectHost is the loopback host name in the redirect URL,
+	// 127.0.0.1 by default. Some identity providers only accept the
+	// redirect URL registered for the app, such as http://localhost.
+	RedirectHost string
+}
+
+// defaultWebAuth opens the browser when there seems to be one.
+func defaultWebAuth(noBrowser bool) webAuth {
+	return webAuth{
+		NoBrowser: noBrowser || !browserAvailable(),
+		Open:      openBrowser,
+		In:        os.Stdin,
+		Out:       os.Stdout,
+	}
+}
+
+// authResult is the outcome of the redirect back from the consent screen.
+type authResult struct {
+	code string
+	err  error
+}
+
+// getTokenFromWeb runs the authorization code flow with PKCE. The redirect
+// goes to a local server on a random free port. Without a browser the user
+// opens the link elsewhere and pastes back the code or the URL they were
+// redirected to.
+func getTokenFromWeb(ctx context.Context, config *oauth2.Config, auth webAuth) (*oauth2.Token, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("unable to start local server: %v", err)
+	}
+	defer listener.Close()
+
+	// Work on a copy so that the redirect URL does not leak to the caller.
+	cfg := *config
+	host := auth.RedirectHost
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	cfg.RedirectURL = fmt.Sprintf("http://%s:%d", host, listener.Addr().(*net.TCPAddr).Port)
+
+	state, err := randomState()
+	if err != nil {
+		return nil, err
+	}
+	verifier := oauth2.GenerateVerifier()
+	authURL := cfg.AuthCodeURL(state, oauth2.AccessTypeOffline, oauth2.S256ChallengeOption(verifier))
+
+	results := make(chan authResult, 1)
+	deliver := func(result authResult) {
+		select {
+		case results <- result:
+		default:
+		}
+	}
+
+	srv := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			query := r.URL.Query()
+			if r.URL.Path != "/" || (!query.Has("code") && !query.Has("error")) {
+				http.NotFound(w, r)
+				return
+			}
+			code, err := parseCallback(query, state)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			} else {
+				fmt.Fprintln(w, "Authorization complete. You may now close this window.")
+			}
+			deliver(authResult{code, err})
+		}),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go srv.Serve(listener)
+	defer srv.Close()
+
+	if auth.NoBrowser {
+		fmt.Fprintf(auth.Out, "Open this link in a browser and grant access:\n%v\n\n", authURL)
+		fmt.Fprintf(auth.Out, "The browser is then sent to a %s address, which fails to load on another machine.\n", host)
+		fmt.Fprint(auth.Out, "Paste that address, or the code in it, here: ")
+		go func() {
+			line, err := bufio.NewReader(auth.In).ReadString('\n')
+			if err != nil && line == "" {
+				deliver(authResult{err: fmt.Errorf("unable to read authorization code: %v", err)})
+				return
+			}
+			code, err := parsePastedCode(line, state)
+			deliver(authResult{code, err})
+		}()
+	} else {
+		fmt.Fprintf(auth.Out, "Your browser will open for authorization.\nIf it doesn't, open this link manually:\n%v\n", authURL)
+		if err := auth.Open(authURL); err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to open a browser: %v\n", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, authTimeout)
+	defer cancel()
+
+	var result authResult
+	select {
+	case result = <-results:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("authorization not completed: %v", ctx.Err())
+	}
+	if result.err != nil {
+		return nil, result.err
+	}
+
+	tok, err := cfg.Exchange(ctx, result.code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve token from web: %v", err)
+	}
+	return tok, nil
+}
+
+// parseCallback returns the code of a redirect back from the consent
+// screen, after checking that it answers this request.
+func parseCallback(query url.Values, state string) (string, error) {
+	if reason := query.Get("error"); reason != "" {
+		return "", fmt.Errorf("authorization failed: %s", reason)
+	}
+	if subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(state)) != 1 {
+		return "", errors.New("authorization failed: state does not match, the response is not for this request")
+	}
+	code := query.Get("code")
+	if code == "" {
+		return "", errors.New("authorization failed: no code in the response")
+	}
+	return code, nil
+}
+
+// parsePastedCode accepts either the bare code or the whole redirect URL.
+// Only the URL carries the state, so a bare code is taken as it is.
+func parsePastedCode(input, state string) (string, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return "", errors.New("no authorization code given")
+	}
+	if !strings.Contains(input, "?") {
+		return input, nil
+	}
+	u, err := url.Parse(input)
+	if err != nil {
+		return "", fmt.Errorf("unable to parse redirect URL: %v", err)
+	}
+	return parseCallback(u.Query(), state)
+}
+
+func randomState() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("unable to generate state: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// browserAvailable guesses whether a browser can be opened on this machine:
+// not over SSH, and not on a Unix desktop-less server.
+func browserAvailable() bool {
+	if os.Getenv("SSH_CONNECTION") != "" || os.Getenv("SSH_TTY") != "" {
+		return false
+	}
+	switch runtime.GOOS {
+	case "windows", "darwin":
+		return true
+	}
+	return os.Getenv("DISPLAY") != "" || os.Getenv("WAYLAND_DISPLAY") != ""
+}
+
+// openBrowser opens link with the desktop's default browser.
+func openBrowser(link string) error {
+	var c *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		c = exec.Command("rundll32", "url.dll,FileProtocolHandler", link)
+	case "darwin":
+		c = exec.Command("open", link)
+	default:
+		c = exec.Command("xdg-open", link)
+	}
+	if err := c.Start(); err != nil {
+		return err
+	}
+	// Reap the process without waiting for the browser to exit.
+	go c.Wait()
+	return nil
+}
@@ -0,0 +1,261 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package providers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+// authServer is a fake authorization server. Its token endpoint only
+// exchanges the code it issued, and only with the matching PKCE verifier.
+type authServer struct {
+	*httptest.Server
+	t *testing.T
+
+	mu        sync.Mutex
+	challenge string
+	redirect  string
+	exchanges int
+}
+
+const authCode = "code-123"
+
+func newAuthServer(t *testing.T) *authServer {
+	s := &authServer{t: t}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.token))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *authServer) config() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     "client-id",
+		ClientSecret: "client-secret",
+		Scopes:       []string{"mail.send"},
+		Endpoint: oauth2.Endpoint{
+			AuthURL:   s.URL + "/auth",
+			TokenURL:  s.URL + "/token",
+			AuthStyle: oauth2.AuthStyleInParams,
+		},
+	}
+}
+
+// authorize checks the authorization link the way the consent screen
+// would, and returns the query of its redirect.
+func (s *authServer) authorize(link string) (redirect *url.URL, state string) {
+	s.t.Helper()
+	u, err := url.Parse(link)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	query := u.Query()
+	for key, want := range map[string]string{
+		"client_id":             "client-id",
+		"response_type":         "code",
+		"access_type":           "offline",
+		"code_challenge_method": "S256",
+	} {
+		if got := query.Get(key); got != want {
+			s.t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+	if query.Get("code_challenge") == "" || query.Get("state") == "" {
+		s.t.Errorf("no PKCE challenge or state in %s", link)
+	}
+	redirect, err = url.Parse(query.Get("redirect_uri"))
+	if err != nil || redirect.Scheme != "http" || !strings.HasPrefix(redirect.Host, "127.0.0.1:") {
+		s.t.Errorf("redirect_uri = %q, want a loopback URL", query.Get("redirect_uri"))
+	}
+
+	s.mu.Lock()
+	s.challenge = query.Get("code_challenge")
+	s.redirect = query.Get("redirect_uri")
+	s.mu.Unlock()
+	return redirect, query.Get("state")
+}
+
+func (s *authServer) token(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.exchanges++
+
+	if r.URL.Path != "/token" || r.ParseForm() != nil {
+		http.NotFound(w, r)
+		return
+	}
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	switch {
+	case r.PostForm.Get("grant_type") != "authorization_code",
+		r.PostForm.Get("code") != authCode,
+		r.PostForm.Get("redirect_uri") != s.redirect,
+		base64.RawURLEncoding.EncodeToString(sum[:]) != s.challenge:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error":"invalid_grant"}`)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprint(w, `{"access_token":"access-token","refresh_token":"refresh-token","token_type":"Bearer","expires_in":3600}`)
+}
+
+// browser returns an Open function that follows the redirect to the local
+// server with the given query, as the browser would after consent.
+func (s *authServer) browser(query func(state string) url.Values) func(string) error {
+	return func(link string) error {
+		redirect, state := s.authorize(link)
+		redirect.RawQuery = query(state).Encode()
+		go func() {
+			resp, err := http.Get(redirect.String())
+			if err == nil {
+				resp.Body.Close()
+			}
+		}()
+		return nil
+	}
+}
+
+func TestWebAuthCodeExchange(t *testing.T) {
+	server := newAuthServer(t)
+	auth := webAuth{
+		Open: server.browser(func(state string) url.Values {
+			return url.Values{"code": {authCode}, "state": {state}}
+		}),
+		Out: io.Discard,
+	}
+	tok, err := getTokenFromWeb(context.Background(), server.config(), auth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.AccessToken != "access-token" || tok.RefreshToken != "refresh-token" {
+		t.Errorf("token = %+v", tok)
+	}
+}
+
+func TestWebAuthRejectsCallback(t *testing.T) {
+	tests := []struct {
+		name  string
+		query func(state string) url.Values
+		want  string
+	}{
+		{
+			name:  "state mismatch",
+			query: func(string) url.Values { return url.Values{"code": {authCode}, "state": {"forged"}} },
+			want:  "state does not match",
+		},
+		{
+			name:  "missing state",
+			query: func(string) url.Values { return url.Values{"code": {authCode}} },
+			want:  "state does not match",
+		},
+		{
+			name:  "consent denied",
+			query: func(state string) url.Values { return url.Values{"error": {"access_denied"}, "state": {state}} },
+			want:  "authorization failed: access_denied",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newAuthServer(t)
+			auth := webAuth{Open: server.browser(tt.query), Out: io.Discard}
+			_, err := getTokenFromWeb(context.Background(), server.config(), auth)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+			if server.exchanges != 0 {
+				t.Errorf("code exchanged %d times after a rejected callback", server.exchanges)
+			}
+		})
+	}
+}
+
+// pasteWriter plays the user of --no-browser: it reads the link printed to
+// Out and pastes the text returned by paste into In.
+type pasteWriter struct {
+	server *authServer
+	paste  func(redirect *url.URL, state string) string
+	in     *io.PipeWriter
+	once   sync.Once
+}
+
+func (p *pasteWriter) Write(b []byte) (int, error) {
+	for _, field := range strings.Fields(string(b)) {
+		if strings.HasPrefix(field, p.server.URL+"/auth?") {
+			p.once.Do(func() {
+				redirect, state := p.server.authorize(field)
+				go fmt.Fprintln(p.in, p.paste(redirect, state))
+			})
+		}
+	}
+	return len(b), nil
+}
+
+func TestWebAuthNoBrowser(t *testing.T) {
+	tests := []struct {
+		name    string
+		paste   func(redirect *url.URL, state string) string
+		wantErr string
+	}{
+		{
+			name: "redirect URL",
+			paste: func(redirect *url.URL, state string) string {
+				redirect.RawQuery = url.Values{"code": {authCode}, "state": {state}}.Encode()
+				return redirect.String()
+			},
+		},
+		{
+			name:  "bare code",
+			paste: func(*url.URL, string) string { return "  " + authCode + "  " },
+		},
+		{
+			name: "redirect URL with another state",
+			paste: func(redirect *url.URL, state string) string {
+				redirect.RawQuery = url.Values{"code": {authCode}, "state": {"forged"}}.Encode()
+				return redirect.String()
+			},
+			wantErr: "state does not match",
+		},
+		{
+			name:    "nothing pasted",
+			paste:   func(*url.URL, string) string { return "" },
+			wantErr: "no authorization code given",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newAuthServer(t)
+			in, pasted := io.Pipe()
+			defer pasted.Close()
+			auth := webAuth{
+				NoBrowser: true,
+				In:        in,
+				Out:       &pasteWriter{server: server, paste: tt.paste, in: pasted},
+			}
+			tok, err := getTokenFromWeb(context.Background(), server.config(), auth)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tok.AccessToken != "access-token" {
+				t.Errorf("token = %+v", tok)
+			}
+		})
+	}
+}
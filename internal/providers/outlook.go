@@ -0,0 +1,503 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	netmail "net/mail"
+	"os"
+	"strings"
+
+	"github.com/latocchi/gomailit/internal/mail"
+	"github.com/latocchi/gomailit/internal/mime"
+	"github.com/latocchi/gomailit/internal/tokenstore"
+	"github.com/latocchi/gomailit/internal/utils"
+	"github.com/spf13/pflag"
+	"golang.org/x/oauth2"
+)
+
+// Microsoft's identity platform and Graph endpoints.
+var (
+	microsoftLoginURL = "https://login.microsoftonline.com"
+	graphURL          = "https://graph.microsoft.com/v1.0"
+)
+
+// outlookScopes allow sending, and creating drafts for attachments too
+// large to send in one request.
+var outlookScopes = []string{
+	"offline_access",
+	"https://graph.microsoft.com/User.Read",
+	"https://graph.microsoft.com/Mail.Send",
+	"https://graph.microsoft.com/Mail.ReadWrite",
+}
+
+const (
+	// graphRequestLimit is the largest request Graph accepts. Attachments
+	// grow by a third in base64, so it holds about 3 MB of them.
+	graphRequestLimit = 4_000_000
+	// uploadChunkSize must be a multiple of 320 KiB.
+	uploadChunkSize = 10 * 320 << 10
+)
+
+func init() {
+	Register(Registration{
+		Name:        "outlook",
+		Aliases:     []string{"microsoft", "office365"},
+		Description: "Microsoft 365 and Outlook.com through Microsoft Graph",
+		Flags: func(flags *pflag.FlagSet) {
+			flags.String("client-id", "", "Application (client) ID of the app registration in Microsoft Entra ID")
+			flags.String("tenant", "common", "Directory (tenant) ID or domain, or common, organizations or consumers")
+			flags.String("token-store", "", "Where to keep the OAuth token: keyring, file or encrypted-file (default keyring)")
+			flags.Bool("no-browser", false, "Print the authorization link and read the code back instead of opening a browser")
+		},
+		Setup: func(ctx context.Context, flags *pflag.FlagSet) error {
+			config, err := LoadOutlookConfig()
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+			if config == nil {
+				config = &OutlookConfig{}
+			}
+			if flags.Changed("client-id") || config.ClientID == "" {
+				config.ClientID, _ = flags.GetString("client-id")
+			}
+			if flags.Changed("tenant") || config.Tenant == "" {
+				config.Tenant, _ = flags.GetString("tenant")
+			}
+			if config.ClientID == "" {
+				return errors.New("--client-id is required, register an app in Microsoft Entra ID first")
+			}
+
+			if value, _ := flags.GetString("token-store"); value != "" {
+				kind, err := tokenstore.ParseKind(value)
+				if err != nil {
+					return err
+				}
+				if err := outlookToken.switchStore(kind); err != nil {
+					return err
+				}
+			}
+			if err := SaveOutlookConfig(config); err != nil {
+				return err
+			}
+
+			noBrowser, _ := flags.GetBool("no-browser")
+			auth := defaultWebAuth(noBrowser)
+			auth.RedirectHost = "localhost"
+			if _, err := outlookToken.authorize(ctx, config.oauth2(), auth); err != nil {
+				return err
+			}
+
+			p, err := NewOutlookProvider()
+			if err != nil {
+				return err
+			}
+			account, err := p.Account(ctx)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Outlook provider set up for %s\n", account)
+			return nil
+		},
+		New: func(ctx context.Context) (Provider, error) {
+			return NewOutlookProvider()
+		},
+	})
+}
+
+// outlookToken is the OAuth token of the Outlook provider.
+var outlookToken = &oauthToken{provider: "outlook"}
+
+// OutlookConfig is the app registration gomailit signs in with. It is a
+// public client, so there is no secret; PKCE protects the code exchange.
+type OutlookConfig struct {
+	ClientID string `json:"client_id"`
+	Tenant   string `json:"tenant"`
+}
+
+func (c *OutlookConfig) oauth2() *oauth2.Config {
+	authority := microsoftLoginURL + "/" + c.Tenant + "/oauth2/v2.0"
+	return &oauth2.Config{
+		ClientID: c.ClientID,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:   authority + "/authorize",
+			TokenURL:  authority + "/token",
+			AuthStyle: oauth2.AuthStyleInParams,
+		},
+		Scopes: outlookScopes,
+	}
+}
+
+func SaveOutlookConfig(config *OutlookConfig) error {
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to encode outlook config: %v", err)
+	}
+	if err := os.WriteFile(utils.OutlookConfigPath(), data, 0600); err != nil {
+		return fmt.Errorf("unable to save outlook config: %v", err)
+	}
+	return nil
+}
+
+func LoadOutlookConfig() (*OutlookConfig, error) {
+	data, err := os.ReadFile(utils.OutlookConfigPath())
+	if err != nil {
+		return nil, err
+	}
+	config := &OutlookConfig{}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("unable to parse outlook config: %v", err)
+	}
+	return config, nil
+}
+
+// OutlookProvider sends email through Microsoft Graph. Like GoogleProvider
+// it holds one client that is safe for concurrent use.
+type OutlookProvider struct {
+	client *http.Client
+}
+
+// NewOutlookProvider builds the Graph client of the selected profile. It
+// never starts the authorization flow.
+func NewOutlookProvider() (*OutlookProvider, error) {
+	config, err := LoadOutlookConfig()
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("outlook is not set up, please run 'gomailit setup outlook' first")
+	} else if err != nil {
+		return nil, err
+	}
+	tok, err := outlookToken.load()
+	if errors.Is(err, tokenstore.ErrNotFound) {
+		return nil, fmt.Errorf("no token found, please run 'gomailit setup outlook' first to set up the Outlook provider")
+	} else if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	client := oauth2.NewClient(ctx, outlookToken.tokenSource(config.oauth2(), tok))
+	return &OutlookProvider{client: client}, nil
+}
+
+// Account returns the address of the signed in mailbox.
+func (p *OutlookProvider) Account(ctx context.Context) (string, error) {
+	var me struct {
+		Mail              string `json:"mail"`
+		UserPrincipalName string `json:"userPrincipalName"`
+	}
+	if err := p.call(ctx, http.MethodGet, "/me?$select=mail,userPrincipalName", nil, &me); err != nil {
+		return "", fmt.Errorf("unable to get user profile: %v", err)
+	}
+	if me.Mail != "" {
+		return me.Mail, nil
+	}
+	return me.UserPrincipalName, nil
+}
+
+// graphMessage is the message resource of the Graph API.
+type graphMessage struct {
+	Subject         string           `json:"subject"`
+	Body            graphBody        `json:"body"`
+	From            *graphRecipient  `json:"from,omitempty"`
+	ToRecipients    []graphRecipient `json:"toRecipients,omitempty"`
+	CcRecipients    []graphRecipient `json:"ccRecipients,omitempty"`
+	BccRecipients   []graphRecipient `json:"bccRecipients,omitempty"`
+	ReplyTo         []graphRecipient `json:"replyTo,omitempty"`
+	InternetMsgID   string           `json:"internetMessageId,omitempty"`
+	InternetHeaders []graphHeader    `json:"internetMessageHeaders,omitempty"`
+	Attachments     []graphFile      `json:"attachments,omitempty"`
+}
+
+type graphBody struct {
+	ContentType string `json:"contentType"`
+	Content     string `json:"content"`
+}
+
+type graphRecipient struct {
+	EmailAddress graphAddress `json:"emailAddress"`
+}
+
+type graphAddress struct {
+	Name    string `json:"name,omitempty"`
+	Address string `json:"address"`
+}
+
+type graphHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type graphFile struct {
+	Type         string `json:"@odata.type"`
+	Name         string `json:"name"`
+	ContentType  string `json:"contentType"`
+	ContentBytes []byte `json:"contentBytes"`
+	ContentID    string `json:"contentId,omitempty"`
+	IsInline     bool   `json:"isInline,omitempty"`
+}
+
+func newGraphMessage(msg *mail.Message) (*graphMessage, error) {
+	gm := &graphMessage{
+		Subject:       msg.Subject,
+		Body:          graphBody{ContentType: "text", Content: msg.TextBody},
+		InternetMsgID: msg.Headers["Message-ID"],
+	}
+	if gm.InternetMsgID == "" {
+		gm.InternetMsgID = mime.NewMessageID(msg.From)
+	}
+	// Graph takes one body; the HTML one carries the formatting.
+	if msg.HTMLBody != "" {
+		gm.Body = graphBody{ContentType: "html", Content: msg.HTMLBody}
+	}
+
+	var err error
+	if msg.From != "" {
+		from, err := graphRecipients([]string{msg.From})
+		if err != nil {
+			return nil, err
+		}
+		gm.From = &from[0]
+	}
+	if gm.ToRecipients, err = graphRecipients(msg.To); err != nil {
+		return nil, err
+	}
+	if gm.CcRecipients, err = graphRecipients(msg.Cc); err != nil {
+		return nil, err
+	}
+	if gm.BccRecipients, err = graphRecipients(msg.Bcc); err != nil {
+		return nil, err
+	}
+	if gm.ReplyTo, err = graphRecipients(msg.ReplyTo); err != nil {
+		return nil, err
+	}
+
+	// Graph only accepts custom headers starting with X-.
+	for name, value := range msg.Headers {
+		if strings.HasPrefix(strings.ToUpper(name), "X-") {
+			gm.InternetHeaders = append(gm.InternetHeaders, graphHeader{Name: name, Value: value})
+		}
+	}
+	return gm, nil
+}
+
+func graphRecipients(addresses []string) ([]graphRecipient, error) {
+	var recipients []graphRecipient
+	for _, address := range addresses {
+		parsed, err := netmail.ParseAddress(address)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address %q: %v", address, err)
+		}
+		recipients = append(recipients, graphRecipient{graphAddress{Name: parsed.Name, Address: parsed.Address}})
+	}
+	return recipients, nil
+}
+
+func newGraphFile(attachment mail.Attachment) graphFile {
+	contentType := attachment.ContentType
+	if contentType == "" {
+		contentType = mime.DetectContentType(attachment.Filename, attachment.Data)
+	}
+	return graphFile{
+		Type:         "#microsoft.graph.fileAttachment",
+		Name:         attachment.Filename,
+		ContentType:  contentType,
+		ContentBytes: attachment.Data,
+		ContentID:    attachment.ContentID,
+		IsInline:     attachment.ContentID != "",
+	}
+}
+
+// Send sends the message with sendMail. Messages too large for one request
+// are created as a draft first, so that large files can be uploaded in
+// chunks. Graph does not return an ID for sent
+// messages, so the Message-ID header is reported instead.
+func (p *OutlookProvider) Send(ctx context.Context, msg *mail.Message) (string, error) {
+	if err := msg.Validate(); err != nil {
+		return "", err
+	}
+	gm, err := newGraphMessage(msg)
+	if err != nil {
+		return "", err
+	}
+
+	for _, attachment := range msg.Attachments {
+		gm.Attachments = append(gm.Attachments, newGraphFile(attachment))
+	}
+	body, err := json.Marshal(map[string]any{"message": gm, "saveToSentItems": true})
+	if err != nil {
+		return "", err
+	}
+	if len(body) <= graphRequestLimit {
+		if err := p.do(ctx, http.MethodPost, "/me/sendMail", "application/json", bytes.NewReader(body), nil); err != nil {
+			return "", fmt.Errorf("unable to send email: %w", err)
+		}
+		return gm.InternetMsgID, nil
+	}
+
+	gm.Attachments = nil
+	if err := p.sendDraft(ctx, gm, msg.Attachments); err != nil {
+		return "", fmt.Errorf("unable to send email: %w", err)
+	}
+	return gm.InternetMsgID, nil
+}
+
+// sendDraft creates a draft, attaches the files one by one and sends it.
+// The draft is deleted again if any step fails.
+func (p *OutlookProvider) sendDraft(ctx context.Context, gm *graphMessage, attachments []mail.Attachment) (err error) {
+	var draft struct {
+		ID string `json:"id"`
+	}
+	if err := p.call(ctx, http.MethodPost, "/me/messages", gm, &draft); err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			// Best effort, the send already failed.
+			p.call(context.WithoutCancel(ctx), http.MethodDelete, "/me/messages/"+draft.ID, nil, nil)
+		}
+	}()
+
+	for _, attachment := range attachments {
+		file := newGraphFile(attachment)
+		var body []byte
+		if body, err = json.Marshal(file); err != nil {
+			return err
+		}
+		if len(body) <= graphRequestLimit {
+			err = p.do(ctx, http.MethodPost, "/me/messages/"+draft.ID+"/attachments", "application/json", bytes.NewReader(body), nil)
+		} else {
+			err = p.upload(ctx, draft.ID, file)
+		}
+		if err != nil {
+			return fmt.Errorf("unable to attach %s: %w", attachment.Filename, err)
+		}
+	}
+	return p.call(ctx, http.MethodPost, "/me/messages/"+draft.ID+"/send", nil, nil)
+}
+
+// upload attaches a large file to a draft through an upload session.
+func (p *OutlookProvider) upload(ctx context.Context, draftID string, file graphFile) error {
+	item := map[string]any{
+		"AttachmentItem": map[string]any{
+			"attachmentType": "file",
+			"name":           file.Name,
+			"size":           len(file.ContentBytes),
+			"contentType":    file.ContentType,
+			"contentId":      file.ContentID,
+			"isInline":       file.IsInline,
+		},
+	}
+	var session struct {
+		UploadURL string `json:"uploadUrl"`
+	}
+	if err := p.call(ctx, http.MethodPost, "/me/messages/"+draftID+"/attachments/createUploadSession", item, &session); err != nil {
+		return err
+	}
+
+	data := file.ContentBytes
+	for start := 0; start < len(data); start += uploadChunkSize {
+		end := min(start+uploadChunkSize, len(data))
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, session.UploadURL, bytes.NewReader(data[start:end]))
+		if err != nil {
+			return err
+		}
+		req.ContentLength = int64(end - start)
+		req.Header.Set("Content-Type", "application/octet-stream")
+		req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end-1, len(data)))
+
+		// The upload URL carries its own authorization and rejects a
+		// bearer token.
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return graphError(resp, body)
+		}
+	}
+	return nil
+}
+
+// SendRaw submits a pre-built MIME message. Like Gmail, Graph delivers to
+// the addresses in the To, Cc and Bcc headers only, so envelope recipients
+// that are not among them are added as Bcc.
+func (p *OutlookProvider) SendRaw(ctx context.Context, env mime.Envelope, raw []byte) (string, error) {
+	raw, err := withEnvelopeBcc("Microsoft Graph", env, raw)
+	if err != nil {
+		return "", err
+	}
+
+	body := base64.StdEncoding.EncodeToString(raw)
+	if err := p.do(ctx, http.MethodPost, "/me/sendMail", "text/plain", strings.NewReader(body), nil); err != nil {
+		return "", fmt.Errorf("unable to send email: %w", err)
+	}
+	return mime.HeaderValue(raw, "Message-ID"), nil
+}
+
+// call sends a JSON request to Graph and decodes the JSON response into
+// out, if given.
+func (p *OutlookProvider) call(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	return p.do(ctx, method, path, "application/json", body, out)
+}
+
+func (p *OutlookProvider) do(ctx context.Context, method, path, contentType string, body io.Reader, out any) error {
+	req, err := http.NewRequestWithContext(ctx, method, graphURL+path, body)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return graphError(resp, data)
+	}
+	if out != nil && len(data) > 0 {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("unable to parse Graph response: %v", err)
+		}
+	}
+	return nil
+}
+
+// graphError turns a Graph error response into an APIError.
+func graphError(resp *http.Response, body []byte) error {
+	apiErr := newAPIError("Microsoft Graph", resp, body)
+	var payload struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &payload) == nil && payload.Error.Code != "" {
+		apiErr.Code = payload.Error.Code
+		apiErr.Message = payload.Error.Message
+	}
+	return apiErr
+}
@@ -0,0 +1,452 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package providers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/latocchi/gomailit/internal/mail"
+	"golang.org/x/oauth2"
+)
+
+// graphServer is a fake Microsoft Graph and identity platform for the
+// mailbox alice@contoso.com. Like Graph it rejects requests over 4 MB.
+type graphServer struct {
+	*httptest.Server
+	t *testing.T
+
+	// fail makes a request, such as "POST /v1.0/me/messages/D1/send",
+	// fail with the given status.
+	fail map[string]int
+
+	mu        sync.Mutex
+	requests  []apiRequest
+	uploaded  []byte
+	challenge string
+	redirect  string
+}
+
+func newGraphServer(t *testing.T) *graphServer {
+	s := &graphServer{t: t, fail: map[string]int{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /contoso.onmicrosoft.com/oauth2/v2.0/token", s.token)
+	mux.HandleFunc("GET /v1.0/me", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"mail":"alice@contoso.com","userPrincipalName":"alice@contoso.onmicrosoft.com"}`)
+	})
+	mux.HandleFunc("POST /v1.0/me/sendMail", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	})
+	mux.HandleFunc("POST /v1.0/me/messages", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"id":"D1"}`)
+	})
+	mux.HandleFunc("POST /v1.0/me/messages/D1/attachments", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"id":"A1"}`)
+	})
+	mux.HandleFunc("POST /v1.0/me/messages/D1/attachments/createUploadSession", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"uploadUrl":%q}`, s.URL+"/upload/D1?token=upload-token")
+	})
+	mux.HandleFunc("PUT /upload/D1", s.upload)
+	mux.HandleFunc("POST /v1.0/me/messages/D1/send", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	})
+	mux.HandleFunc("DELETE /v1.0/me/messages/D1", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		s.mu.Lock()
+		s.requests = append(s.requests, apiRequest{r.Method, r.URL.Path, r.Header.Clone(), body})
+		s.mu.Unlock()
+
+		if len(body) > graphRequestLimit {
+			graphErrorResponse(w, http.StatusRequestEntityTooLarge, "RequestBodyTooLarge", "The request is too large")
+			return
+		}
+		if strings.HasPrefix(r.URL.Path, "/v1.0/") && r.Header.Get("Authorization") != "Bearer access-token" {
+			graphErrorResponse(w, http.StatusUnauthorized, "InvalidAuthenticationToken", "Access token is empty.")
+			return
+		}
+		if status := s.fail[r.Method+" "+r.URL.Path]; status != 0 {
+			graphErrorResponse(w, status, "ErrorInvalidRequest", "request failed")
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(s.Close)
+	useURL(t, &graphURL, s.URL+"/v1.0")
+	useURL(t, &microsoftLoginURL, s.URL)
+	return s
+}
+
+func graphErrorResponse(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{"error": map[string]string{"code": code, "message": message}})
+}
+
+// token exchanges the code of the public client, which has no secret, for
+// the verifier of the challenge the authorization link carried.
+func (s *graphServer) token(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	switch {
+	case r.PostForm.Get("grant_type") != "authorization_code",
+		r.PostForm.Get("client_id") != "client-id",
+		r.PostForm.Has("client_secret"),
+		r.PostForm.Get("code") != authCode,
+		r.PostForm.Get("redirect_uri") != s.redirect,
+		base64.RawURLEncoding.EncodeToString(sum[:]) != s.challenge:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error":"invalid_grant"}`)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprint(w, `{"access_token":"access-token","refresh_token":"refresh-token","token_type":"Bearer","expires_in":3600}`)
+}
+
+// upload takes a chunk of an upload session, which must come in order and
+// without the bearer token.
+func (s *graphServer) upload(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var start, end, total int
+	if _, err := fmt.Sscanf(r.Header.Get("Content-Range"), "bytes %d-%d/%d", &start, &end, &total); err != nil ||
+		start != len(s.uploaded) || end-start+1 != len(body) || r.Header.Get("Authorization") != "" {
+		graphErrorResponse(w, http.StatusBadRequest, "InvalidRange", "bad chunk "+r.Header.Get("Content-Range"))
+		return
+	}
+	s.uploaded = append(s.uploaded, body...)
+	if len(s.uploaded) == total {
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+	fmt.Fprintf(w, `{"nextExpectedRanges":["%d-"]}`, len(s.uploaded))
+}
+
+// calls returns the method and path of every request, in order.
+func (s *graphServer) calls() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var calls []string
+	for _, req := range s.requests {
+		calls = append(calls, req.method+" "+req.path)
+	}
+	return calls
+}
+
+func (s *graphServer) find(call string) apiRequest {
+	s.t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, req := range s.requests {
+		if req.method+" "+req.path == call {
+			return req
+		}
+	}
+	s.t.Fatalf("no %s request", call)
+	return apiRequest{}
+}
+
+func outlookTestProvider() *OutlookProvider {
+	return &OutlookProvider{client: oauth2.NewClient(context.Background(), oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "access-token"}))}
+}
+
+func TestOutlookSend(t *testing.T) {
+	server := newGraphServer(t)
+	msg := apiTestMessage()
+	msg.Headers["Message-ID"] = "<m1@example.com>"
+
+	id, err := outlookTestProvider().Send(context.Background(), msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "<m1@example.com>" {
+		t.Errorf("id = %q", id)
+	}
+	if calls := strings.Join(server.calls(), ", "); calls != "POST /v1.0/me/sendMail" {
+		t.Fatalf("requests = %s", calls)
+	}
+
+	var body struct {
+		Message         graphMessage `json:"message"`
+		SaveToSentItems bool         `json:"saveToSentItems"`
+	}
+	if err := json.Unmarshal(server.find("POST /v1.0/me/sendMail").body, &body); err != nil {
+		t.Fatal(err)
+	}
+	gm := body.Message
+	if !body.SaveToSentItems || gm.Subject != "Report" || gm.InternetMsgID != "<m1@example.com>" {
+		t.Errorf("message = %+v", body)
+	}
+	if gm.Body != (graphBody{ContentType: "html", Content: "<p>See attached</p>"}) {
+		t.Errorf("body = %+v", gm.Body)
+	}
+	if gm.From == nil || gm.From.EmailAddress != (graphAddress{Name: "Alice", Address: "alice@example.com"}) ||
+		len(gm.ToRecipients) != 1 || gm.ToRecipients[0].EmailAddress != (graphAddress{Name: "Bob", Address: "bob@example.com"}) ||
+		len(gm.CcRecipients) != 1 || gm.CcRecipients[0].EmailAddress.Address != "carol@example.com" ||
+		len(gm.BccRecipients) != 1 || gm.BccRecipients[0].EmailAddress.Address != "audit@example.com" ||
+		len(gm.ReplyTo) != 1 || gm.ReplyTo[0].EmailAddress.Address != "support@example.com" {
+		t.Errorf("addresses = %+v", gm)
+	}
+	if len(gm.InternetHeaders) != 1 || gm.InternetHeaders[0] != (graphHeader{"X-Campaign", "june"}) {
+		t.Errorf("headers = %+v", gm.InternetHeaders)
+	}
+	if len(gm.Attachments) != 1 || gm.Attachments[0].Name != "report.pdf" ||
+		string(gm.Attachments[0].ContentBytes) != "%PDF-1.4 report" || gm.Attachments[0].Type != "#microsoft.graph.fileAttachment" {
+		t.Errorf("attachments = %+v", gm.Attachments)
+	}
+}
+
+// attachmentOf returns n bytes of data that do not compress or repeat in
+// whole chunks.
+func attachmentOf(name string, n int) mail.Attachment {
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(i * 7 / 3)
+	}
+	return mail.Attachment{Filename: name, ContentType: "application/octet-stream", Data: data}
+}
+
+func TestOutlookSendSizes(t *testing.T) {
+	tests := []struct {
+		name        string
+		attachments []mail.Attachment
+		want        []string
+	}{
+		{
+			name:        "fits in one request",
+			attachments: []mail.Attachment{attachmentOf("a.bin", 2_900_000)},
+			want:        []string{"POST /v1.0/me/sendMail"},
+		},
+		{
+			// 3 MiB is 4 MiB in base64, over the limit.
+			name:        "3 MiB attachment",
+			attachments: []mail.Attachment{attachmentOf("a.bin", 3<<20)},
+			want: []string{
+				"POST /v1.0/me/messages",
+				"POST /v1.0/me/messages/D1/attachments/createUploadSession",
+				"PUT /upload/D1",
+				"POST /v1.0/me/messages/D1/send",
+			},
+		},
+		{
+			name:        "attachments too large together",
+			attachments: []mail.Attachment{attachmentOf("a.bin", 2_000_000), attachmentOf("b.bin", 2_000_000)},
+			want: []string{
+				"POST /v1.0/me/messages",
+				"POST /v1.0/me/messages/D1/attachments",
+				"POST /v1.0/me/messages/D1/attachments",
+				"POST /v1.0/me/messages/D1/send",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newGraphServer(t)
+			msg := &mail.Message{To: []string{"bob@example.com"}, Subject: "Files", TextBody: "Attached.", Attachments: tt.attachments}
+			if _, err := outlookTestProvider().Send(context.Background(), msg); err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Join(server.calls(), ", "); got != strings.Join(tt.want, ", ") {
+				t.Errorf("requests = %s\nwant %s", got, strings.Join(tt.want, ", "))
+			}
+		})
+	}
+}
+
+func TestOutlookUploadSession(t *testing.T) {
+	server := newGraphServer(t)
+	small := attachmentOf("notes.txt", 1000)
+	large := attachmentOf("video.bin", 2*uploadChunkSize+12345)
+	msg := &mail.Message{To: []string{"bob@example.com"}, Subject: "Video", TextBody: "Attached.", Attachments: []mail.Attachment{small, large}}
+
+	if _, err := outlookTestProvider().Send(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"POST /v1.0/me/messages",
+		"POST /v1.0/me/messages/D1/attachments",
+		"POST /v1.0/me/messages/D1/attachments/createUploadSession",
+		"PUT /upload/D1",
+		"PUT /upload/D1",
+		"PUT /upload/D1",
+		"POST /v1.0/me/messages/D1/send",
+	}
+	if got := strings.Join(server.calls(), ", "); got != strings.Join(want, ", ") {
+		t.Fatalf("requests = %s\nwant %s", got, strings.Join(want, ", "))
+	}
+
+	// The draft goes without the attachments.
+	var draft graphMessage
+	if err := json.Unmarshal(server.find("POST /v1.0/me/messages").body, &draft); err != nil {
+		t.Fatal(err)
+	}
+	if draft.Subject != "Video" || len(draft.Attachments) != 0 {
+		t.Errorf("draft = %+v", draft)
+	}
+
+	var file graphFile
+	if err := json.Unmarshal(server.find("POST /v1.0/me/messages/D1/attachments").body, &file); err != nil {
+		t.Fatal(err)
+	}
+	if file.Name != "notes.txt" || !bytes.Equal(file.ContentBytes, small.Data) {
+		t.Errorf("small attachment = %s, %d bytes", file.Name, len(file.ContentBytes))
+	}
+
+	var session struct {
+		AttachmentItem struct {
+			AttachmentType string `json:"attachmentType"`
+			Name           string `json:"name"`
+			Size           int    `json:"size"`
+		}
+	}
+	if err := json.Unmarshal(server.find("POST /v1.0/me/messages/D1/attachments/createUploadSession").body, &session); err != nil {
+		t.Fatal(err)
+	}
+	if item := session.AttachmentItem; item.AttachmentType != "file" || item.Name != "video.bin" || item.Size != len(large.Data) {
+		t.Errorf("upload session = %+v", item)
+	}
+	if !bytes.Equal(server.uploaded, large.Data) {
+		t.Errorf("uploaded %d bytes, want the %d bytes of video.bin", len(server.uploaded), len(large.Data))
+	}
+}
+
+func TestOutlookDraftDeletedOnFailure(t *testing.T) {
+	tests := []struct {
+		name    string
+		fail    string
+		deleted bool
+	}{
+		{"draft not created", "POST /v1.0/me/messages", false},
+		{"attachment rejected", "POST /v1.0/me/messages/D1/attachments", true},
+		{"upload session refused", "POST /v1.0/me/messages/D1/attachments/createUploadSession", true},
+		{"chunk rejected", "PUT /upload/D1", true},
+		{"send rejected", "POST /v1.0/me/messages/D1/send", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newGraphServer(t)
+			server.fail[tt.fail] = http.StatusBadRequest
+			msg := &mail.Message{
+				To:          []string{"bob@example.com"},
+				Subject:     "Files",
+				TextBody:    "Attached.",
+				Attachments: []mail.Attachment{attachmentOf("notes.txt", 1000), attachmentOf("video.bin", 4_000_000)},
+			}
+
+			_, err := outlookTestProvider().Send(context.Background(), msg)
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+				t.Fatalf("error = %v, want the Graph error", err)
+			}
+			calls := server.calls()
+			if deleted := calls[len(calls)-1] == "DELETE /v1.0/me/messages/D1"; deleted != tt.deleted {
+				t.Errorf("draft deleted = %v, want %v (requests %s)", deleted, tt.deleted, strings.Join(calls, ", "))
+			}
+		})
+	}
+}
+
+func TestOutlookErrors(t *testing.T) {
+	server := newGraphServer(t)
+	server.fail["POST /v1.0/me/sendMail"] = http.StatusServiceUnavailable
+
+	_, err := outlookTestProvider().Send(context.Background(), apiTestMessage())
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != "ErrorInvalidRequest" || apiErr.Message != "request failed" {
+		t.Fatalf("error = %v", err)
+	}
+	if !IsTransient(err) {
+		t.Errorf("IsTransient(%v) = false", err)
+	}
+}
+
+// TestOutlookSetupExchange runs the authorization 'gomailit setup outlook'
+// does: a PKCE code flow for a public client redirected to localhost, after
+// which the provider sends with the stored token.
+func TestOutlookSetupExchange(t *testing.T) {
+	useTempConfigDir(t)
+	server := newGraphServer(t)
+
+	config := &OutlookConfig{ClientID: "client-id", Tenant: "contoso.onmicrosoft.com"}
+	if err := SaveOutlookConfig(config); err != nil {
+		t.Fatal(err)
+	}
+
+	auth := webAuth{
+		RedirectHost: "localhost",
+		Out:          io.Discard,
+		Open: func(link string) error {
+			u, err := url.Parse(link)
+			if err != nil {
+				return err
+			}
+			query := u.Query()
+			if u.Path != "/contoso.onmicrosoft.com/oauth2/v2.0/authorize" || query.Get("client_id") != "client-id" ||
+				query.Get("code_challenge_method") != "S256" || !strings.Contains(query.Get("scope"), "offline_access") ||
+				!strings.Contains(query.Get("scope"), "Mail.Send") {
+				t.Errorf("authorization link = %s", link)
+			}
+			redirect, err := url.Parse(query.Get("redirect_uri"))
+			if err != nil || redirect.Hostname() != "localhost" {
+				t.Errorf("redirect_uri = %q", query.Get("redirect_uri"))
+			}
+			server.mu.Lock()
+			server.challenge = query.Get("code_challenge")
+			server.redirect = query.Get("redirect_uri")
+			server.mu.Unlock()
+
+			// The browser comes back to the local server with the code.
+			redirect.Host = "127.0.0.1:" + redirect.Port()
+			redirect.RawQuery = url.Values{"code": {authCode}, "state": {query.Get("state")}}.Encode()
+			go func() {
+				if resp, err := http.Get(redirect.String()); err == nil {
+					resp.Body.Close()
+				}
+			}()
+			return nil
+		},
+	}
+	tok, err := outlookToken.authorize(context.Background(), config.oauth2(), auth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.AccessToken != "access-token" || tok.RefreshToken != "refresh-token" {
+		t.Errorf("token = %+v", tok)
+	}
+
+	p, err := NewOutlookProvider()
+	if err != nil {
+		t.Fatal(err)
+	}
+	account, err := p.Account(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account != "alice@contoso.com" {
+		t.Errorf("account = %q", account)
+	}
+}
@@ -0,0 +1,216 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/latocchi/gomailit/internal/mail"
+	"github.com/latocchi/gomailit/internal/mime"
+	"github.com/spf13/pflag"
+)
+
+// postmarkURL is the Postmark API endpoint.
+var postmarkURL = "https://api.postmarkapp.com"
+
+func init() {
+	Register(Registration{
+		Name:        "postmark",
+		Description: "Postmark (email API)",
+		Flags: func(flags *pflag.FlagSet) {
+			flags.String("server-token", "", "Postmark server API token, prompted for when omitted")
+			flags.String("message-stream", "outbound", "Message stream to send through")
+			flags.String("from", "", "Sender address, must be a confirmed sender signature or domain")
+			tokenStoreFlag(flags, "server token")
+		},
+		Setup: func(ctx context.Context, flags *pflag.FlagSet) error {
+			config := &PostmarkConfig{}
+			config.ServerToken, _ = flags.GetString("server-token")
+			config.MessageStream, _ = flags.GetString("message-stream")
+			config.From, _ = flags.GetString("from")
+
+			if config.ServerToken == "" {
+				config.ServerToken = promptSecret("Postmark server token: ")
+			}
+			if config.ServerToken == "" {
+				return errors.New("a server token is required")
+			}
+			if err := useTokenStoreFlag(flags, postmarkToken); err != nil {
+				return err
+			}
+			if err := saveAPIConfig(postmarkToken, config); err != nil {
+				return err
+			}
+			fmt.Printf("Postmark provider configured for the %s stream\n", config.MessageStream)
+			return nil
+		},
+		New: func(ctx context.Context) (Provider, error) {
+			config := &PostmarkConfig{}
+			if err := loadAPIConfig(postmarkToken, config); err != nil {
+				return nil, err
+			}
+			return &PostmarkProvider{config: config}, nil
+		},
+	})
+}
+
+// PostmarkConfig holds the settings saved by 'gomailit setup postmark'.
+type PostmarkConfig struct {
+	// ServerToken is kept in the token store. It is only read from
+	// postmark.json files written by older versions.
+	ServerToken   string `json:"server_token,omitempty"`
+	MessageStream string `json:"message_stream,omitempty"`
+	From          string `json:"from,omitempty"`
+}
+
+func (c *PostmarkConfig) secret() *string {
+	return &c.ServerToken
+}
+
+// PostmarkProvider sends email with Postmark's email API, which takes the
+// message as JSON.
+type PostmarkProvider struct {
+	config *PostmarkConfig
+}
+
+type postmarkHeader struct {
+	Name  string
+	Value string
+}
+
+type postmarkAttachment struct {
+	Name        string
+	Content     []byte
+	ContentType string
+	ContentID   string `json:",omitempty"`
+}
+
+type postmarkMessage struct {
+	From          string
+	To            string
+	Cc            string `json:",omitempty"`
+	Bcc           string `json:",omitempty"`
+	ReplyTo       string `json:",omitempty"`
+	Subject       string
+	TextBody      string               `json:",omitempty"`
+	HtmlBody      string               `json:",omitempty"`
+	Headers       []postmarkHeader     `json:",omitempty"`
+	Attachments   []postmarkAttachment `json:",omitempty"`
+	MessageStream string               `json:",omitempty"`
+}
+
+// Send returns the MessageID Postmark assigns to the message.
+func (p *PostmarkProvider) Send(ctx context.Context, msg *mail.Message) (string, error) {
+	if err := msg.Validate(); err != nil {
+		return "", err
+	}
+	msg, err := withSender(msg, p.config.From, "postmark")
+	if err != nil {
+		return "", err
+	}
+	request, err := newPostmarkMessage(msg)
+	if err != nil {
+		return "", err
+	}
+	request.MessageStream = p.config.MessageStream
+	body, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, postmarkURL+"/email", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Postmark-Server-Token", p.config.ServerToken)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	_, data, err := doAPI(req, postmarkError)
+	if err != nil {
+		return "", fmt.Errorf("unable to send email: %w", err)
+	}
+	var response struct {
+		MessageID string
+	}
+	if err := json.Unmarshal(data, &response); err != nil {
+		return "", fmt.Errorf("unable to parse Postmark response: %v", err)
+	}
+	return response.MessageID, nil
+}
+
+func newPostmarkMessage(msg *mail.Message) (*postmarkMessage, error) {
+	request := &postmarkMessage{
+		Subject:  msg.Subject,
+		TextBody: msg.TextBody,
+		HtmlBody: msg.HTMLBody,
+	}
+
+	// Postmark takes address lists as comma-separated strings.
+	fields := []struct {
+		value     *string
+		addresses []string
+	}{
+		{&request.From, []string{msg.From}},
+		{&request.To, msg.To},
+		{&request.Cc, msg.Cc},
+		{&request.Bcc, msg.Bcc},
+		{&request.ReplyTo, msg.ReplyTo},
+	}
+	for _, field := range fields {
+		if len(field.addresses) == 0 {
+			continue
+		}
+		value, err := mime.FormatAddressList(field.addresses)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address: %v", err)
+		}
+		*field.value = value
+	}
+
+	for name, value := range msg.Headers {
+		request.Headers = append(request.Headers, postmarkHeader{name, value})
+	}
+	for _, attachment := range msg.Attachments {
+		contentType := attachment.ContentType
+		if contentType == "" {
+			contentType = mime.DetectContentType(attachment.Filename, attachment.Data)
+		}
+		// Inline images are referenced by a "cid:" Content-ID.
+		contentID := ""
+		if attachment.ContentID != "" {
+			contentID = "cid:" + attachment.ContentID
+		}
+		request.Attachments = append(request.Attachments, postmarkAttachment{
+			Name:        attachment.Filename,
+			Content:     attachment.Data,
+			ContentType: contentType,
+			ContentID:   contentID,
+		})
+	}
+	return request, nil
+}
+
+// postmarkError turns a Postmark error response, which carries a numeric
+// error code, into an APIError.
+func postmarkError(resp *http.Response, body []byte) error {
+	apiErr := newAPIError("Postmark", resp, body)
+	var payload struct {
+		ErrorCode int
+		Message   string
+	}
+	if json.Unmarshal(body, &payload) == nil && payload.Message != "" {
+		apiErr.Code = strconv.Itoa(payload.ErrorCode)
+		apiErr.Message = payload.Message
+		// Error code 405 means that the account has run out of credits.
+		apiErr.QuotaExceeded = payload.ErrorCode == 405
+	}
+	return apiErr
+}
@@ -0,0 +1,89 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func postmarkTestProvider() *PostmarkProvider {
+	return &PostmarkProvider{config: &PostmarkConfig{ServerToken: "server-token", MessageStream: "outbound"}}
+}
+
+func TestPostmarkSend(t *testing.T) {
+	server := newAPIServer(t, apiResponse{status: http.StatusOK, body: `{"ErrorCode":0,"Message":"OK","MessageID":"pm-id"}`})
+	useURL(t, &postmarkURL, server.URL)
+
+	id, err := postmarkTestProvider().Send(context.Background(), apiTestMessage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "pm-id" {
+		t.Errorf("id = %q", id)
+	}
+
+	req := server.request(t)
+	if req.method != http.MethodPost || req.path != "/email" {
+		t.Errorf("request = %s %s", req.method, req.path)
+	}
+	if token := req.header.Get("X-Postmark-Server-Token"); token != "server-token" {
+		t.Errorf("X-Postmark-Server-Token = %q", token)
+	}
+	var body postmarkMessage
+	if err := json.Unmarshal(req.body, &body); err != nil {
+		t.Fatal(err)
+	}
+	want := postmarkMessage{
+		From:          `"Alice" <alice@example.com>`,
+		To:            `"Bob" <bob@example.com>`,
+		Cc:            "carol@example.com",
+		Bcc:           "audit@example.com",
+		ReplyTo:       "support@example.com",
+		Subject:       "Report",
+		TextBody:      "See attached",
+		HtmlBody:      "<p>See attached</p>",
+		Headers:       []postmarkHeader{{"X-Campaign", "june"}},
+		Attachments:   []postmarkAttachment{{Name: "report.pdf", Content: []byte("%PDF-1.4 report"), ContentType: "application/pdf"}},
+		MessageStream: "outbound",
+	}
+	if !reflect.DeepEqual(body, want) {
+		t.Errorf("request body = %+v\nwant %+v", body, want)
+	}
+}
+
+func TestPostmarkErrors(t *testing.T) {
+	checkAPIError(t, []errorCase{
+		{
+			name:      "rate limited",
+			response:  apiResponse{status: http.StatusTooManyRequests, body: `{"ErrorCode":429,"Message":"Rate limit exceeded"}`},
+			message:   "Rate limit exceeded",
+			transient: true,
+		},
+		{
+			name:     "out of credits",
+			response: apiResponse{status: http.StatusUnprocessableEntity, body: `{"ErrorCode":405,"Message":"Not allowed to send: you have run out of credits."}`},
+			message:  "Not allowed to send: you have run out of credits.",
+			quota:    true,
+		},
+		{
+			name:     "unconfirmed sender",
+			response: apiResponse{status: http.StatusUnprocessableEntity, body: `{"ErrorCode":400,"Message":"The 'From' address you supplied is not a Sender Signature."}`},
+			message:  "The 'From' address you supplied is not a Sender Signature.",
+		},
+		{
+			name:      "server error",
+			response:  apiResponse{status: http.StatusInternalServerError, body: "internal error"},
+			message:   "internal error",
+			transient: true,
+		},
+	}, func(t *testing.T, url string) error {
+		useURL(t, &postmarkURL, url)
+		_, err := postmarkTestProvider().Send(context.Background(), apiTestMessage())
+		return err
+	})
+}
@@ -3,6 +3,185 @@ Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
 */
 package providers
 
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/latocchi/gomailit/internal/config"
+	"github.com/latocchi/gomailit/internal/mail"
+	"github.com/latocchi/gomailit/internal/mime"
+	"github.com/latocchi/gomailit/internal/utils"
+	"github.com/spf13/pflag"
+)
+
+// Provider delivers a message and returns the ID the backend assigned to it.
+// Backends that do not assign IDs return the Message-ID header instead, or
+// an empty string.
 type Provider interface {
-	SendEmail() error
+	Send(ctx context.Context, msg *mail.Message) (string, error)
+}
+
+// RawSender is implemented by providers that can submit a complete,
+// pre-built RFC 5322 message as is.
+type RawSender interface {
+	SendRaw(ctx context.Context, env mime.Envelope, raw []byte) (string, error)
+}
+
+// SenderValidator is implemented by providers that restrict which From
+// addresses may be used, such as Gmail's send-as aliases.
+type SenderValidator interface {
+	ValidateFrom(ctx context.Context, from string) error
+}
+
+// AccountReporter is implemented by providers that sign in to a mailbox,
+// such as Gmail, Outlook or a JMAP account. Account returns the address
+// messages without a From address are sent as.
+type AccountReporter interface {
+	Account(ctx context.Context) (string, error)
+}
+
+// ThreadReporter is implemented by providers whose mailboxes group messages
+// into threads, such as Gmail. ThreadID returns the thread of a message the
+// provider sent, given the ID Send returned, or "" when it is unknown.
+type ThreadReporter interface {
+	ThreadID(id string) string
+}
+
+// Registration describes a provider backend. Backends register themselves
+// from an init function so that 'gomailit setup' and 'gomailit send' can
+// resolve them by name.
+type Registration struct {
+	Name        string
+	Aliases     []string
+	Description string
+
+	// Flags registers the provider-specific flags of 'gomailit setup <name>'.
+	// It may be nil.
+	Flags func(flags *pflag.FlagSet)
+	// Setup configures the provider from the parsed setup flags and saves
+	// its settings.
+	Setup func(ctx context.Context, flags *pflag.FlagSet) error
+	// New loads the saved settings and returns a ready to use provider.
+	New func(ctx context.Context) (Provider, error)
+}
+
+var registry = map[string]*Registration{}
+
+// Register makes a provider available by its name and aliases. It panics
+// if a name is registered twice.
+func Register(r Registration) {
+	for _, name := range append([]string{r.Name}, r.Aliases...) {
+		if _, ok := registry[name]; ok {
+			panic(fmt.Sprintf("provider %s registered twice", name))
+		}
+		registry[name] = &r
+	}
+}
+
+// Lookup returns the registration for a provider name or alias.
+func Lookup(name string) (*Registration, error) {
+	r, ok := registry[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported provider: %s", name)
+	}
+	return r, nil
+}
+
+// Registrations returns every registered provider, sorted by name.
+func Registrations() []*Registration {
+	var all []*Registration
+	for name, r := range registry {
+		if name == r.Name {
+			all = append(all, r)
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Name < all[j].Name })
+	return all
+}
+
+// Setup runs the setup of the named provider and makes it the active one.
+func Setup(ctx context.Context, name string, flags *pflag.FlagSet) error {
+	r, err := Lookup(name)
+	if err != nil {
+		return err
+	}
+	if err := r.Setup(ctx, flags); err != nil {
+		return err
+	}
+	return SaveActiveProvider(r.Name)
+}
+
+var (
+	instancesMu sync.Mutex
+	// instances holds the providers created by Get, keyed by profile and
+	// provider name.
+	instances = map[string]Provider{}
+)
+
+// Get returns a ready to use provider by name or alias. A provider is
+// created once per profile and then shared, so providers must be safe for
+// concurrent use.
+func Get(ctx context.Context, name string) (Provider, error) {
+	r, err := Lookup(name)
+	if err != nil {
+		return nil, err
+	}
+
+	instancesMu.Lock()
+	defer instancesMu.Unlock()
+	key := utils.Profile() + "/" + r.Name
+	if p, ok := instances[key]; ok {
+		return p, nil
+	}
+	p, err := r.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	instances[key] = p
+	return p, nil
+}
+
+// SaveActiveProvider records which provider the selected profile sends
+// through.
+func SaveActiveProvider(name string) error {
+	cfg, err := config.LoadFile()
+	if err != nil {
+		return err
+	}
+	profile, ok := cfg.GetProfile(utils.Profile())
+	if !ok {
+		profile = &config.Profile{}
+	}
+	profile.Provider = name
+	cfg.SetProfile(utils.Profile(), profile)
+	if err := cfg.Save(); err != nil {
+		return fmt.Errorf("unable to save active provider: %v", err)
+	}
+	return nil
+}
+
+// ActiveProvider returns the name of the provider of the selected profile.
+// Installs that predate the config file recorded it in a file of its own,
+// and before that only ever supported Google, so that is the default when
+// nothing has been recorded.
+func ActiveProvider() string {
+	if cfg, err := config.Load(); err == nil {
+		if profile, ok := cfg.GetProfile(utils.Profile()); ok && profile.Provider != "" {
+			return profile.Provider
+		}
+	}
+
+	data, err := os.ReadFile(utils.ProviderPath())
+	if err != nil {
+		return "google"
+	}
+	name := strings.TrimSpace(string(data))
+	if name == "" {
+		return "google"
+	}
+	return name
 }
@@ -0,0 +1,241 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	netmail "net/mail"
+	"strings"
+
+	"github.com/latocchi/gomailit/internal/mail"
+	"github.com/latocchi/gomailit/internal/mime"
+	"github.com/spf13/pflag"
+)
+
+// sendgridURL is the SendGrid v3 API endpoint.
+var sendgridURL = "https://api.sendgrid.com"
+
+func init() {
+	Register(Registration{
+		Name:        "sendgrid",
+		Description: "Twilio SendGrid (v3 Mail Send API)",
+		Flags: func(flags *pflag.FlagSet) {
+			flags.String("api-key", "", "SendGrid API key with the Mail Send permission, prompted for when omitted")
+			flags.String("from", "", "Sender address, must be a verified sender or domain")
+			tokenStoreFlag(flags, "API key")
+		},
+		Setup: func(ctx context.Context, flags *pflag.FlagSet) error {
+			config := &SendGridConfig{}
+			config.APIKey, _ = flags.GetString("api-key")
+			config.From, _ = flags.GetString("from")
+
+			if config.APIKey == "" {
+				config.APIKey = promptSecret("SendGrid API key: ")
+			}
+			if config.APIKey == "" {
+				return errors.New("an API key is required")
+			}
+			if err := useTokenStoreFlag(flags, sendgridKey); err != nil {
+				return err
+			}
+			if err := saveAPIConfig(sendgridKey, config); err != nil {
+				return err
+			}
+			fmt.Println("SendGrid provider configured")
+			return nil
+		},
+		New: func(ctx context.Context) (Provider, error) {
+			config := &SendGridConfig{}
+			if err := loadAPIConfig(sendgridKey, config); err != nil {
+				return nil, err
+			}
+			return &SendGridProvider{config: config}, nil
+		},
+	})
+}
+
+// SendGridConfig holds the settings saved by 'gomailit setup sendgrid'.
+type SendGridConfig struct {
+	// APIKey is kept in the token store. It is only read from
+	// sendgrid.json files written by older versions.
+	APIKey string `json:"api_key,omitempty"`
+	From   string `json:"from,omitempty"`
+}
+
+func (c *SendGridConfig) secret() *string {
+	return &c.APIKey
+}
+
+// SendGridProvider sends email with the SendGrid v3 Mail Send API, which
+// takes the message as JSON rather than MIME.
+type SendGridProvider struct {
+	config *SendGridConfig
+}
+
+type sendgridAddress struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+type sendgridContent struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type sendgridAttachment struct {
+	Content     []byte `json:"content"`
+	Type        string `json:"type"`
+	Filename    string `json:"filename"`
+	Disposition string `json:"disposition"`
+	ContentID   string `json:"content_id,omitempty"`
+}
+
+// sendgridPersonalization holds the recipients of the message.
+type sendgridPersonalization struct {
+	To  []sendgridAddress `json:"to"`
+	Cc  []sendgridAddress `json:"cc,omitempty"`
+	Bcc []sendgridAddress `json:"bcc,omitempty"`
+}
+
+type sendgridMessage struct {
+	Personalizations []sendgridPersonalization `json:"personalizations"`
+	From             sendgridAddress           `json:"from"`
+	ReplyToList      []sendgridAddress         `json:"reply_to_list,omitempty"`
+	Subject          string                    `json:"subject"`
+	Content          []sendgridContent         `json:"content"`
+	Attachments      []sendgridAttachment      `json:"attachments,omitempty"`
+	Headers          map[string]string         `json:"headers,omitempty"`
+}
+
+// Send returns the X-Message-Id SendGrid assigns to the request.
+func (p *SendGridProvider) Send(ctx context.Context, msg *mail.Message) (string, error) {
+	if err := msg.Validate(); err != nil {
+		return "", err
+	}
+	msg, err := withSender(msg, p.config.From, "sendgrid")
+	if err != nil {
+		return "", err
+	}
+	request, err := newSendGridMessage(msg)
+	if err != nil {
+		return "", err
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sendgridURL+"/v3/mail/send", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.config.APIKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	header, _, err := doAPI(req, sendgridError)
+	if err != nil {
+		return "", fmt.Errorf("unable to send email: %w", err)
+	}
+	return header.Get("X-Message-Id"), nil
+}
+
+func newSendGridMessage(msg *mail.Message) (*sendgridMessage, error) {
+	request := &sendgridMessage{
+		Personalizations: make([]sendgridPersonalization, 1),
+		Subject:          msg.Subject,
+		Headers:          msg.Headers,
+	}
+
+	var err error
+	personalization := &request.Personalizations[0]
+	if personalization.To, err = sendgridAddresses(msg.To); err != nil {
+		return nil, err
+	}
+	if personalization.Cc, err = sendgridAddresses(msg.Cc); err != nil {
+		return nil, err
+	}
+	if personalization.Bcc, err = sendgridAddresses(msg.Bcc); err != nil {
+		return nil, err
+	}
+	if request.ReplyToList, err = sendgridAddresses(msg.ReplyTo); err != nil {
+		return nil, err
+	}
+	from, err := sendgridAddresses([]string{msg.From})
+	if err != nil {
+		return nil, err
+	}
+	request.From = from[0]
+
+	// SendGrid wants the plain-text part before the HTML one.
+	if msg.TextBody != "" {
+		request.Content = append(request.Content, sendgridContent{"text/plain", msg.TextBody})
+	}
+	if msg.HTMLBody != "" {
+		request.Content = append(request.Content, sendgridContent{"text/html", msg.HTMLBody})
+	}
+
+	for _, attachment := range msg.Attachments {
+		contentType := attachment.ContentType
+		if contentType == "" {
+			contentType = mime.DetectContentType(attachment.Filename, attachment.Data)
+		}
+		disposition := "attachment"
+		if attachment.ContentID != "" {
+			disposition = "inline"
+		}
+		request.Attachments = append(request.Attachments, sendgridAttachment{
+			Content:     attachment.Data,
+			Type:        contentType,
+			Filename:    attachment.Filename,
+			Disposition: disposition,
+			ContentID:   attachment.ContentID,
+		})
+	}
+	return request, nil
+}
+
+func sendgridAddresses(addresses []string) ([]sendgridAddress, error) {
+	var parsed []sendgridAddress
+	for _, address := range addresses {
+		a, err := netmail.ParseAddress(address)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address %q: %v", address, err)
+		}
+		parsed = append(parsed, sendgridAddress{Email: a.Address, Name: a.Name})
+	}
+	return parsed, nil
+}
+
+// sendgridError turns a SendGrid error response, a list of problems with
+// the request, into an APIError.
+func sendgridError(resp *http.Response, body []byte) error {
+	apiErr := newAPIError("SendGrid", resp, body)
+	var payload struct {
+		Errors []struct {
+			Message string `json:"message"`
+			Field   string `json:"field"`
+		} `json:"errors"`
+	}
+	if json.Unmarshal(body, &payload) == nil && len(payload.Errors) > 0 {
+		var messages []string
+		for _, e := range payload.Errors {
+			// Free plans run out with "Maximum credits exceeded".
+			if strings.Contains(strings.ToLower(e.Message), "maximum credits exceeded") {
+				apiErr.QuotaExceeded = true
+			}
+			if e.Field != "" {
+				messages = append(messages, e.Field+": "+e.Message)
+			} else {
+				messages = append(messages, e.Message)
+			}
+		}
+		apiErr.Message = strings.Join(messages, "; ")
+	}
+	return apiErr
+}
@@ -0,0 +1,106 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func sendgridTestProvider() *SendGridProvider {
+	return &SendGridProvider{config: &SendGridConfig{APIKey: "SG.key"}}
+}
+
+func TestSendGridSend(t *testing.T) {
+	server := newAPIServer(t, apiResponse{status: http.StatusAccepted, header: map[string]string{"X-Message-Id": "sg-id"}})
+	useURL(t, &sendgridURL, server.URL)
+
+	id, err := sendgridTestProvider().Send(context.Background(), apiTestMessage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "sg-id" {
+		t.Errorf("id = %q", id)
+	}
+
+	req := server.request(t)
+	if req.method != http.MethodPost || req.path != "/v3/mail/send" {
+		t.Errorf("request = %s %s", req.method, req.path)
+	}
+	if auth := req.header.Get("Authorization"); auth != "Bearer SG.key" {
+		t.Errorf("Authorization = %q", auth)
+	}
+	var body sendgridMessage
+	if err := json.Unmarshal(req.body, &body); err != nil {
+		t.Fatal(err)
+	}
+	want := sendgridMessage{
+		Personalizations: []sendgridPersonalization{{
+			To:  []sendgridAddress{{Email: "bob@example.com", Name: "Bob"}},
+			Cc:  []sendgridAddress{{Email: "carol@example.com"}},
+			Bcc: []sendgridAddress{{Email: "audit@example.com"}},
+		}},
+		From:        sendgridAddress{Email: "alice@example.com", Name: "Alice"},
+		ReplyToList: []sendgridAddress{{Email: "support@example.com"}},
+		Subject:     "Report",
+		Content:     []sendgridContent{{"text/plain", "See attached"}, {"text/html", "<p>See attached</p>"}},
+		Attachments: []sendgridAttachment{{
+			Content:     []byte("%PDF-1.4 report"),
+			Type:        "application/pdf",
+			Filename:    "report.pdf",
+			Disposition: "attachment",
+		}},
+		Headers: map[string]string{"X-Campaign": "june"},
+	}
+	if !reflect.DeepEqual(body, want) {
+		t.Errorf("request body = %+v\nwant %+v", body, want)
+	}
+}
+
+func TestSendGridErrors(t *testing.T) {
+	checkAPIError(t, []errorCase{
+		{
+			name: "rate limited",
+			response: apiResponse{
+				status: http.StatusTooManyRequests,
+				header: map[string]string{"Retry-After": "30"},
+				body:   `{"errors":[{"message":"too many requests","field":null}]}`,
+			},
+			message:    "too many requests",
+			transient:  true,
+			retryAfter: 30 * time.Second,
+		},
+		{
+			name: "credits used up",
+			response: apiResponse{
+				status: http.StatusUnauthorized,
+				body:   `{"errors":[{"message":"Maximum credits exceeded","field":null}]}`,
+			},
+			message: "Maximum credits exceeded",
+			quota:   true,
+		},
+		{
+			name: "invalid request",
+			response: apiResponse{
+				status: http.StatusBadRequest,
+				body:   `{"errors":[{"message":"The from address does not match a verified Sender Identity.","field":"from"},{"message":"Invalid email","field":"personalizations.0.to"}]}`,
+			},
+			message: "from: The from address does not match a verified Sender Identity.; personalizations.0.to: Invalid email",
+		},
+		{
+			name:      "server error",
+			response:  apiResponse{status: http.StatusInternalServerError, body: "oops"},
+			message:   "oops",
+			transient: true,
+		},
+	}, func(t *testing.T, url string) error {
+		useURL(t, &sendgridURL, url)
+		_, err := sendgridTestProvider().Send(context.Background(), apiTestMessage())
+		return err
+	})
+}
@@ -0,0 +1,102 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/latocchi/gomailit/internal/mail"
+	"github.com/latocchi/gomailit/internal/utils"
+	"golang.org/x/oauth2/google"
+	"golang.org/x/oauth2/jwt"
+)
+
+// serviceAccount is a service account key together with the Workspace user
+// it impersonates through domain-wide delegation.
+type serviceAccount struct {
+	Subject string          `json:"subject"`
+	Key     json.RawMessage `json:"key"`
+}
+
+// jwtConfig returns the config that signs token requests for the subject.
+func (sa *serviceAccount) jwtConfig() (*jwt.Config, error) {
+	conf, err := google.JWTConfigFromJSON(sa.Key, googleScopes()...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse service account key: %v", err)
+	}
+	conf.Subject = sa.Subject
+	return conf, nil
+}
+
+// setupServiceAccount checks that the key may impersonate subject and saves
+// both for the selected profile, replacing any OAuth token flow.
+func setupServiceAccount(ctx context.Context, keyPath, subject string) error {
+	if subject == "" {
+		return errors.New("--service-account needs --subject, the user whose mailbox to send from")
+	}
+	if _, err := mail.ParseAddressList([]string{subject}); err != nil {
+		return fmt.Errorf("invalid --subject: %v", err)
+	}
+	key, err := os.ReadFile(keyPath)
+	if err != nil {
+		return fmt.Errorf("unable to read service account key: %v", err)
+	}
+
+	sa := &serviceAccount{Subject: mail.BareAddress(subject), Key: key}
+	conf, err := sa.jwtConfig()
+	if err != nil {
+		return err
+	}
+	// Fetching a token proves that delegation is granted for our scopes.
+	if _, err := conf.TokenSource(ctx).Token(); err != nil {
+		return fmt.Errorf("unable to get a token as %s, check that service account %s has domain-wide delegation for %s: %v", sa.Subject, conf.Email, strings.Join(googleScopes(), ","), err)
+	}
+
+	data, err := json.MarshalIndent(sa, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to encode service account: %v", err)
+	}
+	path := utils.ServiceAccountPath()
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("unable to save service account: %v", err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		return fmt.Errorf("unable to save service account: %v", err)
+	}
+	fmt.Printf("Google provider set up with service account %s acting as %s\n", conf.Email, sa.Subject)
+	return nil
+}
+
+// loadServiceAccount returns the service account of the selected profile.
+// It fails with os.ErrNotExist when the profile uses an OAuth token.
+func loadServiceAccount() (*serviceAccount, error) {
+	data, err := os.ReadFile(utils.ServiceAccountPath())
+	if err != nil {
+		return nil, err
+	}
+	sa := &serviceAccount{}
+	if err := json.Unmarshal(data, sa); err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %v", utils.ServiceAccountPath(), err)
+	}
+	return sa, nil
+}
+
+// removeServiceAccount switches the selected profile back to an OAuth
+// token.
+func removeServiceAccount() error {
+	err := os.Remove(utils.ServiceAccountPath())
+	if err == nil {
+		fmt.Println("Removed service account, using an OAuth token instead")
+		return nil
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return fmt.Errorf("unable to remove service account: %v", err)
+}
@@ -0,0 +1,217 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package providers
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/latocchi/gomailit/internal/utils"
+)
+
+const serviceAccountEmail = "sender@project.iam.gserviceaccount.com"
+
+// jwtServer is a fake Google token endpoint for the JWT bearer grant. It
+// verifies the assertion's signature and records its claims.
+type jwtServer struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+	// deny rejects the grant, as Google does without delegation.
+	deny bool
+
+	mu     sync.Mutex
+	claims map[string]any
+	err    error
+}
+
+func newJWTServer(t *testing.T) *jwtServer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &jwtServer{key: key}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.token))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// keyFile returns a service account key file whose token_uri points to s.
+func (s *jwtServer) keyFile(t *testing.T) string {
+	der, err := x509.MarshalPKCS8PrivateKey(s.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"project_id":     "project",
+		"private_key_id": "key-1",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"client_email":   serviceAccountEmail,
+		"client_id":      "1234",
+		"token_uri":      s.URL + "/token",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := t.TempDir() + "/key.json"
+	if err := os.WriteFile(path, key, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func (s *jwtServer) token(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if s.deny {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"error":"unauthorized_client","error_description":"Client is unauthorized to retrieve access tokens using this method."}`)
+		return
+	}
+	s.claims, s.err = s.verify(r)
+	if s.err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error":"invalid_grant"}`)
+		return
+	}
+	fmt.Fprint(w, `{"access_token":"service-token","token_type":"Bearer","expires_in":3600}`)
+}
+
+// verify checks the grant type and the RS256 signature of the assertion
+// and returns its claims.
+func (s *jwtServer) verify(r *http.Request) (map[string]any, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+	if grant := r.PostForm.Get("grant_type"); grant != "urn:ietf:params:oauth:grant-type:jwt-bearer" {
+		return nil, fmt.Errorf("grant_type = %q", grant)
+	}
+	parts := strings.Split(r.PostForm.Get("assertion"), ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("assertion has %d parts", len(parts))
+	}
+
+	var header map[string]any
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+	if header["alg"] != "RS256" || header["kid"] != "key-1" {
+		return nil, fmt.Errorf("header = %v", header)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(&s.key.PublicKey, crypto.SHA256, sum[:], signature); err != nil {
+		return nil, fmt.Errorf("bad signature: %v", err)
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func TestServiceAccountImpersonation(t *testing.T) {
+	useTempConfigDir(t)
+	server := newJWTServer(t)
+
+	if err := setupServiceAccount(context.Background(), server.keyFile(t), "Alice <alice@example.com>"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(utils.ServiceAccountPath()); err != nil {
+		t.Fatalf("service account not saved: %v", err)
+	}
+
+	// Later runs get their token from the saved service account.
+	source, status, err := googleTokenSource(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.ServiceAccount != serviceAccountEmail {
+		t.Errorf("status names service account %q", status.ServiceAccount)
+	}
+	tok, err := source.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.AccessToken != "service-token" {
+		t.Errorf("token = %+v", tok)
+	}
+
+	server.mu.Lock()
+	claims, verifyErr := server.claims, server.err
+	server.mu.Unlock()
+	if verifyErr != nil {
+		t.Fatal(verifyErr)
+	}
+	want := map[string]any{
+		"iss":   serviceAccountEmail,
+		"sub":   "alice@example.com",
+		"aud":   server.URL + "/token",
+		"scope": strings.Join(googleScopes(), " "),
+	}
+	for claim, value := range want {
+		if claims[claim] != value {
+			t.Errorf("claim %s = %v, want %v", claim, claims[claim], value)
+		}
+	}
+	iat, _ := claims["iat"].(float64)
+	exp, _ := claims["exp"].(float64)
+	if now := float64(time.Now().Unix()); iat < now-60 || iat > now+60 || exp-iat != 3600 {
+		t.Errorf("iat = %v, exp = %v, want a one hour token issued now", iat, exp)
+	}
+}
+
+func TestServiceAccountWithoutDelegation(t *testing.T) {
+	useTempConfigDir(t)
+	server := newJWTServer(t)
+	server.deny = true
+
+	err := setupServiceAccount(context.Background(), server.keyFile(t), "alice@example.com")
+	if err == nil || !strings.Contains(err.Error(), "domain-wide delegation") {
+		t.Errorf("error = %v, want a hint about domain-wide delegation", err)
+	}
+	if _, err := os.Stat(utils.ServiceAccountPath()); !os.IsNotExist(err) {
+		t.Error("service account saved although no token could be fetched")
+	}
+}
+
+func TestServiceAccountNeedsSubject(t *testing.T) {
+	useTempConfigDir(t)
+	server := newJWTServer(t)
+
+	for _, subject := range []string{"", "not an address"} {
+		if err := setupServiceAccount(context.Background(), server.keyFile(t), subject); err == nil {
+			t.Errorf("subject %q was accepted", subject)
+		}
+	}
+}
@@ -0,0 +1,310 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package providers
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/latocchi/gomailit/internal/mail"
+	"github.com/latocchi/gomailit/internal/mime"
+	"github.com/spf13/pflag"
+)
+
+// sesURL is the SES v2 API endpoint, {region} is replaced with the
+// configured region.
+var sesURL = "https://email.{region}.amazonaws.com"
+
+func init() {
+	Register(Registration{
+		Name:        "ses",
+		Aliases:     []string{"amazon-ses"},
+		Description: "Amazon Simple Email Service (SES v2 API)",
+		Flags: func(flags *pflag.FlagSet) {
+			flags.String("region", os.Getenv("AWS_REGION"), "AWS region of the SES account, e.g. eu-west-1")
+			flags.String("access-key-id", "", "AWS access key ID (default $AWS_ACCESS_KEY_ID when sending)")
+			flags.String("secret-access-key", "", "AWS secret access key, prompted for when --access-key-id is set")
+			flags.String("configuration-set", "", "SES configuration set to send with")
+			flags.String("from", "", "Sender address, must be a verified identity")
+			tokenStoreFlag(flags, "secret access key")
+		},
+		Setup: func(ctx context.Context, flags *pflag.FlagSet) error {
+			config := &SESConfig{}
+			config.Region, _ = flags.GetString("region")
+			config.AccessKeyID, _ = flags.GetString("access-key-id")
+			config.SecretAccessKey, _ = flags.GetString("secret-access-key")
+			config.ConfigurationSet, _ = flags.GetString("configuration-set")
+			config.From, _ = flags.GetString("from")
+
+			if config.Region == "" {
+				return errors.New("--region is required")
+			}
+			if config.AccessKeyID != "" && config.SecretAccessKey == "" {
+				config.SecretAccessKey = promptSecret(fmt.Sprintf("Secret access key for %s: ", config.AccessKeyID))
+			}
+			if err := useTokenStoreFlag(flags, sesSecret); err != nil {
+				return err
+			}
+			if err := saveAPIConfig(sesSecret, config); err != nil {
+				return err
+			}
+			fmt.Printf("SES provider configured for %s\n", config.Region)
+			return nil
+		},
+		New: func(ctx context.Context) (Provider, error) {
+			config := &SESConfig{}
+			if err := loadAPIConfig(sesSecret, config); err != nil {
+				return nil, err
+			}
+			return &SESProvider{config: config}, nil
+		},
+	})
+}
+
+// SESConfig holds the settings saved by 'gomailit setup ses'. Without an
+// access key the standard AWS environment variables are used, so that
+// credentials can be handed over by the environment the job runs in.
+type SESConfig struct {
+	Region      string `json:"region"`
+	AccessKeyID string `json:"access_key_id,omitempty"`
+	// SecretAccessKey is kept in the token store. It is only read from
+	// ses.json files written by older versions.
+	SecretAccessKey  string `json:"secret_access_key,omitempty"`
+	ConfigurationSet string `json:"configuration_set,omitempty"`
+	From             string `json:"from,omitempty"`
+}
+
+func (c *SESConfig) secret() *string {
+	// Without an access key the environment provides the credentials.
+	if c.AccessKeyID == "" {
+		return nil
+	}
+	return &c.SecretAccessKey
+}
+
+// credentials returns the AWS credentials to sign requests with.
+func (c *SESConfig) credentials() (awsCredentials, error) {
+	if c.AccessKeyID != "" {
+		return awsCredentials{AccessKeyID: c.AccessKeyID, SecretAccessKey: c.SecretAccessKey}, nil
+	}
+	creds := awsCredentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return awsCredentials{}, errors.New("no AWS credentials, set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY or run 'gomailit setup ses --access-key-id'")
+	}
+	return creds, nil
+}
+
+// SESProvider sends email with the SendEmail call of the SES v2 API. The
+// message is always submitted as raw MIME, so that attachments, inline
+// images and custom headers arrive as built.
+type SESProvider struct {
+	config *SESConfig
+}
+
+func (p *SESProvider) Send(ctx context.Context, msg *mail.Message) (string, error) {
+	if err := msg.Validate(); err != nil {
+		return "", err
+	}
+	msg, err := withSender(msg, p.config.From, "ses")
+	if err != nil {
+		return "", err
+	}
+	raw, err := mime.Build(msg)
+	if err != nil {
+		return "", fmt.Errorf("unable to build message: %v", err)
+	}
+
+	destination := sesDestination{
+		ToAddresses:  bareAddresses(msg.To),
+		CcAddresses:  bareAddresses(msg.Cc),
+		BccAddresses: bareAddresses(msg.Bcc),
+	}
+	return p.send(ctx, mail.BareAddress(msg.From), destination, raw)
+}
+
+// SendRaw submits a pre-built message. The recipients of the envelope go
+// into the destination, so they may differ from the headers.
+func (p *SESProvider) SendRaw(ctx context.Context, env mime.Envelope, raw []byte) (string, error) {
+	if len(env.Recipients) == 0 {
+		return "", errors.New("message has no recipients")
+	}
+	from := env.From
+	if from == "" {
+		from = mail.BareAddress(p.config.From)
+	}
+	raw = mime.StripHeader(raw, "Bcc")
+	return p.send(ctx, from, sesDestination{ToAddresses: env.Recipients}, raw)
+}
+
+type sesDestination struct {
+	ToAddresses  []string `json:",omitempty"`
+	CcAddresses  []string `json:",omitempty"`
+	BccAddresses []string `json:",omitempty"`
+}
+
+func (p *SESProvider) send(ctx context.Context, from string, destination sesDestination, raw []byte) (string, error) {
+	creds, err := p.config.credentials()
+	if err != nil {
+		return "", err
+	}
+
+	var request struct {
+		FromEmailAddress     string `json:",omitempty"`
+		Destination          sesDestination
+		Content              struct{ Raw struct{ Data []byte } }
+		ConfigurationSetName string `json:",omitempty"`
+	}
+	request.FromEmailAddress = from
+	request.Destination = destination
+	request.Content.Raw.Data = raw
+	request.ConfigurationSetName = p.config.ConfigurationSet
+	body, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+
+	endpoint := strings.ReplaceAll(sesURL, "{region}", p.config.Region) + "/v2/email/outbound-emails"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	signV4(req, body, creds, p.config.Region, "ses", time.Now())
+
+	_, data, err := doAPI(req, sesError)
+	if err != nil {
+		return "", fmt.Errorf("unable to send email: %w", err)
+	}
+	var response struct {
+		MessageId string
+	}
+	if err := json.Unmarshal(data, &response); err != nil {
+		return "", fmt.Errorf("unable to parse SES response: %v", err)
+	}
+	return response.MessageId, nil
+}
+
+// sesError turns an SES error response into an APIError. The error type
+// comes in a header, e.g. "MessageRejected:http://internal.amazon.com/...".
+func sesError(resp *http.Response, body []byte) error {
+	apiErr := newAPIError("SES", resp, body)
+	apiErr.Code, _, _ = strings.Cut(resp.Header.Get("X-Amzn-ErrorType"), ":")
+	var payload struct {
+		Message      string `json:"message"`
+		MessageUpper string `json:"Message"`
+	}
+	if json.Unmarshal(body, &payload) == nil {
+		if payload.Message != "" {
+			apiErr.Message = payload.Message
+		} else if payload.MessageUpper != "" {
+			apiErr.Message = payload.MessageUpper
+		}
+	}
+	// SES answers "Daily message quota exceeded" once the sending quota of
+	// the last 24 hours is used up.
+	apiErr.QuotaExceeded = strings.Contains(strings.ToLower(apiErr.Message), "daily message quota exceeded")
+	return apiErr
+}
+
+func bareAddresses(addresses []string) []string {
+	var bare []string
+	for _, address := range addresses {
+		bare = append(bare, mail.BareAddress(address))
+	}
+	return bare
+}
+
+// awsCredentials are the keys AWS requests are signed with.
+type awsCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is set for temporary credentials only.
+	SessionToken string
+}
+
+// signV4 signs req with AWS Signature Version 4. The host, the content
+// type and the X-Amz-* headers are signed.
+func signV4(req *http.Request, body []byte, creds awsCredentials, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if name == "content-type" || strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		sha256Hex(body),
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := []byte("AWS4" + creds.SecretAccessKey)
+	for _, part := range []string{date, region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// canonicalQuery encodes the query sorted by key, with spaces as %20.
+func canonicalQuery(query url.Values) string {
+	return strings.ReplaceAll(query.Encode(), "+", "%20")
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,226 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/latocchi/gomailit/internal/mime"
+)
+
+// TestSignV4 checks the signer against the AWS Signature Version 4 test
+// suite, whose requests are signed for service "service" in us-east-1 on
+// 2015-08-30 12:36:00 UTC.
+func TestSignV4(t *testing.T) {
+	creds := awsCredentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		method        string
+		url           string
+		contentType   string
+		body          string
+		signedHeaders string
+		signature     string
+	}{
+		{
+			name:          "get-vanilla",
+			method:        http.MethodGet,
+			url:           "https://example.amazonaws.com/",
+			signedHeaders: "host;x-amz-date",
+			signature:     "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		{
+			name:          "get-vanilla-query-order-key-case",
+			method:        http.MethodGet,
+			url:           "https://example.amazonaws.com/?Param2=value2&Param1=value1",
+			signedHeaders: "host;x-amz-date",
+			signature:     "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+		},
+		{
+			name:          "post-vanilla",
+			method:        http.MethodPost,
+			url:           "https://example.amazonaws.com/",
+			signedHeaders: "host;x-amz-date",
+			signature:     "5da7c1a2acd57cee7505fc6676e4e544621c30862966e37dddb68e92efbe5d6b",
+		},
+		{
+			name:          "post-x-www-form-urlencoded",
+			method:        http.MethodPost,
+			url:           "https://example.amazonaws.com/",
+			contentType:   "application/x-www-form-urlencoded",
+			body:          "Param1=value1",
+			signedHeaders: "content-type;host;x-amz-date",
+			signature:     "ff11897932ad3f4e8b18135d722051e5ac45fc38421b1da7b9d196a0fe09473a",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			signV4(req, []byte(tt.body), creds, "us-east-1", "service", now)
+
+			want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+				"SignedHeaders=" + tt.signedHeaders + ", Signature=" + tt.signature
+			if got := req.Header.Get("Authorization"); got != want {
+				t.Errorf("Authorization = %s\nwant %s", got, want)
+			}
+			if got := req.Header.Get("X-Amz-Date"); got != "20150830T123600Z" {
+				t.Errorf("X-Amz-Date = %s", got)
+			}
+		})
+	}
+}
+
+func TestSignV4SessionToken(t *testing.T) {
+	req, err := http.NewRequest(http.MethodPost, "https://email.eu-west-1.amazonaws.com/v2/email/outbound-emails", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	creds := awsCredentials{AccessKeyID: "AKID", SecretAccessKey: "secret", SessionToken: "session-token"}
+	signV4(req, nil, creds, "eu-west-1", "ses", time.Now())
+
+	if got := req.Header.Get("X-Amz-Security-Token"); got != "session-token" {
+		t.Errorf("X-Amz-Security-Token = %q", got)
+	}
+	if auth := req.Header.Get("Authorization"); !strings.Contains(auth, "SignedHeaders=host;x-amz-date;x-amz-security-token,") {
+		t.Errorf("session token not signed: %s", auth)
+	}
+}
+
+func sesTestProvider() *SESProvider {
+	return &SESProvider{config: &SESConfig{
+		Region:           "eu-west-1",
+		AccessKeyID:      "AKID",
+		SecretAccessKey:  "secret",
+		ConfigurationSet: "tracking",
+	}}
+}
+
+// sesRequest decodes the body of a SendEmail call.
+type sesRequest struct {
+	FromEmailAddress     string
+	Destination          sesDestination
+	Content              struct{ Raw struct{ Data []byte } }
+	ConfigurationSetName string
+}
+
+func decodeSESRequest(t *testing.T, req apiRequest) sesRequest {
+	t.Helper()
+	if req.method != http.MethodPost || req.path != "/v2/email/outbound-emails" {
+		t.Errorf("request = %s %s", req.method, req.path)
+	}
+	if auth := req.header.Get("Authorization"); !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/") ||
+		!strings.Contains(auth, "/eu-west-1/ses/aws4_request") {
+		t.Errorf("Authorization = %q", auth)
+	}
+	var body sesRequest
+	if err := json.Unmarshal(req.body, &body); err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func TestSESSend(t *testing.T) {
+	server := newAPIServer(t, apiResponse{status: http.StatusOK, body: `{"MessageId":"ses-id"}`})
+	useURL(t, &sesURL, server.URL)
+
+	id, err := sesTestProvider().Send(context.Background(), apiTestMessage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "ses-id" {
+		t.Errorf("id = %q", id)
+	}
+
+	body := decodeSESRequest(t, server.request(t))
+	if body.FromEmailAddress != "alice@example.com" || body.ConfigurationSetName != "tracking" {
+		t.Errorf("request = %+v", body)
+	}
+	destination := body.Destination
+	if strings.Join(destination.ToAddresses, ",") != "bob@example.com" ||
+		strings.Join(destination.CcAddresses, ",") != "carol@example.com" ||
+		strings.Join(destination.BccAddresses, ",") != "audit@example.com" {
+		t.Errorf("destination = %+v", destination)
+	}
+	raw := string(body.Content.Raw.Data)
+	if !strings.Contains(raw, "Subject: Report\r\n") || strings.Contains(raw, "audit@example.com") {
+		t.Errorf("raw message:\n%s", raw)
+	}
+}
+
+func TestSESSendRaw(t *testing.T) {
+	server := newAPIServer(t, apiResponse{status: http.StatusOK, body: `{"MessageId":"ses-id"}`})
+	useURL(t, &sesURL, server.URL)
+
+	raw := []byte("To: bob@example.com\r\nBcc: audit@example.com\r\nSubject: hi\r\n\r\nHi\r\n")
+	env := mime.Envelope{From: "alice@example.com", Recipients: []string{"bob@example.com", "audit@example.com"}}
+	if _, err := sesTestProvider().SendRaw(context.Background(), env, raw); err != nil {
+		t.Fatal(err)
+	}
+
+	body := decodeSESRequest(t, server.request(t))
+	if body.FromEmailAddress != "alice@example.com" || strings.Join(body.Destination.ToAddresses, ",") != "bob@example.com,audit@example.com" {
+		t.Errorf("request = %+v", body)
+	}
+	if strings.Contains(string(body.Content.Raw.Data), "Bcc:") {
+		t.Errorf("Bcc header sent:\n%s", body.Content.Raw.Data)
+	}
+}
+
+func TestSESErrors(t *testing.T) {
+	checkAPIError(t, []errorCase{
+		{
+			name: "throttled",
+			response: apiResponse{
+				status: http.StatusTooManyRequests,
+				header: map[string]string{"X-Amzn-ErrorType": "TooManyRequestsException:http://internal.amazon.com/", "Retry-After": "2"},
+				body:   `{"message":"Maximum sending rate exceeded."}`,
+			},
+			message:    "Maximum sending rate exceeded.",
+			transient:  true,
+			retryAfter: 2 * time.Second,
+		},
+		{
+			name: "daily quota",
+			response: apiResponse{
+				status: http.StatusBadRequest,
+				header: map[string]string{"X-Amzn-ErrorType": "LimitExceededException"},
+				body:   `{"message":"Daily message quota exceeded."}`,
+			},
+			message: "Daily message quota exceeded.",
+			quota:   true,
+		},
+		{
+			name: "unverified sender",
+			response: apiResponse{
+				status: http.StatusBadRequest,
+				header: map[string]string{"X-Amzn-ErrorType": "MessageRejected"},
+				body:   `{"Message":"Email address is not verified."}`,
+			},
+			message: "Email address is not verified.",
+		},
+		{
+			name:      "server error",
+			response:  apiResponse{status: http.StatusServiceUnavailable, body: "unavailable"},
+			message:   "unavailable",
+			transient: true,
+		},
+	}, func(t *testing.T, url string) error {
+		useURL(t, &sesURL, url)
+		_, err := sesTestProvider().Send(context.Background(), apiTestMessage())
+		return err
+	})
+}
@@ -0,0 +1,497 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package providers
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	netmail "net/mail"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/latocchi/gomailit/internal/mail"
+	"github.com/latocchi/gomailit/internal/mime"
+	"github.com/latocchi/gomailit/internal/tokenstore"
+	"github.com/latocchi/gomailit/internal/utils"
+	"github.com/spf13/pflag"
+	"golang.org/x/oauth2"
+)
+
+// Supported values for SMTPConfig.Security.
+const (
+	SMTPSecurityStartTLS = "starttls"
+	SMTPSecurityTLS      = "tls"
+	SMTPSecurityNone     = "none"
+)
+
+// Supported values for SMTPConfig.Auth.
+const (
+	SMTPAuthPlain   = "plain"
+	SMTPAuthLogin   = "login"
+	SMTPAuthCRAMMD5 = "cram-md5"
+	SMTPAuthXOAUTH2 = "xoauth2"
+	SMTPAuthNone    = "none"
+)
+
+// SMTPConfig holds the settings saved by 'gomailit setup smtp'.
+type SMTPConfig struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Username string `json:"username,omitempty"`
+	// Password is kept in the token store. It is only read from smtp.json
+	// files written by older versions.
+	Password string `json:"password,omitempty"`
+	// Security is one of "starttls", "tls" (implicit TLS) or "none".
+	Security string `json:"security"`
+	// Auth is one of "plain", "login", "cram-md5", "xoauth2" or "none".
+	Auth string `json:"auth"`
+	From string `json:"from,omitempty"`
+	// TokenCommand prints the OAuth access token for xoauth2.
+	TokenCommand string `json:"token_command,omitempty"`
+}
+
+// Validate fills in defaults and checks that the settings are usable.
+func (c *SMTPConfig) Validate() error {
+	if c.Host == "" {
+		return errors.New("smtp host is required")
+	}
+
+	c.Security = strings.ToLower(c.Security)
+	if c.Security == "" {
+		c.Security = SMTPSecurityStartTLS
+	}
+	switch c.Security {
+	case SMTPSecurityStartTLS, SMTPSecurityTLS, SMTPSecurityNone:
+	default:
+		return fmt.Errorf("unsupported smtp security mode: %s", c.Security)
+	}
+
+	if c.Port == 0 {
+		switch c.Security {
+		case SMTPSecurityTLS:
+			c.Port = 465
+		case SMTPSecurityStartTLS:
+			c.Port = 587
+		default:
+			c.Port = 25
+		}
+	}
+
+	c.Auth = strings.ToLower(c.Auth)
+	if c.Auth == "" {
+		if c.Username == "" {
+			c.Auth = SMTPAuthNone
+		} else {
+			c.Auth = SMTPAuthPlain
+		}
+	}
+	switch c.Auth {
+	case SMTPAuthPlain, SMTPAuthLogin, SMTPAuthCRAMMD5, SMTPAuthXOAUTH2:
+		if c.Username == "" {
+			return fmt.Errorf("smtp auth %s requires a username", c.Auth)
+		}
+	case SMTPAuthNone:
+	default:
+		return fmt.Errorf("unsupported smtp auth mechanism: %s", c.Auth)
+	}
+
+	return nil
+}
+
+// Addr returns the host:port pair of the SMTP server.
+func (c *SMTPConfig) Addr() string {
+	return net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+}
+
+// smtpPassword keeps the SMTP password in the token store chosen with
+// 'setup smtp --token-store', as the access token of a token that never
+// expires, so that smtp.json holds no secret.
+var smtpPassword = &oauthToken{provider: "smtp"}
+
+// SaveSMTPConfig writes the SMTP settings to the gomailit config directory
+// and the password, if any, to the token store.
+func SaveSMTPConfig(config *SMTPConfig) error {
+	if config.Password != "" {
+		if err := smtpPassword.save(&oauth2.Token{AccessToken: config.Password}); err != nil {
+			return fmt.Errorf("unable to save smtp password: %v", err)
+		}
+	} else {
+		// Best effort, a password left by an earlier setup is unused.
+		smtpPassword.delete()
+	}
+	fmt.Printf("Saving SMTP settings to: %s\n", utils.SMTPConfigPath())
+	return writeSMTPConfig(config)
+}
+
+func writeSMTPConfig(config *SMTPConfig) error {
+	f, err := os.OpenFile(utils.SMTPConfigPath(), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("unable to save smtp settings: %v", err)
+	}
+	defer f.Close()
+
+	stored := *config
+	stored.Password = ""
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return enc.Encode(&stored)
+}
+
+// LoadSMTPConfig reads the SMTP settings saved by SaveSMTPConfig. A
+// password that an older version saved in smtp.json is moved into the
+// token store.
+func LoadSMTPConfig() (*SMTPConfig, error) {
+	f, err := os.Open(utils.SMTPConfigPath())
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	config := &SMTPConfig{}
+	if err := json.NewDecoder(f).Decode(config); err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case config.Password != "":
+		if err := smtpPassword.save(&oauth2.Token{AccessToken: config.Password}); err != nil {
+			return nil, fmt.Errorf("unable to move smtp password to the token store: %v", err)
+		}
+		if err := writeSMTPConfig(config); err != nil {
+			return nil, err
+		}
+	case config.needsPassword():
+		tok, err := smtpPassword.load()
+		if err != nil {
+			return nil, fmt.Errorf("unable to load smtp password: %v", err)
+		}
+		config.Password = tok.AccessToken
+	}
+	return config, nil
+}
+
+// needsPassword reports whether the auth mechanism logs in with a password.
+func (c *SMTPConfig) needsPassword() bool {
+	return c.Auth == SMTPAuthPlain || c.Auth == SMTPAuthLogin || c.Auth == SMTPAuthCRAMMD5
+}
+
+func init() {
+	Register(Registration{
+		Name:        "smtp",
+		Description: "Any SMTP server (STARTTLS, implicit TLS or plain)",
+		Flags: func(flags *pflag.FlagSet) {
+			flags.String("host", "", "SMTP server host")
+			flags.Int("port", 0, "SMTP server port (default depends on --security)")
+			flags.String("username", "", "SMTP username")
+			flags.String("password", "", "SMTP password, prompted for when omitted")
+			flags.String("security", SMTPSecurityStartTLS, "Connection security: starttls, tls or none")
+			flags.String("auth", "", "Auth mechanism: plain, login, cram-md5, xoauth2 or none (default plain when --username is set)")
+			flags.String("token-command", "", "Command printing an OAuth access token, for --auth xoauth2")
+			flags.String("token-store", "", "Where to keep the password: keyring, file or encrypted-file (default keyring)")
+			flags.String("from", "", "Sender address, defaults to --username")
+		},
+		Setup: func(ctx context.Context, flags *pflag.FlagSet) error {
+			config := &SMTPConfig{}
+			config.Host, _ = flags.GetString("host")
+			config.Port, _ = flags.GetInt("port")
+			config.Username, _ = flags.GetString("username")
+			config.Password, _ = flags.GetString("password")
+			config.Security, _ = flags.GetString("security")
+			config.Auth, _ = flags.GetString("auth")
+			config.From, _ = flags.GetString("from")
+			config.TokenCommand, _ = flags.GetString("token-command")
+
+			if value, _ := flags.GetString("token-store"); value != "" {
+				kind, err := tokenstore.ParseKind(value)
+				if err != nil {
+					return err
+				}
+				if err := smtpPassword.switchStore(kind); err != nil {
+					return err
+				}
+			}
+			if strings.EqualFold(config.Auth, SMTPAuthXOAUTH2) {
+				if config.TokenCommand == "" {
+					return errors.New("--auth xoauth2 needs --token-command to get access tokens")
+				}
+			} else if config.Username != "" && config.Password == "" {
+				config.Password = promptSecret(fmt.Sprintf("Password for %s: ", config.Username))
+			}
+			if err := SetupSMTP(config); err != nil {
+				return err
+			}
+			fmt.Printf("SMTP provider configured for %s\n", config.Addr())
+			return nil
+		},
+		New: func(ctx context.Context) (Provider, error) {
+			config, err := LoadSMTPConfig()
+			if err != nil {
+				return nil, fmt.Errorf("unable to load smtp settings, please run 'gomailit setup smtp' first: %v", err)
+			}
+			p := NewSMTPProvider(config)
+			if config.TokenCommand != "" {
+				p.tokens = commandTokenSource{config.TokenCommand}
+			}
+			return p, nil
+		},
+	})
+}
+
+// SetupSMTP validates and stores the SMTP settings.
+func SetupSMTP(config *SMTPConfig) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	return SaveSMTPConfig(config)
+}
+
+// SMTPProvider sends email through an SMTP server.
+type SMTPProvider struct {
+	config *SMTPConfig
+	// tlsConfig is used for both STARTTLS and implicit TLS connections.
+	tlsConfig *tls.Config
+	// tokens supplies the access tokens for xoauth2.
+	tokens oauth2.TokenSource
+}
+
+func NewSMTPProvider(config *SMTPConfig) *SMTPProvider {
+	return &SMTPProvider{
+		config:    config,
+		tlsConfig: &tls.Config{ServerName: config.Host},
+	}
+}
+
+func (p *SMTPProvider) Send(ctx context.Context, msg *mail.Message) (string, error) {
+	if err := msg.Validate(); err != nil {
+		return "", err
+	}
+
+	copied := *msg
+	msg = &copied
+	if msg.From == "" {
+		msg.From = p.config.From
+		if msg.From == "" {
+			msg.From = p.config.Username
+		}
+		if msg.From == "" {
+			return "", errors.New("no sender address configured, set --from with 'gomailit setup smtp'")
+		}
+	}
+
+	// SMTP servers do not report a message ID, so the Message-ID header
+	// doubles as one.
+	messageID := msg.Headers["Message-ID"]
+	if messageID == "" {
+		messageID = mime.NewMessageID(msg.From)
+		msg.Headers = maps.Clone(msg.Headers)
+		msg.SetHeader("Message-ID", messageID)
+	}
+
+	// Bcc recipients only go into the envelope, never the headers.
+	raw, err := mime.Build(msg)
+	if err != nil {
+		return "", fmt.Errorf("unable to build message: %v", err)
+	}
+	if err := p.send(ctx, msg.From, msg.Recipients(), raw); err != nil {
+		return "", err
+	}
+	return messageID, nil
+}
+
+// SendRaw submits a pre-built message. Any Bcc header is removed first so
+// that Bcc recipients stay hidden.
+func (p *SMTPProvider) SendRaw(ctx context.Context, env mime.Envelope, raw []byte) (string, error) {
+	if len(env.Recipients) == 0 {
+		return "", errors.New("message has no recipients")
+	}
+	from := env.From
+	if from == "" {
+		from = p.config.From
+	}
+	if from == "" {
+		from = p.config.Username
+	}
+	if from == "" {
+		return "", errors.New("no envelope sender, the message has no From header and none is configured")
+	}
+
+	raw = mime.StripHeader(raw, "Bcc")
+	if err := p.send(ctx, from, env.Recipients, raw); err != nil {
+		return "", err
+	}
+
+	id := ""
+	if msg, err := netmail.ReadMessage(bytes.NewReader(raw)); err == nil {
+		id = msg.Header.Get("Message-ID")
+	}
+	return id, nil
+}
+
+func (p *SMTPProvider) send(ctx context.Context, from string, to []string, message []byte) error {
+	client, err := p.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if err := p.auth(client); err != nil {
+		return err
+	}
+
+	if err := client.Mail(mail.BareAddress(from)); err != nil {
+		return fmt.Errorf("smtp MAIL FROM failed: %w", err)
+	}
+	for _, rcpt := range to {
+		if err := client.Rcpt(mail.BareAddress(rcpt)); err != nil {
+			return fmt.Errorf("smtp RCPT TO %s failed: %w", rcpt, err)
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("smtp DATA failed: %w", err)
+	}
+	if _, err := w.Write(message); err != nil {
+		return fmt.Errorf("unable to write message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp server rejected message: %w", err)
+	}
+
+	return client.Quit()
+}
+
+// login connects to the server and authenticates, without sending
+// anything.
+func (p *SMTPProvider) login(ctx context.Context) error {
+	client, err := p.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if err := p.auth(client); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// dial connects to the server and negotiates TLS according to the
+// configured security mode.
+func (p *SMTPProvider) dial(ctx context.Context) (*smtp.Client, error) {
+	addr := p.config.Addr()
+
+	var conn net.Conn
+	var err error
+	if p.config.Security == SMTPSecurityTLS {
+		dialer := &tls.Dialer{Config: p.tlsConfig}
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	} else {
+		var dialer net.Dialer
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to smtp server %s: %w", addr, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, p.config.Host)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("unable to start smtp session: %w", err)
+	}
+
+	if p.config.Security == SMTPSecurityStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			client.Close()
+			return nil, fmt.Errorf("smtp server %s does not support STARTTLS", addr)
+		}
+		if err := client.StartTLS(p.tlsConfig); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("smtp STARTTLS failed: %v", err)
+		}
+	}
+
+	return client, nil
+}
+
+func (p *SMTPProvider) auth(client *smtp.Client) error {
+	var auth smtp.Auth
+	switch p.config.Auth {
+	case SMTPAuthNone:
+		return nil
+	case SMTPAuthPlain:
+		auth = smtp.PlainAuth("", p.config.Username, p.config.Password, p.config.Host)
+	case SMTPAuthLogin:
+		auth = &loginAuth{username: p.config.Username, password: p.config.Password, host: p.config.Host}
+	case SMTPAuthCRAMMD5:
+		auth = smtp.CRAMMD5Auth(p.config.Username, p.config.Password)
+	case SMTPAuthXOAUTH2:
+		if p.tokens == nil {
+			return errors.New("smtp auth xoauth2 has no access token, set --token-command with 'gomailit setup smtp'")
+		}
+		tok, err := p.tokens.Token()
+		if err != nil {
+			return err
+		}
+		auth = &xoauth2Auth{username: p.config.Username, token: tok.AccessToken, host: p.config.Host}
+	}
+
+	if ok, _ := client.Extension("AUTH"); !ok {
+		return errors.New("smtp server does not support authentication")
+	}
+	if err := client.Auth(auth); err != nil {
+		return fmt.Errorf("smtp authentication failed: %v", err)
+	}
+	return nil
+}
+
+// loginAuth implements the non-standard but widely deployed AUTH LOGIN
+// mechanism, which net/smtp does not provide.
+type loginAuth struct {
+	username, password, host string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	// Same rule as smtp.PlainAuth: never send credentials in the clear
+	// unless talking to localhost.
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("unencrypted connection")
+	}
+	if server.Name != a.host {
+		return "", nil, errors.New("wrong host name")
+	}
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	switch strings.ToLower(strings.TrimSpace(string(fromServer))) {
+	case "username:", "user name", "username":
+		return []byte(a.username), nil
+	case "password:", "password":
+		return []byte(a.password), nil
+	default:
+		return nil, fmt.Errorf("unexpected server challenge: %s", fromServer)
+	}
+}
+
+func isLocalhost(name string) bool {
+	return name == "localhost" || name == "127.0.0.1" || name == "::1"
+}
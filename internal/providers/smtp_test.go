@@ -0,0 +1,398 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package providers
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"net"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/latocchi/gomailit/internal/mail"
+	"github.com/latocchi/gomailit/internal/mime"
+	"github.com/latocchi/gomailit/internal/testutil"
+	"github.com/latocchi/gomailit/internal/utils"
+	"github.com/zalando/go-keyring"
+)
+
+// smtpStandIn is a minimal SMTP server that records what it receives.
+type smtpStandIn struct {
+	addr        string
+	tlsConfig   *tls.Config
+	implicitTLS bool
+	username    string
+	password    string
+
+	mu   sync.Mutex
+	mech string
+	tls  bool
+	from string
+	rcpt []string
+	data string
+	// continuation is the client's answer to a failed XOAUTH2 login.
+	continuation *string
+}
+
+func newSMTPStandIn(t *testing.T, tlsConfig *tls.Config, implicitTLS bool) *smtpStandIn {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	s := &smtpStandIn{addr: ln.Addr().String(), tlsConfig: tlsConfig, implicitTLS: implicitTLS, username: "alice", password: "secret"}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+// config returns settings for connecting to the stand-in.
+func (s *smtpStandIn) config(security, auth string) *SMTPConfig {
+	host, port, _ := net.SplitHostPort(s.addr)
+	config := &SMTPConfig{Host: host, Security: security, Auth: auth, Username: s.username, Password: s.password}
+	config.Port, _ = strconv.Atoi(port)
+	return config
+}
+
+func (s *smtpStandIn) serve(conn net.Conn) {
+	defer conn.Close()
+	secure := false
+	if s.implicitTLS {
+		conn = tls.Server(conn, s.tlsConfig)
+		secure = true
+	}
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 stand-in ready")
+
+	authed := false
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			text.PrintfLine("250-stand-in")
+			if !secure && s.tlsConfig != nil {
+				text.PrintfLine("250-STARTTLS")
+			}
+			text.PrintfLine("250 AUTH PLAIN LOGIN CRAM-MD5 XOAUTH2")
+		case "STARTTLS":
+			text.PrintfLine("220 go ahead")
+			conn = tls.Server(conn, s.tlsConfig)
+			text = textproto.NewConn(conn)
+			secure = true
+		case "AUTH":
+			mech, initial, _ := strings.Cut(arg, " ")
+			if s.authenticate(text, strings.ToUpper(mech), initial) {
+				authed = true
+				s.mu.Lock()
+				s.mech = strings.ToUpper(mech)
+				s.mu.Unlock()
+				text.PrintfLine("235 authenticated")
+			} else {
+				text.PrintfLine("535 invalid credentials")
+			}
+		case "MAIL":
+			if !authed {
+				text.PrintfLine("530 authentication required")
+				continue
+			}
+			s.mu.Lock()
+			s.tls = secure
+			s.from = strings.Trim(strings.TrimPrefix(arg, "FROM:"), "<>")
+			s.mu.Unlock()
+			text.PrintfLine("250 ok")
+		case "RCPT":
+			s.mu.Lock()
+			s.rcpt = append(s.rcpt, strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>"))
+			s.mu.Unlock()
+			text.PrintfLine("250 ok")
+		case "DATA":
+			text.PrintfLine("354 go ahead")
+			data, err := readDot(text.R)
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.data = data
+			s.mu.Unlock()
+			text.PrintfLine("250 queued")
+		case "QUIT":
+			text.PrintfLine("221 bye")
+			return
+		default:
+			text.PrintfLine("250 ok")
+		}
+	}
+}
+
+func readDot(r *bufio.Reader) (string, error) {
+	var b strings.Builder
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		if line == ".\r\n" {
+			return b.String(), nil
+		}
+		b.WriteString(strings.TrimPrefix(line, "."))
+	}
+}
+
+func (s *smtpStandIn) authenticate(text *textproto.Conn, mech, initial string) bool {
+	challenge := func(c string) string {
+		text.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte(c)))
+		line, _ := text.ReadLine()
+		data, _ := base64.StdEncoding.DecodeString(line)
+		return string(data)
+	}
+	switch mech {
+	case "PLAIN":
+		data, _ := base64.StdEncoding.DecodeString(initial)
+		return string(data) == "\x00"+s.username+"\x00"+s.password
+	case "LOGIN":
+		return challenge("Username:") == s.username && challenge("Password:") == s.password
+	case "CRAM-MD5":
+		nonce := "<1896.697170952@stand-in>"
+		user, digest, _ := strings.Cut(challenge(nonce), " ")
+		h := hmac.New(md5.New, []byte(s.password))
+		h.Write([]byte(nonce))
+		return user == s.username && digest == hex.EncodeToString(h.Sum(nil))
+	case "XOAUTH2":
+		// The password is the access token.
+		data, _ := base64.StdEncoding.DecodeString(initial)
+		if string(data) == "user="+s.username+"\x01auth=Bearer "+s.password+"\x01\x01" {
+			return true
+		}
+		// Like Gmail, describe the failure in a JSON challenge and wait
+		// for the client to acknowledge it.
+		text.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte(`{"status":"401","schemes":"bearer","scope":"https://mail.google.com/"}`)))
+		line, _ := text.ReadLine()
+		s.mu.Lock()
+		s.continuation = &line
+		s.mu.Unlock()
+	}
+	return false
+}
+
+func testMessage() *mail.Message {
+	return &mail.Message{
+		From:     "alice@example.com",
+		To:       []string{"bob@example.com"},
+		Cc:       []string{"carol@example.com"},
+		Bcc:      []string{"audit@example.com"},
+		Subject:  "Hello",
+		TextBody: "Hi Bob",
+	}
+}
+
+func TestSMTPSend(t *testing.T) {
+	serverTLS, clientTLS := testutil.TLS(t, "127.0.0.1")
+
+	tests := []struct {
+		name     string
+		implicit bool
+		security string
+		auth     string
+		mech     string
+	}{
+		{"starttls plain", false, SMTPSecurityStartTLS, SMTPAuthPlain, "PLAIN"},
+		{"starttls login", false, SMTPSecurityStartTLS, SMTPAuthLogin, "LOGIN"},
+		{"starttls cram-md5", false, SMTPSecurityStartTLS, SMTPAuthCRAMMD5, "CRAM-MD5"},
+		{"implicit tls plain", true, SMTPSecurityTLS, SMTPAuthPlain, "PLAIN"},
+		{"implicit tls login", true, SMTPSecurityTLS, SMTPAuthLogin, "LOGIN"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newSMTPStandIn(t, serverTLS, tt.implicit)
+			config := server.config(tt.security, tt.auth)
+			if err := config.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			p := NewSMTPProvider(config)
+			p.tlsConfig = clientTLS
+
+			id, err := p.Send(context.Background(), testMessage())
+			if err != nil {
+				t.Fatalf("Send: %v", err)
+			}
+			if id == "" {
+				t.Error("Send returned no message ID")
+			}
+
+			server.mu.Lock()
+			defer server.mu.Unlock()
+			if !server.tls {
+				t.Error("message was sent without TLS")
+			}
+			if server.mech != tt.mech {
+				t.Errorf("auth mechanism = %q, want %q", server.mech, tt.mech)
+			}
+			if server.from != "alice@example.com" {
+				t.Errorf("MAIL FROM = %q", server.from)
+			}
+			if !strings.Contains(server.data, "Message-ID: "+id) {
+				t.Errorf("message lacks Message-ID %s:\n%s", id, server.data)
+			}
+		})
+	}
+}
+
+func TestSMTPWrongPassword(t *testing.T) {
+	serverTLS, clientTLS := testutil.TLS(t, "127.0.0.1")
+	server := newSMTPStandIn(t, serverTLS, false)
+	config := server.config(SMTPSecurityStartTLS, SMTPAuthPlain)
+	config.Password = "wrong"
+	config.Validate()
+	p := NewSMTPProvider(config)
+	p.tlsConfig = clientTLS
+
+	if _, err := p.Send(context.Background(), testMessage()); err == nil || !strings.Contains(err.Error(), "authentication failed") {
+		t.Fatalf("Send with a wrong password: %v", err)
+	}
+}
+
+func TestSMTPStartTLSRequired(t *testing.T) {
+	server := newSMTPStandIn(t, nil, false)
+	config := server.config(SMTPSecurityStartTLS, SMTPAuthPlain)
+	config.Validate()
+
+	_, err := NewSMTPProvider(config).Send(context.Background(), testMessage())
+	if err == nil || !strings.Contains(err.Error(), "does not support STARTTLS") {
+		t.Fatalf("Send to a server without STARTTLS: %v", err)
+	}
+}
+
+func TestSMTPBccOnlyInEnvelope(t *testing.T) {
+	serverTLS, clientTLS := testutil.TLS(t, "127.0.0.1")
+
+	send := map[string]func(p *SMTPProvider) error{
+		"Send": func(p *SMTPProvider) error {
+			_, err := p.Send(context.Background(), testMessage())
+			return err
+		},
+		"SendRaw": func(p *SMTPProvider) error {
+			builder := &mime.Builder{IncludeBcc: true}
+			raw, err := builder.Build(testMessage())
+			if err != nil {
+				return err
+			}
+			env, err := mime.ParseEnvelope(raw)
+			if err != nil {
+				return err
+			}
+			_, err = p.SendRaw(context.Background(), env, raw)
+			return err
+		},
+	}
+	for name, send := range send {
+		t.Run(name, func(t *testing.T) {
+			server := newSMTPStandIn(t, serverTLS, false)
+			config := server.config(SMTPSecurityStartTLS, SMTPAuthPlain)
+			config.Validate()
+			p := NewSMTPProvider(config)
+			p.tlsConfig = clientTLS
+
+			if err := send(p); err != nil {
+				t.Fatal(err)
+			}
+
+			server.mu.Lock()
+			defer server.mu.Unlock()
+			want := []string{"bob@example.com", "carol@example.com", "audit@example.com"}
+			if strings.Join(server.rcpt, ",") != strings.Join(want, ",") {
+				t.Errorf("RCPT TO = %v, want %v", server.rcpt, want)
+			}
+			if strings.Contains(server.data, "audit@example.com") || strings.Contains(strings.ToLower(server.data), "\nbcc:") {
+				t.Errorf("Bcc recipient leaked into the message:\n%s", server.data)
+			}
+		})
+	}
+}
+
+// useTempConfigDir points the gomailit config directory at a temporary
+// directory and the OS keyring at an in-memory mock.
+func useTempConfigDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+	keyring.MockInit()
+	utils.SetProfile("")
+	return filepath.Join(dir, "gomailit")
+}
+
+func TestSMTPPasswordInTokenStore(t *testing.T) {
+	useTempConfigDir(t)
+
+	config := &SMTPConfig{Host: "smtp.example.com", Username: "alice", Password: "secret"}
+	if err := SetupSMTP(config); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(utils.SMTPConfigPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "secret") {
+		t.Errorf("smtp.json holds the password:\n%s", data)
+	}
+
+	loaded, err := LoadSMTPConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Password != "secret" {
+		t.Errorf("loaded password = %q, want the saved one", loaded.Password)
+	}
+}
+
+func TestSMTPLegacyPasswordMoved(t *testing.T) {
+	useTempConfigDir(t)
+
+	legacy := `{"host": "smtp.example.com", "port": 587, "username": "alice", "password": "secret", "security": "starttls", "auth": "plain"}`
+	if err := os.MkdirAll(filepath.Dir(utils.SMTPConfigPath()), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(utils.SMTPConfigPath(), []byte(legacy), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadSMTPConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Password != "secret" {
+		t.Errorf("loaded password = %q, want secret", loaded.Password)
+	}
+	data, _ := os.ReadFile(utils.SMTPConfigPath())
+	if strings.Contains(string(data), "secret") {
+		t.Errorf("password was left in smtp.json:\n%s", data)
+	}
+	tok, err := smtpPassword.load()
+	if err != nil || tok.AccessToken != "secret" {
+		t.Errorf("token store holds %v, %v", tok, err)
+	}
+}
@@ -0,0 +1,225 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/latocchi/gomailit/internal/tokenstore"
+	"github.com/latocchi/gomailit/internal/utils"
+	"golang.org/x/oauth2"
+)
+
+// keyringService is the service name tokens are stored under in the OS
+// keyring.
+const keyringService = "gomailit"
+
+// oauthToken keeps the OAuth token of one provider for the selected
+// profile, in the store chosen with 'setup <provider> --token-store'.
+type oauthToken struct {
+	provider string
+}
+
+// newStore returns the store of the given kind for the token.
+func (t *oauthToken) newStore(kind tokenstore.Kind) tokenstore.Store {
+	switch kind {
+	case tokenstore.File:
+		return tokenstore.NewFile(utils.TokenPath(t.provider))
+	case tokenstore.EncryptedFile:
+		return tokenstore.NewEncryptedFile(utils.EncryptedTokenPath(t.provider))
+	default:
+		// Every profile has its own entry in the keyring.
+		user := t.provider
+		if profile := utils.Profile(); profile != "default" {
+			user += ":" + profile
+		}
+		return tokenstore.NewKeyring(keyringService, user)
+	}
+}
+
+// store returns the configured store, the OS keyring by default.
+func (t *oauthToken) store() tokenstore.Store {
+	data, err := os.ReadFile(utils.TokenStorePath(t.provider))
+	if err != nil {
+		return t.newStore(tokenstore.Keyring)
+	}
+	kind, err := tokenstore.ParseKind(strings.TrimSpace(string(data)))
+	if err != nil {
+		return t.newStore(tokenstore.Keyring)
+	}
+	return t.newStore(kind)
+}
+
+func (t *oauthToken) saveStoreKind(kind tokenstore.Kind) error {
+	if err := os.WriteFile(utils.TokenStorePath(t.provider), []byte(string(kind)+"\n"), 0600); err != nil {
+		return fmt.Errorf("unable to save token store: %v", err)
+	}
+	return nil
+}
+
+// switchStore records kind as the token store and moves the current token
+// into it.
+func (t *oauthToken) switchStore(kind tokenstore.Kind) error {
+	current := t.store()
+	if current.Kind() == kind {
+		return t.saveStoreKind(kind)
+	}
+
+	next := t.newStore(kind)
+	if _, err := current.Load(); err != nil && t.keyringUnavailable(err) {
+		// Nothing can be moved out of a keyring that cannot be reached.
+		return t.saveStoreKind(kind)
+	}
+	moved, err := tokenstore.Migrate(current, next)
+	if err != nil {
+		return fmt.Errorf("unable to move token from %s to %s: %v", current.Location(), next.Location(), err)
+	}
+	if moved {
+		fmt.Printf("Moved token from %s to %s\n", current.Location(), next.Location())
+	}
+	return t.saveStoreKind(kind)
+}
+
+// keyringUnavailable reports whether err comes from the OS keyring being
+// unreachable rather than from a missing token.
+func (t *oauthToken) keyringUnavailable(err error) bool {
+	return t.store().Kind() == tokenstore.Keyring && !errors.Is(err, tokenstore.ErrNotFound)
+}
+
+// delete removes the token from every store. An unavailable keyring is
+// only an error when it is the configured store.
+func (t *oauthToken) delete() error {
+	configured := t.store().Kind()
+	for _, kind := range tokenstore.Kinds {
+		err := t.newStore(kind).Delete()
+		if err == nil || errors.Is(err, tokenstore.ErrNotFound) {
+			continue
+		}
+		if kind == tokenstore.Keyring && configured != tokenstore.Keyring {
+			continue
+		}
+		return err
+	}
+	return nil
+}
+
+// storedTokens lists the credentials kept through oauthToken.
+var storedTokens = []*oauthToken{googleToken, outlookToken, smtpPassword, jmapToken, mailgunKey, postmarkToken, sendgridKey, sesSecret}
+
+// DeleteTokens removes the tokens and passwords of every provider for the
+// selected profile from every store, so that none is left in the OS keyring
+// once the profile is gone.
+func DeleteTokens() error {
+	var errs []error
+	for _, token := range storedTokens {
+		if err := token.delete(); err != nil {
+			errs = append(errs, fmt.Errorf("unable to delete %s token: %v", token.provider, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// load returns the saved token. A token file left by an older version is
+// moved into the configured store the first time it is read.
+func (t *oauthToken) load() (*oauth2.Token, error) {
+	store := t.store()
+	tok, err := store.Load()
+	if !errors.Is(err, tokenstore.ErrNotFound) || store.Kind() == tokenstore.File {
+		return tok, err
+	}
+
+	legacy := t.newStore(tokenstore.File)
+	tok, legacyErr := legacy.Load()
+	if legacyErr != nil {
+		return nil, err
+	}
+	if err := t.save(tok); err != nil {
+		return nil, err
+	}
+	if err := legacy.Delete(); err != nil {
+		return nil, fmt.Errorf("unable to remove %s after moving the token: %v", legacy.Location(), err)
+	}
+	fmt.Fprintf(os.Stderr, "Moved token from %s to %s\n", legacy.Location(), t.store().Location())
+	return tok, nil
+}
+
+// save saves the token to the configured store. When the OS keyring is
+// unavailable, for example on a server without a Secret Service, it falls
+// back to an encrypted file.
+func (t *oauthToken) save(token *oauth2.Token) error {
+	store := t.store()
+	err := store.Save(token)
+	if err != nil && store.Kind() == tokenstore.Keyring {
+		fallback := t.newStore(tokenstore.EncryptedFile)
+		fmt.Fprintf(os.Stderr, "OS keyring unavailable (%v), using %s instead\n", err, fallback.Location())
+		if err := fallback.Save(token); err != nil {
+			return err
+		}
+		return t.saveStoreKind(tokenstore.EncryptedFile)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Saved token to %s\n", store.Location())
+	return nil
+}
+
+// authorize returns the saved token, running the authorization flow when
+// there is none.
+func (t *oauthToken) authorize(ctx context.Context, config *oauth2.Config, auth webAuth) (*oauth2.Token, error) {
+	tok, err := t.load()
+	if err != nil && t.keyringUnavailable(err) {
+		// There is no token in a keyring that cannot be reached, and save
+		// falls back to an encrypted file.
+		err = tokenstore.ErrNotFound
+	}
+	if !errors.Is(err, tokenstore.ErrNotFound) {
+		return tok, err
+	}
+	tok, err = getTokenFromWeb(ctx, config, auth)
+	if err != nil {
+		return nil, err
+	}
+	return tok, t.save(tok)
+}
+
+// tokenSource returns a source that refreshes tok with config and saves
+// every refreshed token.
+func (t *oauthToken) tokenSource(config *oauth2.Config, tok *oauth2.Token) oauth2.TokenSource {
+	return &persistingTokenSource{tokens: t, src: config.TokenSource(context.Background(), tok), last: tok}
+}
+
+// persistingTokenSource saves every token its source hands out that differs
+// from the previous one. config.Client alone refreshes in memory only, which
+// would leave an expired access token in the store.
+type persistingTokenSource struct {
+	mu     sync.Mutex
+	tokens *oauthToken
+	src    oauth2.TokenSource
+	last   *oauth2.Token
+}
+
+func (s *persistingTokenSource) Token() (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tok, err := s.src.Token()
+	if err != nil {
+		return nil, err
+	}
+	if s.last == nil || tok.AccessToken != s.last.AccessToken {
+		if err := s.tokens.store().Save(tok); err != nil {
+			// The token still works for this run, it is only refreshed
+			// again next time.
+			fmt.Fprintf(os.Stderr, "Warning: unable to save refreshed token: %v\n", err)
+		}
+		s.last = tok
+	}
+	return tok, nil
+}
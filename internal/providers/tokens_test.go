@@ -0,0 +1,53 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package providers
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/latocchi/gomailit/internal/tokenstore"
+	"github.com/latocchi/gomailit/internal/utils"
+	"golang.org/x/oauth2"
+)
+
+func TestDeleteTokens(t *testing.T) {
+	useTempConfigDir(t)
+	t.Cleanup(func() { utils.SetProfile("") })
+
+	// Each profile keeps a token for every provider, one of them in a file.
+	for _, profile := range []string{"work", "home"} {
+		utils.SetProfile(profile)
+		if err := smtpPassword.switchStore(tokenstore.EncryptedFile); err != nil {
+			t.Fatal(err)
+		}
+		for _, token := range storedTokens {
+			if err := token.save(&oauth2.Token{AccessToken: profile + "-" + token.provider}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	utils.SetProfile("work")
+	if err := DeleteTokens(); err != nil {
+		t.Fatal(err)
+	}
+	for _, token := range storedTokens {
+		for _, kind := range tokenstore.Kinds {
+			if _, err := token.newStore(kind).Load(); !errors.Is(err, tokenstore.ErrNotFound) {
+				t.Errorf("%s token of the deleted profile left in the %s store: %v", token.provider, kind, err)
+			}
+		}
+	}
+
+	utils.SetProfile("home")
+	for _, token := range storedTokens {
+		tok, err := token.load()
+		if err != nil {
+			t.Errorf("%s token of another profile: %v", token.provider, err)
+		} else if tok.AccessToken != "home-"+token.provider {
+			t.Errorf("%s token of another profile = %q", token.provider, tok.AccessToken)
+		}
+	}
+}
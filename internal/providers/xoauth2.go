@@ -0,0 +1,69 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package providers
+
+import (
+	"errors"
+	"fmt"
+	"net/smtp"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+// xoauth2Auth implements the SASL XOAUTH2 mechanism used by Gmail,
+// Outlook.com and other servers that accept OAuth2 access tokens instead
+// of passwords.
+type xoauth2Auth struct {
+	username, token, host string
+}
+
+func (a *xoauth2Auth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	// The token is as good as a password, so the same rules apply.
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("unencrypted connection")
+	}
+	if server.Name != a.host {
+		return "", nil, errors.New("wrong host name")
+	}
+	return "XOAUTH2", []byte("user=" + a.username + "\x01auth=Bearer " + a.token + "\x01\x01"), nil
+}
+
+func (a *xoauth2Auth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	// The server sends a JSON description of the failure as a challenge
+	// and expects an empty response, after which it rejects the login.
+	return []byte{}, nil
+}
+
+// commandTokenSource gets access tokens from a command that prints one,
+// like msmtp's passwordeval. It lets any XOAUTH2 server be used with a
+// token helper that manages the OAuth flow for that server.
+type commandTokenSource struct {
+	command string
+}
+
+func (s commandTokenSource) Token() (*oauth2.Token, error) {
+	var c *exec.Cmd
+	if runtime.GOOS == "windows" {
+		c = exec.Command("cmd", "/C", s.command)
+	} else {
+		c = exec.Command("sh", "-c", s.command)
+	}
+	c.Stderr = os.Stderr
+	out, err := c.Output()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get access token from %q: %v", s.command, err)
+	}
+	token := strings.TrimSpace(string(out))
+	if token == "" {
+		return nil, fmt.Errorf("unable to get access token: %q printed nothing", s.command)
+	}
+	return &oauth2.Token{AccessToken: token, TokenType: "Bearer"}, nil
+}
@@ -0,0 +1,109 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package providers
+
+import (
+	"context"
+	"net/smtp"
+	"strings"
+	"testing"
+
+	"github.com/latocchi/gomailit/internal/testutil"
+	"golang.org/x/oauth2"
+)
+
+func TestXOAUTH2Send(t *testing.T) {
+	serverTLS, clientTLS := testutil.TLS(t, "127.0.0.1")
+	server := newSMTPStandIn(t, serverTLS, false)
+	config := server.config(SMTPSecurityStartTLS, SMTPAuthXOAUTH2)
+	config.Password = ""
+	config.TokenCommand = "echo secret"
+	if err := config.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	p := NewSMTPProvider(config)
+	p.tlsConfig = clientTLS
+	p.tokens = commandTokenSource{config.TokenCommand}
+
+	if _, err := p.Send(context.Background(), testMessage()); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.mech != "XOAUTH2" {
+		t.Errorf("auth mechanism = %q, want XOAUTH2", server.mech)
+	}
+	if server.continuation != nil {
+		t.Errorf("login challenged with %q although the token was valid", *server.continuation)
+	}
+}
+
+func TestXOAUTH2Rejected(t *testing.T) {
+	serverTLS, clientTLS := testutil.TLS(t, "127.0.0.1")
+	server := newSMTPStandIn(t, serverTLS, false)
+	config := server.config(SMTPSecurityStartTLS, SMTPAuthXOAUTH2)
+	config.Validate()
+	p := NewSMTPProvider(config)
+	p.tlsConfig = clientTLS
+	p.tokens = oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "expired"})
+
+	_, err := p.Send(context.Background(), testMessage())
+	if err == nil || !strings.Contains(err.Error(), "authentication failed") || !strings.Contains(err.Error(), "535") {
+		t.Fatalf("Send with an expired token: %v", err)
+	}
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.continuation == nil || *server.continuation != "" {
+		t.Errorf("JSON error challenge answered with %v, want an empty response", server.continuation)
+	}
+}
+
+func TestXOAUTH2Start(t *testing.T) {
+	auth := &xoauth2Auth{username: "alice@example.com", token: "secret", host: "smtp.example.com"}
+	tests := []struct {
+		name    string
+		server  smtp.ServerInfo
+		wantErr string
+	}{
+		{"tls", smtp.ServerInfo{Name: "smtp.example.com", TLS: true}, ""},
+		{"unencrypted", smtp.ServerInfo{Name: "smtp.example.com"}, "unencrypted connection"},
+		{"wrong host", smtp.ServerInfo{Name: "smtp.example.org", TLS: true}, "wrong host name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mech, resp, err := auth.Start(&tt.server)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if mech != "XOAUTH2" || string(resp) != "user=alice@example.com\x01auth=Bearer secret\x01\x01" {
+				t.Errorf("Start = %q, %q", mech, resp)
+			}
+		})
+	}
+}
+
+func TestCommandTokenSource(t *testing.T) {
+	tok, err := commandTokenSource{"echo ' token '"}.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.AccessToken != "token" || tok.TokenType != "Bearer" {
+		t.Errorf("token = %+v", tok)
+	}
+
+	for command, want := range map[string]string{
+		"exit 1": "unable to get access token from",
+		"exit 0": "printed nothing",
+	} {
+		if _, err := (commandTokenSource{command}).Token(); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: error = %v, want one containing %q", command, err, want)
+		}
+	}
+}
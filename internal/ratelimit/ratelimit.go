@@ -0,0 +1,245 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+
+// Package ratelimit keeps sending within the limits of a provider: a token
+// bucket for the messages per second and a count of the messages per day,
+// which is kept on disk so that it carries over between runs.
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/latocchi/gomailit/internal/filelock"
+)
+
+// ErrDailyLimit is returned by Wait once the messages per day are used up.
+var ErrDailyLimit = errors.New("daily sending limit reached")
+
+// Limiter throttles the messages sent through one provider. It is safe for
+// concurrent use.
+type Limiter struct {
+	mu        sync.Mutex
+	perSecond float64
+	burst     float64
+	tokens    float64
+	last      time.Time
+	// notBefore holds every send back after the provider asked to slow
+	// down.
+	notBefore time.Time
+
+	key     string
+	perDay  int
+	counter *Counter
+}
+
+// New returns a limiter allowing perSecond messages per second, in bursts
+// of up to one second's worth, and perDay messages per calendar day (UTC)
+// as counted by counter under key. Zero means unlimited.
+func New(perSecond float64, perDay int, counter *Counter, key string) *Limiter {
+	burst := math.Max(1, math.Ceil(perSecond))
+	return &Limiter{
+		perSecond: perSecond,
+		burst:     burst,
+		tokens:    burst,
+		last:      time.Now(),
+		key:       key,
+		perDay:    perDay,
+		counter:   counter,
+	}
+}
+
+// Wait blocks until a message may be sent and counts it towards the daily
+// limit. Call Done once the send has finished.
+func (l *Limiter) Wait(ctx context.Context) error {
+	// Do not wait for the rate limit only to find the day used up.
+	if l.perDay > 0 {
+		if count, err := l.counter.Get(l.key); err == nil && count >= l.perDay {
+			return fmt.Errorf("%w: %d messages sent through %s today", ErrDailyLimit, count, l.key)
+		}
+	}
+
+	for {
+		wait := l.reserve()
+		if wait == 0 {
+			break
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	if l.perDay > 0 {
+		count, err := l.counter.Add(l.key, 1, l.perDay)
+		if errors.Is(err, ErrDailyLimit) {
+			return fmt.Errorf("%w: %d messages sent through %s today", ErrDailyLimit, count, l.key)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reserve takes a token and returns zero, or returns how long to wait
+// before trying again.
+func (l *Limiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Before(l.notBefore) {
+		return l.notBefore.Sub(now)
+	}
+	if l.perSecond <= 0 {
+		return 0
+	}
+
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.perSecond)
+	l.last = now
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0
+	}
+	return time.Duration((1 - l.tokens) / l.perSecond * float64(time.Second))
+}
+
+// Done records the outcome of a send allowed by Wait. Messages that were
+// not sent do not count towards the daily limit.
+func (l *Limiter) Done(sent bool) {
+	if sent || l.perDay <= 0 {
+		return
+	}
+	// Failing to give the message back only makes the count too high.
+	l.counter.Add(l.key, -1, 0)
+}
+
+// Pause holds back every send for d, for when the provider answers that it
+// is sent too much.
+func (l *Limiter) Pause(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until := time.Now().Add(d); until.After(l.notBefore) {
+		l.notBefore = until
+	}
+}
+
+// Counter counts the messages sent per key and day in a JSON file. Every
+// gomailit process sending through the same profile shares the file, and
+// takes turns updating it through a file lock.
+type Counter struct {
+	mu   sync.Mutex
+	path string
+}
+
+type dayCount struct {
+	Date  string `json:"date"`
+	Count int    `json:"count"`
+}
+
+// NewCounter returns a counter kept in path.
+func NewCounter(path string) *Counter {
+	return &Counter{path: path}
+}
+
+// Get returns today's count of key.
+func (c *Counter) Get(key string) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var counts map[string]dayCount
+	err := filelock.With(c.path, false, func() error {
+		var err error
+		counts, err = c.load()
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return counts[key].Count, nil
+}
+
+// Add adds n to today's count of key and returns the new count. With a
+// positive limit it fails with ErrDailyLimit instead of going over it, and
+// returns the count as it is.
+func (c *Counter) Add(key string, n, limit int) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var count int
+	err := filelock.With(c.path, true, func() error {
+		counts, err := c.load()
+		if err != nil {
+			return err
+		}
+		count = counts[key].Count
+		if limit > 0 && n > 0 && count+n > limit {
+			return ErrDailyLimit
+		}
+		count = max(0, count+n)
+		counts[key] = dayCount{Date: today(), Count: count}
+		return c.save(counts)
+	})
+	return count, err
+}
+
+// load reads the counts, leaving out those of earlier days.
+func (c *Counter) load() (map[string]dayCount, error) {
+	counts := map[string]dayCount{}
+	data, err := os.ReadFile(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return counts, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read send count: %v", err)
+	}
+	if err := json.Unmarshal(data, &counts); err != nil {
+		return nil, fmt.Errorf("unable to parse send count %s: %v", c.path, err)
+	}
+	for key, count := range counts {
+		if count.Date != today() {
+			delete(counts, key)
+		}
+	}
+	return counts, nil
+}
+
+func (c *Counter) save(counts map[string]dayCount) error {
+	data, err := json.MarshalIndent(counts, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to encode send count: %v", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), "sendcount.*.tmp")
+	if err != nil {
+		return fmt.Errorf("unable to save send count: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("unable to save send count: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("unable to save send count: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return fmt.Errorf("unable to save send count: %v", err)
+	}
+	return nil
+}
+
+func today() string {
+	return time.Now().UTC().Format(time.DateOnly)
+}
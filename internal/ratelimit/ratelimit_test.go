@@ -0,0 +1,241 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	tests := []struct {
+		name      string
+		perSecond float64
+		// free is how many messages go out at once before the limiter
+		// makes the next one wait.
+		free int
+	}{
+		{"unlimited", 0, 100},
+		{"one per second", 1, 1},
+		{"burst of one second", 10, 10},
+		{"fraction rounds burst up", 2.5, 3},
+		{"below one per second", 0.5, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := New(tt.perSecond, 0, nil, "smtp")
+			for i := range tt.free {
+				if wait := l.reserve(); wait != 0 {
+					t.Fatalf("message %d waits %v", i+1, wait)
+				}
+			}
+			if tt.perSecond == 0 {
+				return
+			}
+			wait := l.reserve()
+			if wait <= 0 || wait > time.Duration(float64(time.Second)/tt.perSecond) {
+				t.Errorf("next message waits %v, want up to %v", wait, time.Duration(float64(time.Second)/tt.perSecond))
+			}
+		})
+	}
+}
+
+func TestTokenBucketRefills(t *testing.T) {
+	l := New(100, 0, nil, "smtp")
+	for range 100 {
+		l.reserve()
+	}
+	if l.reserve() == 0 {
+		t.Fatal("bucket not empty")
+	}
+	// Pretend 50ms passed: five messages' worth of tokens.
+	l.last = l.last.Add(-50 * time.Millisecond)
+	for i := range 4 {
+		if wait := l.reserve(); wait != 0 {
+			t.Fatalf("message %d waits %v after the refill", i+1, wait)
+		}
+	}
+}
+
+func TestWaitHonoursContext(t *testing.T) {
+	l := New(1, 0, nil, "smtp")
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := l.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait = %v, want the context's error", err)
+	}
+}
+
+func TestPause(t *testing.T) {
+	l := New(0, 0, nil, "smtp")
+	l.Pause(time.Hour)
+	if wait := l.reserve(); wait < 59*time.Minute {
+		t.Errorf("paused limiter waits %v", wait)
+	}
+
+	// A shorter pause does not cut a longer one short.
+	l.Pause(time.Second)
+	if wait := l.reserve(); wait < 59*time.Minute {
+		t.Errorf("shorter pause shortened the wait to %v", wait)
+	}
+
+	l = New(0, 0, nil, "smtp")
+	l.Pause(30 * time.Millisecond)
+	start := time.Now()
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 25*time.Millisecond {
+		t.Errorf("Wait returned after %v, before the pause ended", elapsed)
+	}
+}
+
+func TestDailyLimit(t *testing.T) {
+	counter := NewCounter(filepath.Join(t.TempDir(), "send_count.json"))
+	l := New(0, 2, counter, "smtp")
+	ctx := context.Background()
+
+	for i := range 2 {
+		if err := l.Wait(ctx); err != nil {
+			t.Fatalf("message %d: %v", i+1, err)
+		}
+		l.Done(true)
+	}
+	if err := l.Wait(ctx); !errors.Is(err, ErrDailyLimit) {
+		t.Fatalf("third message: %v, want ErrDailyLimit", err)
+	}
+
+	// Another provider has its own count.
+	if err := New(0, 2, counter, "ses").Wait(ctx); err != nil {
+		t.Errorf("other provider: %v", err)
+	}
+}
+
+func TestDoneGivesBackUnsent(t *testing.T) {
+	counter := NewCounter(filepath.Join(t.TempDir(), "send_count.json"))
+	l := New(0, 1, counter, "smtp")
+	ctx := context.Background()
+
+	if err := l.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	l.Done(false)
+	if count, _ := counter.Get("smtp"); count != 0 {
+		t.Fatalf("count after a failed send = %d", count)
+	}
+
+	if err := l.Wait(ctx); err != nil {
+		t.Fatalf("the failed send used up the day: %v", err)
+	}
+	l.Done(true)
+	if count, _ := counter.Get("smtp"); count != 1 {
+		t.Errorf("count = %d, want 1", count)
+	}
+
+	// Giving back never goes below zero.
+	counter.Add("smtp", -5, 0)
+	if count, _ := counter.Get("smtp"); count != 0 {
+		t.Errorf("count = %d, want 0", count)
+	}
+}
+
+func TestCounterDayRollover(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "send_count.json")
+	yesterday := time.Now().UTC().AddDate(0, 0, -1).Format(time.DateOnly)
+	data := `{"smtp":{"date":"` + yesterday + `","count":500},"ses":{"date":"` + today() + `","count":7}}`
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	counter := NewCounter(path)
+	if count, err := counter.Get("smtp"); err != nil || count != 0 {
+		t.Errorf("yesterday's count = %d, %v, want 0", count, err)
+	}
+	if count, err := counter.Add("smtp", 1, 500); err != nil || count != 1 {
+		t.Errorf("first send of the day = %d, %v, want 1", count, err)
+	}
+	if count, _ := counter.Get("ses"); count != 7 {
+		t.Errorf("today's count = %d, want 7", count)
+	}
+}
+
+func TestCounterLimit(t *testing.T) {
+	counter := NewCounter(filepath.Join(t.TempDir(), "send_count.json"))
+	if _, err := counter.Add("smtp", 3, 3); err != nil {
+		t.Fatal(err)
+	}
+	count, err := counter.Add("smtp", 1, 3)
+	if !errors.Is(err, ErrDailyLimit) || count != 3 {
+		t.Errorf("Add over the limit = %d, %v", count, err)
+	}
+}
+
+func TestCounterCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "send_count.json")
+	if err := os.WriteFile(path, []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewCounter(path).Add("smtp", 1, 0); err == nil {
+		t.Error("corrupt count accepted")
+	}
+}
+
+// TestCounterSharedBetweenProcesses counts through separate Counters, the
+// way separate gomailit processes do, so only the file lock keeps them from
+// losing each other's counts.
+func TestCounterSharedBetweenProcesses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "send_count.json")
+	const processes, sends = 8, 25
+
+	var wg sync.WaitGroup
+	for range processes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			counter := NewCounter(path)
+			for range sends {
+				if _, err := counter.Add("smtp", 1, 0); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if count, _ := NewCounter(path).Get("smtp"); count != processes*sends {
+		t.Errorf("count = %d, want %d", count, processes*sends)
+	}
+
+	// With a limit, no process goes over it.
+	path = filepath.Join(t.TempDir(), "send_count.json")
+	var mu sync.Mutex
+	allowed := 0
+	for range processes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l := New(0, 50, NewCounter(path), "smtp")
+			for range sends {
+				if l.Wait(context.Background()) == nil {
+					mu.Lock()
+					allowed++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if allowed != 50 {
+		t.Errorf("%d sends allowed, want 50", allowed)
+	}
+}
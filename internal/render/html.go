@@ -0,0 +1,145 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+
+// Package render turns the body sources accepted by 'gomailit send' (HTML,
+// Markdown, templates) into the text and HTML bodies of a mail.Message.
+package render
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	// skippedElements have content that must never show up in the text.
+	skippedElements = regexp.MustCompile(`(?is)<(head|style|script|title)\b[^>]*>.*?</(head|style|script|title)\s*>`)
+	comments        = regexp.MustCompile(`(?s)<!--.*?-->|<![^>]*>|<\?[^>]*>`)
+	tags            = regexp.MustCompile(`(?s)<(/?)([a-zA-Z][a-zA-Z0-9]*)\b([^>]*)>`)
+	hrefAttr        = regexp.MustCompile(`(?i)\bhref\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s>]+))`)
+	altAttr         = regexp.MustCompile(`(?i)\balt\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s>]+))`)
+	spaces          = regexp.MustCompile(`[ \t\r\n]+`)
+	blankLines      = regexp.MustCompile(`\n{3,}`)
+)
+
+// blockElements start on a new line in the plain-text rendering.
+var blockElements = map[string]bool{
+	"p": true, "div": true, "section": true, "article": true, "header": true, "footer": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"table": true, "tr": true, "blockquote": true, "hr": true,
+}
+
+// hardSpace and hardTab stand for the whitespace of preformatted text and
+// of list indentation until the lines have been trimmed.
+const (
+	hardSpace = "\x01"
+	hardTab   = "\x02"
+)
+
+// HTMLToText renders an HTML document as readable plain text, suitable for
+// the text/plain alternative of an HTML email. Links keep their target in
+// parentheses, list items are bulleted and indented by how deeply they are
+// nested, and preformatted text keeps its whitespace.
+func HTMLToText(document string) string {
+	document = skippedElements.ReplaceAllString(document, "")
+	document = comments.ReplaceAllString(document, "")
+
+	var out strings.Builder
+	var href []string
+	lists, pre := 0, 0
+	last := 0
+	text := func(segment string) {
+		if pre == 0 {
+			out.WriteString(collapse(segment))
+			return
+		}
+		segment = strings.ReplaceAll(html.UnescapeString(segment), "\r\n", "\n")
+		segment = strings.ReplaceAll(segment, " ", hardSpace)
+		out.WriteString(strings.ReplaceAll(segment, "\t", hardTab))
+	}
+	for _, loc := range tags.FindAllStringSubmatchIndex(document, -1) {
+		text(document[last:loc[0]])
+		last = loc[1]
+
+		closing := document[loc[2]:loc[3]] == "/"
+		name := strings.ToLower(document[loc[4]:loc[5]])
+		attrs := document[loc[6]:loc[7]]
+
+		switch {
+		case name == "br":
+			out.WriteString("\n")
+		case name == "li" && !closing:
+			out.WriteString("\n" + strings.Repeat(hardSpace+hardSpace, max(0, lists-1)) + "* ")
+		case name == "ul" || name == "ol":
+			if closing {
+				lists = max(0, lists-1)
+			} else {
+				lists++
+			}
+			// A nested list continues its item.
+			if lists == 0 || lists == 1 && !closing {
+				out.WriteString("\n\n")
+			}
+		case name == "pre":
+			out.WriteString("\n\n")
+			if closing {
+				pre = max(0, pre-1)
+				break
+			}
+			pre++
+			// Like browsers, ignore a line break right after <pre>.
+			if strings.HasPrefix(document[last:], "\r\n") {
+				last += 2
+			} else if strings.HasPrefix(document[last:], "\n") {
+				last++
+			}
+		case name == "td" || name == "th":
+			if closing {
+				out.WriteString("\t")
+			}
+		case name == "a" && !closing:
+			href = append(href, attr(hrefAttr, attrs))
+		case name == "a" && closing && len(href) > 0:
+			target := href[len(href)-1]
+			href = href[:len(href)-1]
+			if target != "" && !strings.HasPrefix(target, "#") && !strings.HasPrefix(target, "mailto:") {
+				out.WriteString(" (" + html.UnescapeString(target) + ")")
+			}
+		case name == "img":
+			if alt := attr(altAttr, attrs); alt != "" {
+				out.WriteString("[" + html.UnescapeString(alt) + "]")
+			}
+		case blockElements[name]:
+			out.WriteString("\n\n")
+		}
+	}
+	text(document[last:])
+
+	lines := strings.Split(out.String(), "\n")
+	for i, line := range lines {
+		line = strings.ReplaceAll(strings.TrimSpace(line), hardSpace, " ")
+		lines[i] = strings.ReplaceAll(line, hardTab, "\t")
+	}
+	result := blankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+	return strings.Trim(result, "\n") + "\n"
+}
+
+// collapse folds runs of whitespace the way a browser would and decodes
+// character references.
+func collapse(text string) string {
+	return html.UnescapeString(spaces.ReplaceAllString(text, " "))
+}
+
+func attr(re *regexp.Regexp, attrs string) string {
+	m := re.FindStringSubmatch(attrs)
+	if m == nil {
+		return ""
+	}
+	for _, value := range m[1:] {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
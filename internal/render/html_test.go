@@ -0,0 +1,74 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package render
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// checkGolden compares got with the golden file, or rewrites it with
+// -update.
+func checkGolden(t *testing.T, golden, got string) {
+	t.Helper()
+	if *update {
+		if err := os.WriteFile(golden, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("%v (run go test -update to create it)", err)
+	}
+	if got != string(want) {
+		t.Errorf("output differs from %s:\n%s", golden, got)
+	}
+}
+
+// TestHTMLToText renders every testdata/text/*.html document and compares
+// it with the .txt file next to it.
+func TestHTMLToText(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "text", "*.html"))
+	if err != nil || len(inputs) == 0 {
+		t.Fatalf("no test documents: %v", err)
+	}
+	for _, input := range inputs {
+		name := strings.TrimSuffix(filepath.Base(input), ".html")
+		t.Run(name, func(t *testing.T) {
+			document, err := os.ReadFile(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, strings.TrimSuffix(input, ".html")+".txt", HTMLToText(string(document)))
+		})
+	}
+}
+
+func TestHTMLToTextFragments(t *testing.T) {
+	tests := []struct {
+		document string
+		want     string
+	}{
+		{"", "\n"},
+		{"plain text", "plain text\n"},
+		{"<p>one</p><p>two</p>", "one\n\ntwo\n"},
+		{"a<br>b<br/>c", "a\nb\nc\n"},
+		{"<p>a</p>\n\n\n\n<p>b</p>", "a\n\nb\n"},
+		{`<a href="https://example.com">https://example.com</a>`, "https://example.com (https://example.com)\n"},
+		{"<pre>\n  indented\n\ttabbed</pre>", "  indented\n\ttabbed\n"},
+		{"<ul><li>a<ul><li>b<ul><li>c</li></ul></li></ul></li><li>d</li></ul>", "* a\n  * b\n    * c\n* d\n"},
+		{"<STYLE>.x{}</STYLE><Script type=x>y</Script>text", "text\n"},
+	}
+	for _, tt := range tests {
+		if got := HTMLToText(tt.document); got != tt.want {
+			t.Errorf("HTMLToText(%q) = %q, want %q", tt.document, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package render
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/latocchi/gomailit/internal/mail"
+)
+
+var imgSrc = regexp.MustCompile(`(?is)(<img\b[^>]*?\bsrc\s*=\s*)(?:"([^"]*)"|'([^']*)')`)
+
+// EmbedImages finds <img> elements that reference local files, relative to
+// baseDir unless absolute, and rewrites them to cid: URLs. It returns the
+// rewritten HTML and the images as inline attachments. Remote, data: and
+// cid: URLs are left untouched, and each file is embedded only once.
+func EmbedImages(document, baseDir string) (string, []mail.Attachment, error) {
+	var images []mail.Attachment
+	contentIDs := map[string]string{}
+
+	var err error
+	document = imgSrc.ReplaceAllStringFunc(document, func(m string) string {
+		if err != nil {
+			return m
+		}
+
+		parts := imgSrc.FindStringSubmatch(m)
+		src := html.UnescapeString(parts[2] + parts[3])
+		if !isLocalImage(src) {
+			return m
+		}
+
+		path := filepath.FromSlash(strings.TrimPrefix(src, "file://"))
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(baseDir, path)
+		}
+
+		id, ok := contentIDs[path]
+		if !ok {
+			var image mail.Attachment
+			image, err = mail.LoadAttachment(path)
+			if err != nil {
+				err = fmt.Errorf("unable to embed image: %v", err)
+				return m
+			}
+			id = fmt.Sprintf("image%d@gomailit", len(images)+1)
+			image.ContentID = id
+			images = append(images, image)
+			contentIDs[path] = id
+		}
+		return parts[1] + `"cid:` + id + `"`
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return document, images, nil
+}
+
+func isLocalImage(src string) bool {
+	if src == "" {
+		return false
+	}
+	u, err := url.Parse(src)
+	if err != nil {
+		return true
+	}
+	return u.Scheme == "" || u.Scheme == "file" || len(u.Scheme) == 1 // Windows drive letter
+}
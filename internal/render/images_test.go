@@ -0,0 +1,94 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package render
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeImage(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEmbedImages(t *testing.T) {
+	dir := t.TempDir()
+	writeImage(t, filepath.Join(dir, "images", "logo.png"), "logo")
+	writeImage(t, filepath.Join(dir, "a&b.gif"), "ampersand")
+	absolute := filepath.Join(t.TempDir(), "banner.jpg")
+	writeImage(t, absolute, "banner")
+
+	document := `<p><img src="images/logo.png" alt="Logo"></p>
+<p><IMG class="x" SRC='images/logo.png'></p>
+<p><img src="a&amp;b.gif"></p>
+<p><img alt="Banner" src="file://` + filepath.ToSlash(absolute) + `"></p>
+<p><img src="https://example.com/remote.png"></p>
+<p><img src="data:image/png;base64,iVBORw0KGgo="></p>
+<p><img src="cid:existing@example.com"></p>`
+
+	got, images, err := EmbedImages(document, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `<p><img src="cid:image1@gomailit" alt="Logo"></p>
+<p><IMG class="x" SRC="cid:image1@gomailit"></p>
+<p><img src="cid:image2@gomailit"></p>
+<p><img alt="Banner" src="cid:image3@gomailit"></p>
+<p><img src="https://example.com/remote.png"></p>
+<p><img src="data:image/png;base64,iVBORw0KGgo="></p>
+<p><img src="cid:existing@example.com"></p>`
+	if got != want {
+		t.Errorf("document =\n%s\nwant\n%s", got, want)
+	}
+
+	// The logo is used twice but attached once.
+	wantImages := []struct{ name, id, data string }{
+		{"logo.png", "image1@gomailit", "logo"},
+		{"a&b.gif", "image2@gomailit", "ampersand"},
+		{"banner.jpg", "image3@gomailit", "banner"},
+	}
+	if len(images) != len(wantImages) {
+		t.Fatalf("%d images, want %d", len(images), len(wantImages))
+	}
+	for i, want := range wantImages {
+		image := images[i]
+		if image.Filename != want.name || image.ContentID != want.id || string(image.Data) != want.data {
+			t.Errorf("image %d = %s %s %q, want %s %s %q", i+1, image.Filename, image.ContentID, image.Data, want.name, want.id, want.data)
+		}
+	}
+}
+
+func TestEmbedImagesMissingFile(t *testing.T) {
+	document := `<img src="logo.png"><img src="missing.png">`
+	dir := t.TempDir()
+	writeImage(t, filepath.Join(dir, "logo.png"), "logo")
+
+	_, images, err := EmbedImages(document, dir)
+	if err == nil || !strings.Contains(err.Error(), "missing.png") {
+		t.Fatalf("error = %v, want one naming missing.png", err)
+	}
+	if images != nil {
+		t.Errorf("images returned with the error: %v", images)
+	}
+}
+
+func TestEmbedImagesRemoteOnly(t *testing.T) {
+	document := `<img src="https://example.com/a.png"><img src="http://example.com/b.png">`
+	got, images, err := EmbedImages(document, "/nonexistent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != document || len(images) != 0 {
+		t.Errorf("remote images rewritten: %s, %v", got, images)
+	}
+}
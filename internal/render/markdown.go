@@ -0,0 +1,176 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package render
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	heading     = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	rule        = regexp.MustCompile(`^\s*([-*_])(\s*[-*_]){2,}\s*$`)
+	bulletItem  = regexp.MustCompile(`^\s*[-*+]\s+(.*)$`)
+	orderedItem = regexp.MustCompile(`^\s*\d+[.)]\s+(.*)$`)
+	quoteLine   = regexp.MustCompile(`^\s*>\s?(.*)$`)
+
+	codeSpan = regexp.MustCompile("`([^`]+)`")
+	image    = regexp.MustCompile(`!\[([^\]]*)\]\(([^)\s]+)\)`)
+	link     = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	strong   = regexp.MustCompile(`(\*\*|__)(.+?)(\*\*|__)`)
+	emphasis = regexp.MustCompile(`(^|[^*\w])[*_]([^*_]+)[*_]`)
+)
+
+// MarkdownToHTML converts the commonly used subset of Markdown (headings,
+// paragraphs, emphasis, code, links, images, nested lists, block quotes and
+// rules) into an HTML document. HTML in the Markdown is escaped, not passed
+// through.
+func MarkdownToHTML(markdown string) string {
+	lines := strings.Split(strings.ReplaceAll(markdown, "\r\n", "\n"), "\n")
+
+	var out strings.Builder
+	out.WriteString("<!DOCTYPE html>\n<html>\n<body>\n")
+
+	var paragraph []string
+	flush := func() {
+		if len(paragraph) > 0 {
+			out.WriteString("<p>" + inline(strings.Join(paragraph, "\n")) + "</p>\n")
+			paragraph = nil
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		switch {
+		case strings.HasPrefix(strings.TrimSpace(line), "```"):
+			flush()
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), "```"); i++ {
+				code = append(code, html.EscapeString(lines[i]))
+			}
+			out.WriteString("<pre><code>" + strings.Join(code, "\n") + "</code></pre>\n")
+		case strings.TrimSpace(line) == "":
+			flush()
+		case heading.MatchString(line):
+			flush()
+			m := heading.FindStringSubmatch(line)
+			out.WriteString(fmt.Sprintf("<h%d>%s</h%d>\n", len(m[1]), inline(m[2]), len(m[1])))
+		case rule.MatchString(line):
+			flush()
+			out.WriteString("<hr>\n")
+		case bulletItem.MatchString(line), orderedItem.MatchString(line):
+			flush()
+			i = list(lines, i, &out) - 1
+		case quoteLine.MatchString(line):
+			flush()
+			var quote []string
+			for ; i < len(lines) && quoteLine.MatchString(lines[i]); i++ {
+				quote = append(quote, quoteLine.FindStringSubmatch(lines[i])[1])
+			}
+			i--
+			out.WriteString("<blockquote><p>" + inline(strings.Join(quote, "\n")) + "</p></blockquote>\n")
+		default:
+			paragraph = append(paragraph, strings.TrimSpace(line))
+		}
+	}
+	flush()
+
+	out.WriteString("</body>\n</html>\n")
+	return out.String()
+}
+
+// list renders the list starting at lines[i] and returns the index of the
+// first line after it. Items indented deeper than the first one form a
+// list nested in the item before them.
+func list(lines []string, i int, out *strings.Builder) int {
+	item, tag := bulletItem, "ul"
+	if !bulletItem.MatchString(lines[i]) {
+		item, tag = orderedItem, "ol"
+	}
+	indent := indentation(lines[i])
+
+	out.WriteString("<" + tag + ">\n")
+	open := false
+	for i < len(lines) && (bulletItem.MatchString(lines[i]) || orderedItem.MatchString(lines[i])) {
+		depth := indentation(lines[i])
+		if depth < indent {
+			break
+		}
+		if depth > indent && open {
+			out.WriteString("\n")
+			i = list(lines, i, out)
+			continue
+		}
+		if !item.MatchString(lines[i]) {
+			break
+		}
+		if open {
+			out.WriteString("</li>\n")
+		}
+		out.WriteString("<li>" + inline(item.FindStringSubmatch(lines[i])[1]))
+		open = true
+		i++
+	}
+	if open {
+		out.WriteString("</li>\n")
+	}
+	out.WriteString("</" + tag + ">\n")
+	return i
+}
+
+// indentation returns the width of the leading whitespace of line, a tab
+// counting as four spaces.
+func indentation(line string) int {
+	width := 0
+	for _, c := range line {
+		switch c {
+		case ' ':
+			width++
+		case '\t':
+			width += 4
+		default:
+			return width
+		}
+	}
+	return width
+}
+
+// inline renders the inline Markdown syntax of a block of text.
+func inline(text string) string {
+	// Code spans, images and links are rendered first and replaced by
+	// placeholders so that emphasis never rewrites their contents.
+	var rendered []string
+	protect := func(fragment string) string {
+		rendered = append(rendered, fragment)
+		return fmt.Sprintf("\x00%d\x00", len(rendered)-1)
+	}
+
+	text = codeSpan.ReplaceAllStringFunc(text, func(m string) string {
+		return protect("<code>" + html.EscapeString(codeSpan.FindStringSubmatch(m)[1]) + "</code>")
+	})
+	text = image.ReplaceAllStringFunc(text, func(m string) string {
+		p := image.FindStringSubmatch(m)
+		return protect(fmt.Sprintf(`<img src="%s" alt="%s">`, html.EscapeString(p[2]), html.EscapeString(p[1])))
+	})
+	text = link.ReplaceAllStringFunc(text, func(m string) string {
+		p := link.FindStringSubmatch(m)
+		return protect(fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(p[2]), emphasize(html.EscapeString(p[1]))))
+	})
+
+	text = emphasize(html.EscapeString(text))
+
+	for i, fragment := range rendered {
+		text = strings.Replace(text, fmt.Sprintf("\x00%d\x00", i), fragment, 1)
+	}
+	return text
+}
+
+// emphasize renders strong and emphasized text in escaped HTML.
+func emphasize(text string) string {
+	text = strong.ReplaceAllString(text, "<strong>$2</strong>")
+	return emphasis.ReplaceAllString(text, "$1<em>$2</em>")
+}
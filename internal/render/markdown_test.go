@@ -0,0 +1,62 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package render
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestMarkdownToHTML converts every testdata/markdown/*.md file and
+// compares it with the .html file next to it.
+func TestMarkdownToHTML(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "markdown", "*.md"))
+	if err != nil || len(inputs) == 0 {
+		t.Fatalf("no test documents: %v", err)
+	}
+	for _, input := range inputs {
+		name := strings.TrimSuffix(filepath.Base(input), ".md")
+		t.Run(name, func(t *testing.T) {
+			markdown, err := os.ReadFile(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, strings.TrimSuffix(input, ".md")+".html", MarkdownToHTML(string(markdown)))
+		})
+	}
+}
+
+func TestInline(t *testing.T) {
+	tests := []struct {
+		markdown string
+		want     string
+	}{
+		{"plain", "plain"},
+		{"**bold** and __bold__", "<strong>bold</strong> and <strong>bold</strong>"},
+		{"*em* and _em_", "<em>em</em> and <em>em</em>"},
+		{"snake_case_name and 2*3*4", "snake_case_name and 2*3*4"},
+		{"`a <b> & *c*`", "<code>a &lt;b&gt; &amp; *c*</code>"},
+		{"[a & b](https://example.com/?q=1&r=2)", `<a href="https://example.com/?q=1&amp;r=2">a &amp; b</a>`},
+		{"[*x*](https://example.com/_y_)", `<a href="https://example.com/_y_"><em>x</em></a>`},
+		{`![a "quote"](img.png)`, `<img src="img.png" alt="a &#34;quote&#34;">`},
+		{"&copy; <br>", "&amp;copy; &lt;br&gt;"},
+	}
+	for _, tt := range tests {
+		if got := inline(tt.markdown); got != tt.want {
+			t.Errorf("inline(%q) = %q, want %q", tt.markdown, got, tt.want)
+		}
+	}
+}
+
+// TestMarkdownPlainText checks the text alternative 'gomailit send
+// --markdown' generates from the converted HTML.
+func TestMarkdownPlainText(t *testing.T) {
+	got := HTMLToText(MarkdownToHTML("# Title\n\nSee [docs](https://example.com).\n\n- a\n  - b\n\n```\n  code\n```\n"))
+	want := "Title\n\nSee docs (https://example.com).\n\n* a\n  * b\n\n  code\n"
+	if got != want {
+		t.Errorf("text = %q, want %q", got, want)
+	}
+}
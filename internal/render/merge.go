@@ -0,0 +1,230 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package render
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/latocchi/gomailit/internal/mail"
+)
+
+// Row is one record of a mail merge data file, keyed by column name.
+type Row map[string]string
+
+// ReadCSV reads a CSV file whose first line holds the column names.
+func ReadCSV(path string) ([]Row, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open data file: %v", err)
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read data file header: %v", err)
+	}
+	for i, column := range header {
+		header[i] = strings.TrimSpace(strings.TrimPrefix(column, "\ufeff"))
+	}
+
+	var rows []Row
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to read data file: %v", err)
+		}
+
+		row := Row{}
+		for i, column := range header {
+			if i < len(record) {
+				row[column] = strings.TrimSpace(record[i])
+			} else {
+				row[column] = ""
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// MergeOptions holds the template sources of a mail merge. Text, HTML and
+// Markdown are template sources, not file names.
+type MergeOptions struct {
+	To      string
+	Subject string
+	Text    string
+	// HTML is rendered with html/template, so values are escaped.
+	HTML string
+	// Markdown is rendered with text/template and then converted to HTML.
+	Markdown string
+
+	// AttachColumns name the columns holding per-row attachment paths.
+	// A cell may list several paths separated by ';'.
+	AttachColumns []string
+	// DataDir resolves relative attachment paths from the data file.
+	DataDir string
+	// BodyDir resolves relative image paths from the HTML or Markdown file.
+	BodyDir string
+}
+
+// Merge renders one message per data row.
+type Merge struct {
+	options  MergeOptions
+	to       *texttemplate.Template
+	subject  *texttemplate.Template
+	text     *texttemplate.Template
+	html     *htmltemplate.Template
+	markdown *texttemplate.Template
+}
+
+// NewMerge parses the templates of a mail merge. Referencing a column that
+// does not exist is an error when rendering.
+func NewMerge(options MergeOptions) (*Merge, error) {
+	m := &Merge{options: options}
+
+	var err error
+	parse := func(name, source string) *texttemplate.Template {
+		if err != nil || source == "" {
+			return nil
+		}
+		var t *texttemplate.Template
+		t, err = texttemplate.New(name).Option("missingkey=error").Parse(source)
+		if err != nil {
+			err = fmt.Errorf("unable to parse %s template: %v", name, err)
+		}
+		return t
+	}
+	m.to = parse("to", options.To)
+	m.subject = parse("subject", options.Subject)
+	m.text = parse("body", options.Text)
+	m.markdown = parse("markdown", options.Markdown)
+	if err != nil {
+		return nil, err
+	}
+
+	if options.HTML != "" {
+		m.html, err = htmltemplate.New("html").Option("missingkey=error").Parse(options.HTML)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse html template: %v", err)
+		}
+	}
+
+	if m.to == nil {
+		return nil, fmt.Errorf("a recipient template is required")
+	}
+	return m, nil
+}
+
+// Render renders the message of one row. Only the fields driven by the
+// templates and data (To, Subject, bodies and attachments) are set.
+func (m *Merge) Render(row Row) (*mail.Message, error) {
+	msg := &mail.Message{}
+
+	var err error
+	if msg.To, err = m.recipients(row); err != nil {
+		return nil, err
+	}
+	if msg.Subject, err = execute(m.subject, row); err != nil {
+		return nil, err
+	}
+	if msg.TextBody, err = execute(m.text, row); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case m.html != nil:
+		var buf bytes.Buffer
+		if err := m.html.Execute(&buf, row); err != nil {
+			return nil, fmt.Errorf("unable to render html template: %v", err)
+		}
+		msg.HTMLBody = buf.String()
+	case m.markdown != nil:
+		source, err := execute(m.markdown, row)
+		if err != nil {
+			return nil, err
+		}
+		msg.HTMLBody = MarkdownToHTML(source)
+	}
+
+	if msg.HTMLBody != "" {
+		if m.text == nil {
+			msg.TextBody = HTMLToText(msg.HTMLBody)
+		}
+		var images []mail.Attachment
+		msg.HTMLBody, images, err = EmbedImages(msg.HTMLBody, m.options.BodyDir)
+		if err != nil {
+			return nil, err
+		}
+		msg.Attachments = append(msg.Attachments, images...)
+	}
+
+	attachments, err := m.attachments(row)
+	if err != nil {
+		return nil, err
+	}
+	msg.Attachments = append(msg.Attachments, attachments...)
+
+	return msg, nil
+}
+
+func (m *Merge) recipients(row Row) ([]string, error) {
+	value, err := execute(m.to, row)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(value) == "" {
+		return nil, fmt.Errorf("row has no recipient")
+	}
+	return mail.ParseAddressList([]string{value})
+}
+
+func (m *Merge) attachments(row Row) ([]mail.Attachment, error) {
+	var attachments []mail.Attachment
+	for _, column := range m.options.AttachColumns {
+		cell, ok := row[column]
+		if !ok {
+			return nil, fmt.Errorf("attachment column %q does not exist", column)
+		}
+		for _, path := range strings.Split(cell, ";") {
+			path = strings.TrimSpace(path)
+			if path == "" {
+				continue
+			}
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(m.options.DataDir, path)
+			}
+			attachment, err := mail.LoadAttachment(path)
+			if err != nil {
+				return nil, err
+			}
+			attachments = append(attachments, attachment)
+		}
+	}
+	return attachments, nil
+}
+
+func execute(t *texttemplate.Template, row Row) (string, error) {
+	if t == nil {
+		return "", nil
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, row); err != nil {
+		return "", fmt.Errorf("unable to render %s template: %v", t.Name(), err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,315 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package render
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeCSV(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "data.csv")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []Row
+	}{
+		{
+			name:    "plain",
+			content: "Name,Email\nAda,ada@example.com\nGrace,grace@example.com\n",
+			want: []Row{
+				{"Name": "Ada", "Email": "ada@example.com"},
+				{"Name": "Grace", "Email": "grace@example.com"},
+			},
+		},
+		{
+			name:    "byte order mark",
+			content: "\ufeffEmail,Name\r\nada@example.com,Ada\r\n",
+			want:    []Row{{"Email": "ada@example.com", "Name": "Ada"}},
+		},
+		{
+			name:    "quoted fields",
+			content: "Name,Email,Note\n\"Lovelace, Ada\",ada@example.com,\"said \"\"hi\"\"\nthen left\"\n",
+			want: []Row{
+				{"Name": "Lovelace, Ada", "Email": "ada@example.com", "Note": "said \"hi\"\nthen left"},
+			},
+		},
+		{
+			name:    "spaces around values",
+			content: " Name , Email \n Ada ,  ada@example.com \n",
+			want:    []Row{{"Name": "Ada", "Email": "ada@example.com"}},
+		},
+		{
+			name:    "header only",
+			content: "Name,Email\n",
+			want:    nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadCSV(writeCSV(t, tt.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rows = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadCSVErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"empty", "", "unable to read data file header"},
+		{"unterminated quote", "Name,Email\n\"Ada,ada@example.com\n", "unable to read data file"},
+		{"extra field", "Name,Email\nAda,ada@example.com,extra\n", "unable to read data file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadCSV(writeCSV(t, tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := ReadCSV(filepath.Join(t.TempDir(), "missing.csv")); err == nil || !strings.Contains(err.Error(), "unable to open data file") {
+		t.Errorf("missing file: error = %v", err)
+	}
+}
+
+func TestMergeRender(t *testing.T) {
+	merge, err := NewMerge(MergeOptions{
+		To:      `{{.Name}} <{{.Email}}>`,
+		Subject: "Hello {{.Name}}",
+		Text:    "Dear {{.Name}},\n\nYour code is {{.Code}}.\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := merge.Render(Row{"Name": "Ada", "Email": "ada@example.com", "Code": "<b>&</b>"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{`"Ada" <ada@example.com>`}; !reflect.DeepEqual(msg.To, want) {
+		t.Errorf("To = %q, want %q", msg.To, want)
+	}
+	if msg.Subject != "Hello Ada" {
+		t.Errorf("Subject = %q", msg.Subject)
+	}
+	// Plain text is not escaped.
+	if want := "Dear Ada,\n\nYour code is <b>&</b>.\n"; msg.TextBody != want {
+		t.Errorf("TextBody = %q, want %q", msg.TextBody, want)
+	}
+	if msg.HTMLBody != "" || len(msg.Attachments) != 0 {
+		t.Errorf("unexpected HTML body or attachments: %q, %v", msg.HTMLBody, msg.Attachments)
+	}
+}
+
+func TestMergeEscaping(t *testing.T) {
+	row := Row{"Email": "ada@example.com", "Name": `<script>alert("x")</script> & co`}
+
+	html, err := NewMerge(MergeOptions{
+		To:      "{{.Email}}",
+		Subject: "For {{.Name}}",
+		HTML:    `<p>Hi {{.Name}}</p><a href="https://example.com/?n={{.Name}}">link</a>`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := html.Render(row)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(msg.HTMLBody, "<script>") {
+		t.Errorf("html/template left the value unescaped: %s", msg.HTMLBody)
+	}
+	if !strings.Contains(msg.HTMLBody, "<p>Hi &lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt; &amp; co</p>") {
+		t.Errorf("HTMLBody = %s", msg.HTMLBody)
+	}
+	if !strings.Contains(msg.HTMLBody, `href="https://example.com/?n=%3cscript%3ealert%28%22x%22%29%3c%2fscript%3e%20%26%20co"`) {
+		t.Errorf("attribute not URL escaped: %s", msg.HTMLBody)
+	}
+	// The subject is a text/template and the text alternative is derived
+	// from the HTML, so both read as the raw value.
+	if want := `For <script>alert("x")</script> & co`; msg.Subject != want {
+		t.Errorf("Subject = %q, want %q", msg.Subject, want)
+	}
+	if !strings.Contains(msg.TextBody, `Hi <script>alert("x")</script> & co`) {
+		t.Errorf("TextBody = %q", msg.TextBody)
+	}
+
+	// Markdown is rendered with text/template, and the converter escapes
+	// the HTML in the result.
+	markdown, err := NewMerge(MergeOptions{To: "{{.Email}}", Markdown: "Hi **{{.Name}}**\n"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err = markdown.Render(row)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "<p>Hi <strong>&lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt; &amp; co</strong></p>"; !strings.Contains(msg.HTMLBody, want) {
+		t.Errorf("HTMLBody = %q, want it to contain %q", msg.HTMLBody, want)
+	}
+}
+
+func TestMergeMissingColumn(t *testing.T) {
+	row := Row{"Email": "ada@example.com"}
+	tests := []struct {
+		name    string
+		options MergeOptions
+		want    string
+	}{
+		{"to", MergeOptions{To: "{{.Address}}"}, "unable to render to template"},
+		{"subject", MergeOptions{To: "{{.Email}}", Subject: "Hi {{.Name}}"}, "unable to render subject template"},
+		{"text", MergeOptions{To: "{{.Email}}", Text: "Hi {{.Name}}"}, "unable to render body template"},
+		{"html", MergeOptions{To: "{{.Email}}", HTML: "<p>Hi {{.Name}}</p>"}, "unable to render html template"},
+		{"markdown", MergeOptions{To: "{{.Email}}", Markdown: "Hi {{.Name}}"}, "unable to render markdown template"},
+		{"attachment column", MergeOptions{To: "{{.Email}}", AttachColumns: []string{"Invoice"}}, `attachment column "Invoice" does not exist`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merge, err := NewMerge(tt.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = merge.Render(row)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewMergeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		options MergeOptions
+		want    string
+	}{
+		{"no recipient", MergeOptions{Subject: "Hi"}, "a recipient template is required"},
+		{"bad subject", MergeOptions{To: "{{.Email}}", Subject: "{{.Name"}, "unable to parse subject template"},
+		{"bad html", MergeOptions{To: "{{.Email}}", HTML: "{{if}}"}, "unable to parse html template"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewMerge(tt.options)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeNoAddress(t *testing.T) {
+	merge, err := NewMerge(MergeOptions{To: "{{.Email}}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, email := range []string{"", "   "} {
+		_, err := merge.Render(Row{"Email": email})
+		if err == nil || err.Error() != "row has no recipient" {
+			t.Errorf("Email %q: error = %v, want row has no recipient", email, err)
+		}
+	}
+
+	_, err = merge.Render(Row{"Email": "not an address"})
+	if err == nil || !strings.Contains(err.Error(), "invalid address") {
+		t.Errorf("error = %v, want invalid address", err)
+	}
+}
+
+func TestMergeAttachments(t *testing.T) {
+	dataDir := t.TempDir()
+	writeImage(t, filepath.Join(dataDir, "invoices", "ada.pdf"), "invoice")
+	writeImage(t, filepath.Join(dataDir, "terms.txt"), "terms")
+	absolute := filepath.Join(t.TempDir(), "extra.txt")
+	writeImage(t, absolute, "extra")
+
+	merge, err := NewMerge(MergeOptions{
+		To:            "{{.Email}}",
+		Text:          "See attached.",
+		AttachColumns: []string{"Invoice", "Extra"},
+		DataDir:       dataDir,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := merge.Render(Row{
+		"Email":   "ada@example.com",
+		"Invoice": "invoices/ada.pdf; terms.txt;",
+		"Extra":   absolute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, attachment := range msg.Attachments {
+		got = append(got, attachment.Filename+"="+string(attachment.Data))
+	}
+	if want := []string{"ada.pdf=invoice", "terms.txt=terms", "extra.txt=extra"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("attachments = %q, want %q", got, want)
+	}
+
+	// An empty cell attaches nothing for that row.
+	msg, err = merge.Render(Row{"Email": "grace@example.com", "Invoice": "", "Extra": " "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msg.Attachments) != 0 {
+		t.Errorf("attachments = %v, want none", msg.Attachments)
+	}
+
+	_, err = merge.Render(Row{"Email": "ada@example.com", "Invoice": "invoices/missing.pdf", "Extra": ""})
+	if err == nil || !strings.Contains(err.Error(), "missing.pdf") {
+		t.Errorf("error = %v, want one naming missing.pdf", err)
+	}
+}
+
+func TestMergeInlineImages(t *testing.T) {
+	bodyDir := t.TempDir()
+	writeImage(t, filepath.Join(bodyDir, "logo.png"), "logo")
+
+	merge, err := NewMerge(MergeOptions{
+		To:      "{{.Email}}",
+		HTML:    `<p>Hi {{.Name}}</p><img src="logo.png">`,
+		BodyDir: bodyDir,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := merge.Render(Row{"Email": "ada@example.com", "Name": "Ada"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `<p>Hi Ada</p><img src="cid:image1@gomailit">`; msg.HTMLBody != want {
+		t.Errorf("HTMLBody = %q, want %q", msg.HTMLBody, want)
+	}
+	if len(msg.Attachments) != 1 || msg.Attachments[0].ContentID != "image1@gomailit" {
+		t.Errorf("attachments = %v", msg.Attachments)
+	}
+}
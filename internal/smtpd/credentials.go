@@ -0,0 +1,70 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package smtpd
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Credentials is a list of users allowed to submit mail, loaded from a file
+// with one "username:password" pair per line. Blank lines and lines starting
+// with # are ignored.
+type Credentials map[string]string
+
+// LoadCredentials reads a credential list. The file holds passwords, so it
+// must not be readable by other users.
+func LoadCredentials(path string) (Credentials, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read credentials: %v", err)
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		return nil, fmt.Errorf("%s is accessible by other users, run: chmod 600 %s", path, path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read credentials: %v", err)
+	}
+	defer f.Close()
+
+	credentials := Credentials{}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		username, password, ok := strings.Cut(line, ":")
+		if !ok || username == "" || password == "" {
+			return nil, fmt.Errorf("%s:%d: expected username:password", path, n)
+		}
+		credentials[username] = password
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read credentials: %v", err)
+	}
+	if len(credentials) == 0 {
+		return nil, fmt.Errorf("%s has no credentials", path)
+	}
+	return credentials, nil
+}
+
+// Check reports whether username and password match an entry, comparing in
+// constant time.
+func (c Credentials) Check(username, password string) bool {
+	expected, ok := c[username]
+	if !ok {
+		// Compare anyway so that unknown users take as long as known ones.
+		expected = "\x00"
+	}
+	a := sha256.Sum256([]byte(password))
+	b := sha256.Sum256([]byte(expected))
+	return subtle.ConstantTimeCompare(a[:], b[:]) == 1 && ok
+}
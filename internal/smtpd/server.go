@@ -0,0 +1,497 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+
+// Package smtpd implements the server side of SMTP submission, enough for
+// local applications to hand messages to gomailit.
+package smtpd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/latocchi/gomailit/internal/mail"
+	"github.com/latocchi/gomailit/internal/mime"
+)
+
+// Handler delivers a message received by the server. Returning an error
+// rejects the message; errors for which Temporary reports true are
+// reported with a 4xx code so that the client retries later.
+type Handler func(ctx context.Context, env mime.Envelope, raw []byte) error
+
+// Server holds the settings shared by all sessions.
+type Server struct {
+	// Hostname is announced in the greeting.
+	Hostname string
+	// MaxSize is the largest accepted message in bytes, 0 for no limit.
+	MaxSize int64
+	Handler Handler
+	// Temporary reports whether a Handler error is worth retrying.
+	Temporary func(error) bool
+
+	// Authenticate checks AUTH credentials. When it is set, clients must
+	// authenticate before sending mail.
+	Authenticate func(username, password string) bool
+	// TLSConfig enables STARTTLS on network connections.
+	TLSConfig *tls.Config
+	// Timeout is how long the server waits for a command, 0 for no limit.
+	Timeout time.Duration
+	// Logf, when set, receives a line for every delivered or rejected
+	// message.
+	Logf func(format string, args ...any)
+}
+
+// session is the state of one SMTP conversation.
+type session struct {
+	server *Server
+	conn   net.Conn // nil when not serving a network connection
+	text   *textproto.Conn
+	env    mime.Envelope
+	// sender is set by MAIL, which may give an empty reverse-path.
+	sender bool
+	helo   bool
+	tls    bool
+	user   string
+}
+
+// Serve accepts connections on ln and serves each in its own goroutine
+// until ctx is cancelled. It waits for open sessions to finish before
+// returning.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	stop := context.AfterFunc(ctx, func() { ln.Close() })
+	defer stop()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.ServeSession(ctx, conn); err != nil && ctx.Err() == nil {
+				s.logf("%s: %v", conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+// ServeSession runs an SMTP conversation over rw until the client quits or
+// the connection fails.
+func (s *Server) ServeSession(ctx context.Context, rw io.ReadWriteCloser) error {
+	sess := &session{server: s, text: textproto.NewConn(rw)}
+	defer func() { sess.text.Close() }()
+
+	if conn, ok := rw.(net.Conn); ok {
+		sess.conn = conn
+		// Unblock a pending read when the server shuts down.
+		stop := context.AfterFunc(ctx, func() { conn.SetReadDeadline(time.Now()) })
+		defer stop()
+	}
+
+	sess.reply(220, fmt.Sprintf("%s ESMTP gomailit ready", s.hostname()))
+	for {
+		if ctx.Err() != nil {
+			sess.reply(421, "Service shutting down")
+			return nil
+		}
+
+		if sess.conn != nil && s.Timeout > 0 {
+			sess.conn.SetReadDeadline(time.Now().Add(s.Timeout))
+		}
+		line, err := sess.text.ReadLine()
+		if err != nil {
+			if ctx.Err() != nil {
+				sess.reply(421, "Service shutting down")
+				return nil
+			}
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				sess.reply(421, "Timeout waiting for command")
+				return nil
+			}
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		verb, arg, _ := strings.Cut(strings.TrimSpace(line), " ")
+		if quit := sess.handle(ctx, strings.ToUpper(verb), strings.TrimSpace(arg)); quit {
+			return nil
+		}
+	}
+}
+
+func (s *Server) logf(format string, args ...any) {
+	if s.Logf != nil {
+		s.Logf(format, args...)
+	}
+}
+
+func (s *Server) hostname() string {
+	if s.Hostname == "" {
+		return "localhost"
+	}
+	return s.Hostname
+}
+
+// handle runs one command and reports whether the session is over.
+func (sess *session) handle(ctx context.Context, verb, arg string) bool {
+	switch verb {
+	case "HELO":
+		sess.helo = true
+		sess.reset()
+		sess.reply(250, sess.server.hostname())
+	case "EHLO":
+		sess.helo = true
+		sess.reset()
+		sess.reply(250, sess.extensions()...)
+	case "MAIL":
+		sess.mail(arg)
+	case "RCPT":
+		sess.rcpt(arg)
+	case "DATA":
+		sess.data(ctx)
+	case "STARTTLS":
+		sess.startTLS()
+	case "AUTH":
+		sess.auth(arg)
+	case "RSET":
+		sess.reset()
+		sess.reply(250, "OK")
+	case "NOOP":
+		sess.reply(250, "OK")
+	case "VRFY":
+		sess.reply(252, "Cannot VRFY user, but will accept message")
+	case "QUIT":
+		sess.reply(221, "Bye")
+		return true
+	default:
+		sess.reply(502, "Command not implemented")
+	}
+	return false
+}
+
+func (sess *session) extensions() []string {
+	lines := []string{sess.server.hostname(), "PIPELINING", "8BITMIME"}
+	if sess.server.MaxSize > 0 {
+		lines = append(lines, fmt.Sprintf("SIZE %d", sess.server.MaxSize))
+	}
+	if sess.canStartTLS() {
+		lines = append(lines, "STARTTLS")
+	}
+	if sess.server.Authenticate != nil && sess.authAllowed() {
+		lines = append(lines, "AUTH PLAIN LOGIN")
+	}
+	return lines
+}
+
+// authAllowed reports whether passwords may be sent: over TLS, or when they
+// cannot cross a network because the client is local.
+func (sess *session) authAllowed() bool {
+	if sess.tls || sess.conn == nil {
+		return true
+	}
+	host, _, err := net.SplitHostPort(sess.conn.RemoteAddr().String())
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// authorized replies 530 and returns false when the client must
+// authenticate first.
+func (sess *session) authorized() bool {
+	if sess.server.Authenticate != nil && sess.user == "" {
+		if sess.authAllowed() {
+			sess.reply(530, "Authentication required")
+		} else {
+			sess.reply(530, "Must issue a STARTTLS command first")
+		}
+		return false
+	}
+	return true
+}
+
+func (sess *session) canStartTLS() bool {
+	return sess.server.TLSConfig != nil && sess.conn != nil && !sess.tls
+}
+
+func (sess *session) startTLS() {
+	if !sess.canStartTLS() {
+		sess.reply(502, "STARTTLS not available")
+		return
+	}
+	sess.reply(220, "Ready to start TLS")
+
+	conn := tls.Server(sess.conn, sess.server.TLSConfig)
+	if sess.server.Timeout > 0 {
+		conn.SetDeadline(time.Now().Add(sess.server.Timeout))
+	}
+	if err := conn.Handshake(); err != nil {
+		sess.server.logf("%s: TLS handshake failed: %v", sess.conn.RemoteAddr(), err)
+		sess.conn.Close()
+		return
+	}
+	conn.SetDeadline(time.Time{})
+
+	// The client starts over after the handshake (RFC 3207).
+	sess.conn = conn
+	sess.text = textproto.NewConn(conn)
+	sess.tls = true
+	sess.helo = false
+	sess.user = ""
+	sess.reset()
+}
+
+// auth implements AUTH PLAIN (RFC 4616) and AUTH LOGIN.
+func (sess *session) auth(arg string) {
+	switch {
+	case sess.server.Authenticate == nil:
+		sess.reply(502, "Authentication not available")
+		return
+	case !sess.helo:
+		sess.reply(503, "Send EHLO first")
+		return
+	case !sess.authAllowed():
+		sess.reply(538, "Encryption required for requested authentication mechanism")
+		return
+	case sess.user != "":
+		sess.reply(503, "Already authenticated")
+		return
+	}
+
+	mechanism, initial, _ := strings.Cut(arg, " ")
+	var username, password string
+	var err error
+	switch strings.ToUpper(mechanism) {
+	case "PLAIN":
+		var response string
+		if response, err = sess.challenge(initial, ""); err == nil {
+			// authzid NUL authcid NUL passwd
+			parts := strings.Split(response, "\x00")
+			if len(parts) != 3 {
+				sess.reply(501, "Malformed AUTH PLAIN response")
+				return
+			}
+			username, password = parts[1], parts[2]
+		}
+	case "LOGIN":
+		if username, err = sess.challenge(initial, "Username:"); err == nil {
+			password, err = sess.challenge("", "Password:")
+		}
+	default:
+		sess.reply(504, "Unrecognized authentication mechanism")
+		return
+	}
+	if err != nil {
+		sess.reply(501, err.Error())
+		return
+	}
+
+	if !sess.server.Authenticate(username, password) {
+		sess.server.logf("%s: authentication failed for %q", sess.remote(), username)
+		sess.reply(535, "Authentication credentials invalid")
+		return
+	}
+	sess.user = username
+	sess.reply(235, "Authentication successful")
+}
+
+// challenge returns the decoded initial response if there is one, or sends
+// prompt and reads the client's answer.
+func (sess *session) challenge(initial, prompt string) (string, error) {
+	response := initial
+	if response == "" {
+		sess.reply(334, base64.StdEncoding.EncodeToString([]byte(prompt)))
+		line, err := sess.text.ReadLine()
+		if err != nil {
+			return "", errors.New("Error reading response")
+		}
+		response = strings.TrimSpace(line)
+	}
+	if response == "*" {
+		return "", errors.New("Authentication cancelled")
+	}
+	decoded, err := base64.StdEncoding.DecodeString(response)
+	if err != nil {
+		return "", errors.New("Invalid base64 response")
+	}
+	return string(decoded), nil
+}
+
+func (sess *session) remote() string {
+	if sess.conn == nil {
+		return "stdin"
+	}
+	return sess.conn.RemoteAddr().String()
+}
+
+func (sess *session) reset() {
+	sess.env = mime.Envelope{}
+	sess.sender = false
+}
+
+func (sess *session) mail(arg string) {
+	if !sess.helo {
+		sess.reply(503, "Send HELO/EHLO first")
+		return
+	}
+	if !sess.authorized() {
+		return
+	}
+	address, ok := pathArg(arg, "FROM:")
+	if !ok {
+		sess.reply(501, "Syntax: MAIL FROM:<address>")
+		return
+	}
+	sess.reset()
+	sess.env.From = address
+	sess.sender = true
+	sess.reply(250, "OK")
+}
+
+func (sess *session) rcpt(arg string) {
+	if !sess.helo {
+		sess.reply(503, "Send HELO/EHLO first")
+		return
+	}
+	if !sess.authorized() {
+		return
+	}
+	if !sess.sender {
+		sess.reply(503, "Need MAIL command")
+		return
+	}
+	address, ok := pathArg(arg, "TO:")
+	if !ok || address == "" {
+		sess.reply(501, "Syntax: RCPT TO:<address>")
+		return
+	}
+	sess.env.Recipients = append(sess.env.Recipients, mail.BareAddress(address))
+	sess.reply(250, "OK")
+}
+
+func (sess *session) data(ctx context.Context) {
+	if !sess.authorized() {
+		return
+	}
+	if !sess.sender {
+		sess.reply(503, "Need MAIL command")
+		return
+	}
+	if len(sess.env.Recipients) == 0 {
+		sess.reply(503, "Need RCPT before DATA")
+		return
+	}
+	sess.reply(354, "End data with <CR><LF>.<CR><LF>")
+
+	dot := sess.text.DotReader()
+	var raw []byte
+	var err error
+	if sess.server.MaxSize > 0 {
+		raw, err = io.ReadAll(io.LimitReader(dot, sess.server.MaxSize+1))
+	} else {
+		raw, err = io.ReadAll(dot)
+	}
+	if err != nil {
+		sess.reply(451, "Error reading message")
+		return
+	}
+	if sess.server.MaxSize > 0 && int64(len(raw)) > sess.server.MaxSize {
+		// Drain the rest of the message before rejecting it.
+		io.Copy(io.Discard, dot)
+		sess.reset()
+		sess.server.logf("%s: rejected message larger than %d bytes", sess.remote(), sess.server.MaxSize)
+		sess.reply(552, "Message exceeds maximum size")
+		return
+	}
+
+	// DotReader turns CRLF into LF, restore canonical line endings.
+	raw = bytes.ReplaceAll(raw, []byte("\n"), []byte("\r\n"))
+
+	env := sess.env
+	sess.reset()
+	recipients := strings.Join(env.Recipients, ", ")
+	if err := sess.server.Handler(ctx, env, raw); err != nil {
+		sess.server.logf("%s: failed to send message to %s: %v", sess.remote(), recipients, err)
+		if sess.server.Temporary != nil && sess.server.Temporary(err) {
+			sess.reply(451, "Temporary failure: "+oneLine(err))
+		} else {
+			sess.reply(554, "Transaction failed: "+oneLine(err))
+		}
+		return
+	}
+	sess.server.logf("%s: sent message to %s", sess.remote(), recipients)
+	sess.reply(250, "OK: queued")
+}
+
+// reply writes a single or multi-line response.
+func (sess *session) reply(code int, lines ...string) {
+	var buf strings.Builder
+	for i, line := range lines {
+		sep := "-"
+		if i == len(lines)-1 {
+			sep = " "
+		}
+		fmt.Fprintf(&buf, "%d%s%s\r\n", code, sep, line)
+	}
+	sess.text.W.WriteString(buf.String())
+	sess.text.W.Flush()
+}
+
+// pathArg parses the argument of MAIL FROM:<...> or RCPT TO:<...>,
+// ignoring ESMTP parameters such as SIZE=.
+func pathArg(arg, prefix string) (string, bool) {
+	if len(arg) < len(prefix) || !strings.EqualFold(arg[:len(prefix)], prefix) {
+		return "", false
+	}
+	path := strings.TrimSpace(arg[len(prefix):])
+	if strings.HasPrefix(path, "<") {
+		end := strings.IndexByte(path, '>')
+		if end < 0 {
+			return "", false
+		}
+		return path[1:end], true
+	}
+	address, _, _ := strings.Cut(path, " ")
+	return address, true
+}
+
+func oneLine(err error) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(err.Error())
+}
+
+// stdio joins a reader and a writer, such as stdin and stdout, into a
+// connection.
+type stdio struct {
+	io.Reader
+	io.Writer
+}
+
+func (stdio) Close() error { return nil }
+
+// Stdio returns a connection that reads from r and writes to w, for
+// 'sendmail -bs'.
+func Stdio(r io.Reader, w io.Writer) io.ReadWriteCloser {
+	return stdio{Reader: bufio.NewReader(r), Writer: w}
+}
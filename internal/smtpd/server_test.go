@@ -0,0 +1,272 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package smtpd
+
+import (
+	"context"
+	"encoding/base64"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/latocchi/gomailit/internal/mime"
+	"github.com/latocchi/gomailit/internal/testutil"
+)
+
+// sink collects the messages handed to the server's Handler.
+type sink struct {
+	mu       sync.Mutex
+	messages []delivery
+}
+
+type delivery struct {
+	env mime.Envelope
+	raw string
+}
+
+func (s *sink) handle(ctx context.Context, env mime.Envelope, raw []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, delivery{env, string(raw)})
+	return nil
+}
+
+func (s *sink) delivered() []delivery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]delivery(nil), s.messages...)
+}
+
+func checkCredentials(username, password string) bool {
+	return username == "alice" && password == "secret"
+}
+
+// listen serves s on a loopback address and returns a connected client.
+func listen(t *testing.T, s *Server) *smtp.Client {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Serve(ctx, ln)
+		close(done)
+	}()
+
+	c, err := smtp.Dial(ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		c.Close()
+		cancel()
+		<-done
+	})
+	return c
+}
+
+// remote serves s over a connection that does not come from a loopback
+// address and returns a connected client.
+func remote(t *testing.T, s *Server) *smtp.Client {
+	t.Helper()
+	serverConn, clientConn := net.Pipe()
+	go s.ServeSession(context.Background(), serverConn)
+
+	c, err := smtp.NewClient(clientConn, "relay.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// send submits a message with net/smtp's client.
+func send(c *smtp.Client, from string, to []string, msg string) error {
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte(msg)); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// expect sends a raw command and checks the reply code.
+func expect(t *testing.T, text *textproto.Conn, code int, command string) {
+	t.Helper()
+	id, err := text.Cmd("%s", command)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text.StartResponse(id)
+	defer text.EndResponse(id)
+	got, msg, _ := text.ReadResponse(0)
+	if got != code {
+		t.Errorf("%s: got %d %s, want %d", command, got, msg, code)
+	}
+}
+
+func TestEnvelopeHandOff(t *testing.T) {
+	out := &sink{}
+	c := listen(t, &Server{Handler: out.handle})
+	if err := c.Hello("client.example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := "From: alice@example.com\r\nTo: bob@example.com\r\nSubject: hi\r\n\r\nHello Bob\r\n"
+	if err := send(c, "alice@example.com", []string{"bob@example.com", "hidden@example.com"}, msg); err != nil {
+		t.Fatal(err)
+	}
+
+	got := out.delivered()
+	if len(got) != 1 {
+		t.Fatalf("%d messages delivered, want 1", len(got))
+	}
+	if got[0].env.From != "alice@example.com" {
+		t.Errorf("envelope sender = %q", got[0].env.From)
+	}
+	if strings.Join(got[0].env.Recipients, ",") != "bob@example.com,hidden@example.com" {
+		t.Errorf("envelope recipients = %v", got[0].env.Recipients)
+	}
+	if got[0].raw != msg {
+		t.Errorf("message = %q, want %q", got[0].raw, msg)
+	}
+}
+
+func TestDotUnstuffing(t *testing.T) {
+	out := &sink{}
+	c := listen(t, &Server{Handler: out.handle})
+
+	// The client stuffs every line starting with a dot, the server must
+	// remove exactly that dot again.
+	msg := "Subject: dots\r\n\r\n.leading dot\r\n..two dots\r\n.\r\nlast line\r\n"
+	if err := send(c, "alice@example.com", []string{"bob@example.com"}, msg); err != nil {
+		t.Fatal(err)
+	}
+	got := out.delivered()
+	if len(got) != 1 || got[0].raw != msg {
+		t.Errorf("delivered %q, want %q", got, msg)
+	}
+}
+
+func TestMaxSize(t *testing.T) {
+	out := &sink{}
+	c := listen(t, &Server{Handler: out.handle, MaxSize: 100})
+	if err := c.Hello("client.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, param := c.Extension("SIZE"); !ok || param != "100" {
+		t.Errorf("SIZE extension = %v %q, want 100", ok, param)
+	}
+
+	large := "Subject: large\r\n\r\n" + strings.Repeat("x", 200) + "\r\n"
+	err := send(c, "alice@example.com", []string{"bob@example.com"}, large)
+	if err == nil || !strings.Contains(err.Error(), "552") {
+		t.Fatalf("large message: %v, want a 552 reply", err)
+	}
+
+	// The session goes on after the rejected message.
+	small := "Subject: small\r\n\r\nok\r\n"
+	if err := send(c, "alice@example.com", []string{"bob@example.com"}, small); err != nil {
+		t.Fatal(err)
+	}
+	got := out.delivered()
+	if len(got) != 1 || got[0].raw != small {
+		t.Errorf("delivered %v, want only the small message", got)
+	}
+}
+
+func TestAuthRequired(t *testing.T) {
+	out := &sink{}
+	c := listen(t, &Server{Handler: out.handle, Authenticate: checkCredentials})
+	if err := c.Hello("client.example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every step of a transaction needs authentication, not only MAIL.
+	expect(t, c.Text, 530, "MAIL FROM:<alice@example.com>")
+	expect(t, c.Text, 530, "RCPT TO:<bob@example.com>")
+	expect(t, c.Text, 530, "DATA")
+
+	expect(t, c.Text, 535, "AUTH PLAIN "+base64.StdEncoding.EncodeToString([]byte("\x00alice\x00wrong")))
+	if err := c.Auth(smtp.PlainAuth("", "alice", "secret", "127.0.0.1")); err != nil {
+		t.Fatal(err)
+	}
+
+	// RCPT and DATA still need a MAIL command first.
+	expect(t, c.Text, 503, "RCPT TO:<bob@example.com>")
+	expect(t, c.Text, 503, "DATA")
+
+	if err := send(c, "alice@example.com", []string{"bob@example.com"}, "Subject: hi\r\n\r\nok\r\n"); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.delivered(); len(got) != 1 {
+		t.Errorf("%d messages delivered, want 1", len(got))
+	}
+}
+
+func TestNeedMail(t *testing.T) {
+	out := &sink{}
+	c := listen(t, &Server{Handler: out.handle})
+	if err := c.Hello("client.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	expect(t, c.Text, 503, "RCPT TO:<bob@example.com>")
+	expect(t, c.Text, 503, "DATA")
+	if got := out.delivered(); len(got) != 0 {
+		t.Errorf("%d messages delivered without MAIL", len(got))
+	}
+}
+
+func TestAuthOnlyAfterSTARTTLS(t *testing.T) {
+	serverTLS, clientTLS := testutil.TLS(t, "relay.example.com")
+	out := &sink{}
+	c := remote(t, &Server{Handler: out.handle, Authenticate: checkCredentials, TLSConfig: serverTLS})
+	if err := c.Hello("client.example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Before STARTTLS the password would travel in cleartext.
+	if ok, _ := c.Extension("AUTH"); ok {
+		t.Error("AUTH advertised before STARTTLS")
+	}
+	if ok, _ := c.Extension("STARTTLS"); !ok {
+		t.Fatal("STARTTLS not advertised")
+	}
+	expect(t, c.Text, 538, "AUTH PLAIN "+base64.StdEncoding.EncodeToString([]byte("\x00alice\x00secret")))
+	expect(t, c.Text, 530, "MAIL FROM:<alice@example.com>")
+
+	if err := c.StartTLS(clientTLS); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := c.Extension("AUTH"); !ok {
+		t.Fatal("AUTH not advertised after STARTTLS")
+	}
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		t.Error("STARTTLS advertised again")
+	}
+	if err := c.Auth(smtp.PlainAuth("", "alice", "secret", "relay.example.com")); err != nil {
+		t.Fatal(err)
+	}
+	if err := send(c, "alice@example.com", []string{"bob@example.com"}, "Subject: hi\r\n\r\nok\r\n"); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.delivered(); len(got) != 1 {
+		t.Errorf("%d messages delivered, want 1", len(got))
+	}
+}
@@ -0,0 +1,179 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package tokenstore
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"strings"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/oauth2"
+)
+
+// PassphraseEnv names the environment variable holding the passphrase of
+// encrypted token files.
+const PassphraseEnv = "GOMAILIT_TOKEN_PASSPHRASE"
+
+const (
+	kdfPBKDF2 = "pbkdf2-sha256"
+	kdfHKDF   = "hkdf-sha256"
+
+	pbkdf2Iterations = 600000
+)
+
+// encryptedToken is the on-disk format of an encrypted token file.
+type encryptedToken struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations,omitempty"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+type encryptedFileStore struct {
+	path string
+}
+
+// NewEncryptedFile returns a store that keeps the token in a file encrypted
+// with XChaCha20-Poly1305. The key is derived from $GOMAILIT_TOKEN_PASSPHRASE
+// when it is set, and otherwise from the machine ID and user, which keeps
+// the token unreadable when the file is copied elsewhere, such as into a
+// backup, but not from other programs running as the same user.
+func NewEncryptedFile(path string) Store {
+	return &encryptedFileStore{path: path}
+}
+
+func (s *encryptedFileStore) Kind() Kind { return EncryptedFile }
+
+func (s *encryptedFileStore) Location() string { return s.path }
+
+func (s *encryptedFileStore) Load() (*oauth2.Token, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read token: %v", err)
+	}
+
+	var file encryptedToken
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("unable to decode encrypted token: %v", err)
+	}
+	if file.Version != 1 {
+		return nil, fmt.Errorf("unsupported encrypted token version %d", file.Version)
+	}
+
+	key, err := deriveKey(file.KDF, file.Salt, file.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, file.Nonce, file.Ciphertext, nil)
+	if err != nil {
+		if file.KDF == kdfPBKDF2 {
+			return nil, fmt.Errorf("unable to decrypt token, check $%s", PassphraseEnv)
+		}
+		return nil, errors.New("unable to decrypt token, it was encrypted on another machine or by another user")
+	}
+	return decodeToken(plaintext)
+}
+
+func (s *encryptedFileStore) Save(token *oauth2.Token) error {
+	plaintext, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	file := encryptedToken{
+		Version: 1,
+		KDF:     kdfHKDF,
+		Salt:    make([]byte, 16),
+		Nonce:   make([]byte, chacha20poly1305.NonceSizeX),
+	}
+	if os.Getenv(PassphraseEnv) != "" {
+		file.KDF = kdfPBKDF2
+		file.Iterations = pbkdf2Iterations
+	}
+	if _, err := rand.Read(file.Salt); err != nil {
+		return err
+	}
+	if _, err := rand.Read(file.Nonce); err != nil {
+		return err
+	}
+
+	key, err := deriveKey(file.KDF, file.Salt, file.Iterations)
+	if err != nil {
+		return err
+	}
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return err
+	}
+	file.Ciphertext = aead.Seal(nil, file.Nonce, plaintext, nil)
+
+	data, err := json.MarshalIndent(&file, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(s.path, data)
+}
+
+func (s *encryptedFileStore) Delete() error {
+	return removeFile(s.path)
+}
+
+// deriveKey derives the file key with the function recorded in the file.
+func deriveKey(kdf string, salt []byte, iterations int) ([]byte, error) {
+	switch kdf {
+	case kdfPBKDF2:
+		passphrase := os.Getenv(PassphraseEnv)
+		if passphrase == "" {
+			return nil, fmt.Errorf("token is protected by a passphrase, set $%s", PassphraseEnv)
+		}
+		return pbkdf2.Key(sha256.New, passphrase, salt, iterations, chacha20poly1305.KeySize)
+	case kdfHKDF:
+		key := make([]byte, chacha20poly1305.KeySize)
+		if _, err := io.ReadFull(hkdf.New(sha256.New, machineSecret(), salt, []byte("gomailit token")), key); err != nil {
+			return nil, err
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported key derivation %q", kdf)
+	}
+}
+
+// machineSecret identifies the machine and user the token belongs to.
+func machineSecret() []byte {
+	var parts []string
+	for _, path := range []string{"/etc/machine-id", "/var/lib/dbus/machine-id"} {
+		if data, err := os.ReadFile(path); err == nil {
+			parts = append(parts, strings.TrimSpace(string(data)))
+			break
+		}
+	}
+	if len(parts) == 0 {
+		// No machine ID outside Linux, the host name is the next best thing.
+		if host, err := os.Hostname(); err == nil {
+			parts = append(parts, host)
+		}
+	}
+	if u, err := user.Current(); err == nil {
+		parts = append(parts, u.Uid, u.HomeDir)
+	}
+	return []byte(strings.Join(parts, "\x00"))
+}
@@ -0,0 +1,194 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+
+// Package tokenstore keeps OAuth2 tokens in the OS keyring, a plain JSON
+// file or an encrypted file.
+package tokenstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/zalando/go-keyring"
+	"golang.org/x/oauth2"
+)
+
+// Kind names a token store backend.
+type Kind string
+
+const (
+	Keyring       Kind = "keyring"
+	File          Kind = "file"
+	EncryptedFile Kind = "encrypted-file"
+)
+
+// Kinds lists the valid values of --token-store.
+var Kinds = []Kind{Keyring, File, EncryptedFile}
+
+// ErrNotFound is returned by Load when no token has been saved.
+var ErrNotFound = errors.New("token not found")
+
+// Store loads and saves a single token.
+type Store interface {
+	Kind() Kind
+	Load() (*oauth2.Token, error)
+	Save(token *oauth2.Token) error
+	Delete() error
+	// Location describes where the token is kept, for messages.
+	Location() string
+}
+
+// ParseKind validates a --token-store value.
+func ParseKind(value string) (Kind, error) {
+	for _, kind := range Kinds {
+		if string(kind) == value {
+			return kind, nil
+		}
+	}
+	return "", fmt.Errorf("unknown token store %q, expected keyring, file or encrypted-file", value)
+}
+
+// Migrate moves the token in from, if there is one, to to and reports
+// whether a token was moved.
+func Migrate(from, to Store) (bool, error) {
+	token, err := from.Load()
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := to.Save(token); err != nil {
+		return false, err
+	}
+	if err := from.Delete(); err != nil && !errors.Is(err, ErrNotFound) {
+		return true, fmt.Errorf("token copied but unable to remove it from %s: %v", from.Location(), err)
+	}
+	return true, nil
+}
+
+type keyringStore struct {
+	service, user string
+}
+
+// NewKeyring returns a store that keeps the token in the OS keyring (the
+// Secret Service on Linux, Keychain on macOS, Credential Manager on
+// Windows).
+func NewKeyring(service, user string) Store {
+	return &keyringStore{service: service, user: user}
+}
+
+func (s *keyringStore) Kind() Kind { return Keyring }
+
+func (s *keyringStore) Location() string {
+	return fmt.Sprintf("the OS keyring (%s/%s)", s.service, s.user)
+}
+
+func (s *keyringStore) Load() (*oauth2.Token, error) {
+	data, err := keyring.Get(s.service, s.user)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read token from keyring: %v", err)
+	}
+	return decodeToken([]byte(data))
+}
+
+func (s *keyringStore) Save(token *oauth2.Token) error {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	if err := keyring.Set(s.service, s.user, string(data)); err != nil {
+		return fmt.Errorf("unable to save token to keyring: %w", err)
+	}
+	return nil
+}
+
+func (s *keyringStore) Delete() error {
+	err := keyring.Delete(s.service, s.user)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+type fileStore struct {
+	path string
+}
+
+// NewFile returns a store that keeps the token as plain JSON, readable only
+// by the current user.
+func NewFile(path string) Store {
+	return &fileStore{path: path}
+}
+
+func (s *fileStore) Kind() Kind { return File }
+
+func (s *fileStore) Location() string { return s.path }
+
+func (s *fileStore) Load() (*oauth2.Token, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read token: %v", err)
+	}
+	return decodeToken(data)
+}
+
+func (s *fileStore) Save(token *oauth2.Token) error {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	return writeFile(s.path, data)
+}
+
+func (s *fileStore) Delete() error {
+	return removeFile(s.path)
+}
+
+func decodeToken(data []byte) (*oauth2.Token, error) {
+	token := &oauth2.Token{}
+	if err := json.Unmarshal(data, token); err != nil {
+		return nil, fmt.Errorf("unable to decode token: %v", err)
+	}
+	return token, nil
+}
+
+// writeFile writes data with 0600 permissions through a temporary file, so
+// that a crash never leaves a truncated token behind.
+func writeFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("unable to save token: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("unable to save token: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("unable to save token: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("unable to save token: %v", err)
+	}
+	return nil
+}
+
+func removeFile(path string) error {
+	err := os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	}
+	return err
+}
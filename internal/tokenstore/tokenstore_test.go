@@ -0,0 +1,195 @@
+/*
+Copyright © 2025 Jaycy Ivan Bañaga jaycybanaga@gmail.com
+*/
+package tokenstore
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zalando/go-keyring"
+	"golang.org/x/oauth2"
+)
+
+func testToken() *oauth2.Token {
+	return &oauth2.Token{
+		AccessToken:  "access-token",
+		TokenType:    "Bearer",
+		RefreshToken: "refresh-token",
+		Expiry:       time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func sameToken(t *testing.T, got, want *oauth2.Token) {
+	t.Helper()
+	if got.AccessToken != want.AccessToken || got.RefreshToken != want.RefreshToken ||
+		got.TokenType != want.TokenType || !got.Expiry.Equal(want.Expiry) {
+		t.Errorf("loaded %+v, want %+v", got, want)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	keyring.MockInit()
+	dir := t.TempDir()
+	tests := []struct {
+		name       string
+		store      Store
+		passphrase string
+	}{
+		{name: "keyring", store: NewKeyring("gomailit-test", "google")},
+		{name: "file", store: NewFile(filepath.Join(dir, "token.json"))},
+		{name: "encrypted file", store: NewEncryptedFile(filepath.Join(dir, "token.enc"))},
+		{name: "encrypted file with passphrase", store: NewEncryptedFile(filepath.Join(dir, "token.pass")), passphrase: "correct horse"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(PassphraseEnv, tt.passphrase)
+
+			if _, err := tt.store.Load(); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("Load before Save: %v, want ErrNotFound", err)
+			}
+			if err := tt.store.Save(testToken()); err != nil {
+				t.Fatal(err)
+			}
+			got, err := tt.store.Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			sameToken(t, got, testToken())
+
+			if err := tt.store.Delete(); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := tt.store.Load(); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Load after Delete: %v, want ErrNotFound", err)
+			}
+			if err := tt.store.Delete(); !errors.Is(err, ErrNotFound) {
+				t.Errorf("second Delete: %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestFilePermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no Unix permissions on Windows")
+	}
+	dir := t.TempDir()
+	for _, store := range []Store{NewFile(filepath.Join(dir, "token.json")), NewEncryptedFile(filepath.Join(dir, "token.enc"))} {
+		if err := store.Save(testToken()); err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Stat(store.Location())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if perm := info.Mode().Perm(); perm != 0600 {
+			t.Errorf("%s has permissions %o, want 600", store.Location(), perm)
+		}
+	}
+}
+
+func TestEncryptedFileHidesToken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token.enc")
+	if err := NewEncryptedFile(path).Save(testToken()); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "access-token") || strings.Contains(string(data), "refresh-token") {
+		t.Errorf("token in cleartext:\n%s", data)
+	}
+}
+
+func TestEncryptedFileWrongPassphrase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token.enc")
+	t.Setenv(PassphraseEnv, "correct horse")
+	if err := NewEncryptedFile(path).Save(testToken()); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(PassphraseEnv, "battery staple")
+	_, err := NewEncryptedFile(path).Load()
+	if err == nil || !strings.Contains(err.Error(), PassphraseEnv) {
+		t.Errorf("wrong passphrase: %v, want an error naming $%s", err, PassphraseEnv)
+	}
+
+	t.Setenv(PassphraseEnv, "")
+	_, err = NewEncryptedFile(path).Load()
+	if err == nil || !strings.Contains(err.Error(), "set $"+PassphraseEnv) {
+		t.Errorf("no passphrase: %v, want an error asking for $%s", err, PassphraseEnv)
+	}
+}
+
+func TestEncryptedFileTampered(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token.enc")
+	store := NewEncryptedFile(path)
+	if err := store.Save(testToken()); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var file encryptedToken
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatal(err)
+	}
+	file.Ciphertext[0] ^= 1
+	data, err = json.Marshal(&file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if token, err := store.Load(); err == nil {
+		t.Errorf("tampered token loaded: %+v", token)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	dir := t.TempDir()
+	from := NewFile(filepath.Join(dir, "token.json"))
+	to := NewEncryptedFile(filepath.Join(dir, "token.enc"))
+
+	if moved, err := Migrate(from, to); err != nil || moved {
+		t.Fatalf("Migrate without a token = %v, %v", moved, err)
+	}
+
+	if err := from.Save(testToken()); err != nil {
+		t.Fatal(err)
+	}
+	if moved, err := Migrate(from, to); err != nil || !moved {
+		t.Fatalf("Migrate = %v, %v", moved, err)
+	}
+	if _, err := from.Load(); !errors.Is(err, ErrNotFound) {
+		t.Errorf("token left in the old store: %v", err)
+	}
+	got, err := to.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sameToken(t, got, testToken())
+}
+
+func TestParseKind(t *testing.T) {
+	for _, kind := range Kinds {
+		if got, err := ParseKind(string(kind)); err != nil || got != kind {
+			t.Errorf("ParseKind(%q) = %q, %v", kind, got, err)
+		}
+	}
+	if _, err := ParseKind("vault"); err == nil {
+		t.Error("ParseKind accepted an unknown store")
+	}
+}
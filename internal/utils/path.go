@@ -40,6 +40,109 @@ func getAppConfigDir() string {
 	return appDir
 }
 
-func TokenPath() string {
-	return filepath.Join(getAppConfigDir(), "token.json")
+// profile is the name of the selected profile, "" for the default one.
+var profile string
+
+// SetProfile selects the profile whose token, provider settings and outbox
+// the path functions return. The default profile lives directly in the
+// config directory, named ones in profiles/<name>.
+func SetProfile(name string) {
+	if name == "default" {
+		name = ""
+	}
+	profile = name
+}
+
+// Profile returns the name of the selected profile.
+func Profile() string {
+	if profile == "" {
+		return "default"
+	}
+	return profile
+}
+
+// ProfileDir returns the directory holding the files of a profile.
+func ProfileDir(name string) string {
+	if name == "" || name == "default" {
+		return getAppConfigDir()
+	}
+	return filepath.Join(getAppConfigDir(), "profiles", name)
+}
+
+func getProfileDir() string {
+	dir := ProfileDir(profile)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		log.Fatalf("Unable to create profile directory: %v", err)
+	}
+	return dir
+}
+
+func ConfigPath() string {
+	return filepath.Join(getAppConfigDir(), "config.toml")
+}
+
+// CredentialsPath returns the Google OAuth client secret, which all profiles
+// share.
+func CredentialsPath() string {
+	return filepath.Join(getAppConfigDir(), "credentials.json")
+}
+
+// TokenPath returns the token file of an OAuth provider. Google's files
+// predate the other providers and keep their names without a prefix.
+func TokenPath(provider string) string {
+	return providerFile(provider, "token.json")
+}
+
+func EncryptedTokenPath(provider string) string {
+	return providerFile(provider, "token.enc")
+}
+
+func TokenStorePath(provider string) string {
+	return providerFile(provider, "token_store")
+}
+
+func providerFile(provider, name string) string {
+	if provider != "google" {
+		name = provider + "_" + name
+	}
+	return filepath.Join(getProfileDir(), name)
+}
+
+func ServiceAccountPath() string {
+	return filepath.Join(getProfileDir(), "service_account.json")
+}
+
+func GoogleConfigPath() string {
+	return filepath.Join(getProfileDir(), "google.json")
+}
+
+func OutlookConfigPath() string {
+	return filepath.Join(getProfileDir(), "outlook.json")
+}
+
+// APIConfigPath returns the settings of an email API provider such as
+// SendGrid. Its API key is kept in the token store.
+func APIConfigPath(provider string) string {
+	return filepath.Join(getProfileDir(), provider+".json")
+}
+
+func SMTPConfigPath() string {
+	return filepath.Join(getProfileDir(), "smtp.json")
+}
+
+func ProviderPath() string {
+	return filepath.Join(getProfileDir(), "provider")
+}
+
+func SendCountPath() string {
+	return filepath.Join(getProfileDir(), "send_count.json")
+}
+
+func OutboxDir() string {
+	return filepath.Join(getProfileDir(), "outbox")
+}
+
+// HistoryPath returns the log of sent messages, which all profiles share.
+func HistoryPath() string {
+	return filepath.Join(getAppConfigDir(), "history.jsonl")
 }